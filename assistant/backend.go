@@ -0,0 +1,67 @@
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chatserver/models"
+)
+
+// Tipos de backend suportados
+const (
+	KindN8N  = "n8n"
+	KindEcho = "echo"
+)
+
+// Request representa o que é enviado ao backend do assistente
+type Request struct {
+	Message        string
+	ConversationID string
+	History        []models.Message // Histórico das últimas mensagens (mais antigas primeiro)
+	Task           string           // Vazio para respostas do chat; TaskTitle para pedir um título
+}
+
+// Response representa a resposta do backend do assistente
+type Response struct {
+	Content  string
+	Metadata map[string]interface{}
+}
+
+// Backend gera as respostas do assistente a partir do histórico e da mensagem do usuário
+type Backend interface {
+	// Name retorna o identificador do backend (ex: "n8n", "echo")
+	Name() string
+	// Send envia a mensagem e aguarda a resposta completa
+	Send(ctx context.Context, req Request) (*Response, error)
+}
+
+// Config define qual backend usar e como configurá-lo
+type Config struct {
+	Kind          string // n8n (padrão) ou echo
+	N8NWebhookURL string
+	N8NOptions    N8NOptions
+
+	// Circuit breaker do backend n8n (BreakerThreshold 0 = desabilitado)
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// New cria o backend descrito pela configuração, instrumentado com métricas do Prometheus
+func New(cfg Config) (Backend, error) {
+	switch cfg.Kind {
+	case "", KindN8N:
+		if cfg.N8NWebhookURL == "" {
+			return nil, fmt.Errorf("URL do webhook n8n não configurada")
+		}
+		var backend Backend = NewN8NBackend(cfg.N8NWebhookURL, cfg.N8NOptions)
+		if cfg.BreakerThreshold > 0 {
+			backend = NewCircuitBreaker(backend, cfg.BreakerThreshold, cfg.BreakerCooldown)
+		}
+		return NewInstrumentedBackend(backend), nil
+	case KindEcho:
+		return NewInstrumentedBackend(NewEchoBackend()), nil
+	default:
+		return nil, fmt.Errorf("backend de chat desconhecido: %q", cfg.Kind)
+	}
+}
@@ -0,0 +1,66 @@
+package assistant
+
+import (
+	"context"
+	"testing"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewSelectsBackend(t *testing.T) {
+	const webhook = "http://localhost:5678/webhook/chat"
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"padrão", Config{N8NWebhookURL: webhook}, KindN8N},
+		{"n8n", Config{Kind: KindN8N, N8NWebhookURL: webhook}, KindN8N},
+		{"eco", Config{Kind: KindEcho}, KindEcho},
+	}
+	for _, tt := range tests {
+		backend, err := New(tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if backend.Name() != tt.want {
+			t.Errorf("%s: backend %q, esperado %q", tt.name, backend.Name(), tt.want)
+		}
+	}
+
+	for _, cfg := range []Config{{}, {Kind: KindN8N}, {Kind: "outro", N8NWebhookURL: webhook}} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("config %+v: esperado erro", cfg)
+		}
+	}
+}
+
+func TestEchoBackendSend(t *testing.T) {
+	backend := NewEchoBackend()
+	conversationID := primitive.NewObjectID()
+	history := []models.Message{
+		*models.NewMessage(conversationID, models.RoleUser, "antes"),
+		*models.NewMessage(conversationID, models.RoleAssistant, "Echo: antes"),
+	}
+
+	resp, err := backend.Send(context.Background(), Request{Message: "oi", History: history})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Echo: oi" {
+		t.Errorf("conteúdo %q, esperado %q", resp.Content, "Echo: oi")
+	}
+	if resp.Metadata["backend"] != KindEcho || resp.Metadata["historySize"] != len(history) {
+		t.Errorf("metadados %v, esperado o backend e o tamanho do histórico", resp.Metadata)
+	}
+
+	// Requisição já cancelada pelo cliente
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := backend.Send(ctx, Request{Message: "oi"}); err != context.Canceled {
+		t.Errorf("erro %v, esperado %v", err, context.Canceled)
+	}
+}
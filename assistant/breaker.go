@@ -0,0 +1,137 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Estados do circuit breaker
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// CircuitBreaker envolve um Backend e passa a falhar imediatamente com
+// ErrCircuitOpen após uma sequência de falhas, até o período de espera terminar.
+// Depois disso uma única chamada de teste é liberada (half-open): se funcionar
+// o circuito fecha, senão volta a abrir.
+type CircuitBreaker struct {
+	backend   Backend
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker abre o circuito após threshold falhas consecutivas,
+// mantendo-o aberto por cooldown
+func NewCircuitBreaker(backend Backend, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		backend:   backend,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Name implementa Backend
+func (cb *CircuitBreaker) Name() string {
+	return cb.backend.Name()
+}
+
+// Send implementa Backend
+func (cb *CircuitBreaker) Send(ctx context.Context, req Request) (*Response, error) {
+	if err := cb.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := cb.backend.Send(ctx, req)
+	cb.record(ctx, err)
+	return resp, err
+}
+
+// Stream implementa Streamer
+func (cb *CircuitBreaker) Stream(ctx context.Context, req Request, onChunk ChunkHandler) (*Response, error) {
+	if err := cb.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := Stream(ctx, cb.backend, req, onChunk)
+	cb.record(ctx, err)
+	return resp, err
+}
+
+// Title implementa Titler quando o backend envolvido também implementa
+func (cb *CircuitBreaker) Title(ctx context.Context, req Request) (string, error) {
+	titler, ok := cb.backend.(Titler)
+	if !ok {
+		return "", errTitleUnsupported
+	}
+	if err := cb.allow(); err != nil {
+		return "", err
+	}
+	title, err := titler.Title(ctx, req)
+	cb.record(ctx, err)
+	return title, err
+}
+
+// allow verifica se a chamada pode ser feita no estado atual
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case breakerOpen:
+		remaining := cb.cooldown - time.Since(cb.openedAt)
+		if remaining > 0 {
+			return &CircuitOpenError{RetryAfter: remaining}
+		}
+		cb.state = breakerHalfOpen
+		cb.probing = true
+		return nil
+	case breakerHalfOpen:
+		// Apenas uma chamada de teste por vez
+		if cb.probing {
+			return &CircuitOpenError{RetryAfter: cb.cooldown}
+		}
+		cb.probing = true
+	}
+	return nil
+}
+
+// record atualiza o estado com o resultado da chamada
+func (cb *CircuitBreaker) record(ctx context.Context, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+
+	switch {
+	case err == nil || isClientError(err):
+		// O backend respondeu: fecha o circuito
+		cb.state = breakerClosed
+		cb.failures = 0
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		// Cancelado pelo cliente: não diz nada sobre o backend. Se era a chamada
+		// de teste, a próxima requisição testa novamente.
+		if cb.state == breakerHalfOpen {
+			cb.state = breakerOpen
+		}
+	default:
+		cb.failures++
+		if cb.state == breakerHalfOpen || cb.failures >= cb.threshold {
+			cb.state = breakerOpen
+			cb.openedAt = time.Now()
+		}
+	}
+}
+
+// isClientError indica uma resposta 4xx do backend, que não deve abrir o circuito
+func isClientError(err error) bool {
+	var upstreamErr *UpstreamError
+	return errors.As(err, &upstreamErr) && !upstreamErr.Retryable()
+}
@@ -0,0 +1,135 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeBackend responde com os erros da fila, em ordem, e conta as chamadas
+type fakeBackend struct {
+	errs  []error
+	calls int
+}
+
+func (b *fakeBackend) Name() string {
+	return "fake"
+}
+
+func (b *fakeBackend) Send(ctx context.Context, req Request) (*Response, error) {
+	b.calls++
+	if len(b.errs) == 0 {
+		return &Response{Content: "ok"}, nil
+	}
+	err := b.errs[0]
+	b.errs = b.errs[1:]
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Content: "ok"}, nil
+}
+
+var errUpstreamDown = &UpstreamError{StatusCode: 503, Body: "indisponível"}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	backend := &fakeBackend{errs: []error{errUpstreamDown, errUpstreamDown, errUpstreamDown}}
+	cb := NewCircuitBreaker(backend, 3, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := cb.Send(ctx, Request{}); !errors.Is(err, errUpstreamDown) {
+			t.Fatalf("chamada %d: erro %v, esperado o erro do backend", i+1, err)
+		}
+	}
+
+	// Aberto: falha sem chamar o backend, informando quando tentar de novo
+	_, err := cb.Send(ctx, Request{})
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("circuito aberto: erro %v, esperado %v", err, ErrCircuitOpen)
+	}
+	if openErr.RetryAfter <= 0 {
+		t.Errorf("RetryAfter = %s, esperado positivo", openErr.RetryAfter)
+	}
+	if backend.calls != 3 {
+		t.Errorf("backend chamado %d vezes, esperado 3", backend.calls)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	backend := &fakeBackend{errs: []error{errUpstreamDown, nil, errUpstreamDown, errUpstreamDown}}
+	cb := NewCircuitBreaker(backend, 3, time.Minute)
+
+	for i := 0; i < 4; i++ {
+		cb.Send(context.Background(), Request{})
+	}
+	if _, err := cb.Send(context.Background(), Request{}); err != nil {
+		t.Fatalf("falhas intercaladas com sucesso abriram o circuito: %v", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	badRequest := &UpstreamError{StatusCode: 400, Body: "inválido"}
+	backend := &fakeBackend{errs: []error{badRequest, badRequest, badRequest}}
+	cb := NewCircuitBreaker(backend, 2, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := cb.Send(context.Background(), Request{}); !errors.Is(err, badRequest) {
+			t.Fatalf("chamada %d: erro %v, esperado o 400 do backend", i+1, err)
+		}
+	}
+	if _, err := cb.Send(context.Background(), Request{}); err != nil {
+		t.Errorf("respostas 4xx abriram o circuito: %v", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	ctx := context.Background()
+
+	t.Run("sucesso fecha", func(t *testing.T) {
+		backend := &fakeBackend{errs: []error{errUpstreamDown}}
+		cb := NewCircuitBreaker(backend, 1, cooldown)
+		cb.Send(ctx, Request{})
+
+		time.Sleep(cooldown)
+		if _, err := cb.Send(ctx, Request{}); err != nil {
+			t.Fatalf("chamada de teste: %v", err)
+		}
+		if _, err := cb.Send(ctx, Request{}); err != nil {
+			t.Errorf("circuito não fechou após o teste bem-sucedido: %v", err)
+		}
+	})
+
+	t.Run("falha reabre", func(t *testing.T) {
+		backend := &fakeBackend{errs: []error{errUpstreamDown, errUpstreamDown}}
+		cb := NewCircuitBreaker(backend, 1, cooldown)
+		cb.Send(ctx, Request{})
+
+		time.Sleep(cooldown)
+		if _, err := cb.Send(ctx, Request{}); !errors.Is(err, errUpstreamDown) {
+			t.Fatalf("chamada de teste: erro %v, esperado o erro do backend", err)
+		}
+		if _, err := cb.Send(ctx, Request{}); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("circuito não reabriu após o teste com falha: %v", err)
+		}
+		if backend.calls != 2 {
+			t.Errorf("backend chamado %d vezes, esperado 2", backend.calls)
+		}
+	})
+
+	t.Run("uma chamada de teste por vez", func(t *testing.T) {
+		backend := &fakeBackend{errs: []error{errUpstreamDown}}
+		cb := NewCircuitBreaker(backend, 1, cooldown)
+		cb.Send(ctx, Request{})
+
+		time.Sleep(cooldown)
+		if err := cb.allow(); err != nil {
+			t.Fatalf("chamada de teste recusada: %v", err)
+		}
+		if err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("segunda chamada durante o teste: erro %v, esperado %v", err, ErrCircuitOpen)
+		}
+	})
+}
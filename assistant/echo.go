@@ -0,0 +1,55 @@
+package assistant
+
+import (
+	"context"
+	"strings"
+)
+
+// EchoBackend devolve a própria mensagem do usuário, sem acesso à rede.
+// Útil para desenvolvimento local e testes.
+type EchoBackend struct{}
+
+// NewEchoBackend cria um backend de eco
+func NewEchoBackend() *EchoBackend {
+	return &EchoBackend{}
+}
+
+// Name implementa Backend
+func (b *EchoBackend) Name() string {
+	return KindEcho
+}
+
+// Send implementa Backend
+func (b *EchoBackend) Send(ctx context.Context, req Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Content: "Echo: " + req.Message,
+		Metadata: map[string]interface{}{
+			"backend":     KindEcho,
+			"historySize": len(req.History),
+		},
+	}, nil
+}
+
+// Stream implementa Streamer, entregando a resposta palavra por palavra
+func (b *EchoBackend) Stream(ctx context.Context, req Request, onChunk ChunkHandler) (*Response, error) {
+	resp, err := b.Send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	words := strings.SplitAfter(resp.Content, " ")
+	for _, word := range words {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onChunk(word); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
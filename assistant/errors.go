@@ -0,0 +1,40 @@
+package assistant
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCircuitOpen indica que o circuit breaker está aberto e o backend não foi chamado
+var ErrCircuitOpen = errors.New("backend do assistente indisponível (circuit breaker aberto)")
+
+// CircuitOpenError é retornado enquanto o circuit breaker está aberto
+type CircuitOpenError struct {
+	RetryAfter time.Duration // Tempo até a próxima tentativa ser permitida
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s, nova tentativa em %s", ErrCircuitOpen, e.RetryAfter.Round(time.Second))
+}
+
+// Is permite usar errors.Is(err, ErrCircuitOpen)
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// UpstreamError representa uma resposta de erro do serviço externo.
+// O corpo é mantido apenas para logs e não deve ser repassado ao cliente.
+type UpstreamError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("upstream retornou status %d: %s", e.StatusCode, e.Body)
+}
+
+// Retryable indica se vale a pena repetir a chamada (erros 5xx e 429)
+func (e *UpstreamError) Retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == 429
+}
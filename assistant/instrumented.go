@@ -0,0 +1,103 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"chatserver/metrics"
+	"chatserver/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentedBackend envolve um Backend e registra no Prometheus a latência e
+// o resultado de cada chamada (assistant_backend_requests_total e
+// assistant_backend_request_duration_seconds), além de um span de tracing
+// ("assistant send", "assistant stream" ou "assistant title")
+type InstrumentedBackend struct {
+	backend Backend
+}
+
+// NewInstrumentedBackend instrumenta backend. Deve envolver o circuit breaker,
+// para que as chamadas recusadas com o circuito aberto também sejam contadas.
+func NewInstrumentedBackend(backend Backend) *InstrumentedBackend {
+	return &InstrumentedBackend{backend: backend}
+}
+
+// Name implementa Backend
+func (ib *InstrumentedBackend) Name() string {
+	return ib.backend.Name()
+}
+
+// Send implementa Backend
+func (ib *InstrumentedBackend) Send(ctx context.Context, req Request) (*Response, error) {
+	ctx, span := ib.startSpan(ctx, "send")
+	start := time.Now()
+	resp, err := ib.backend.Send(ctx, req)
+	ib.record(span, "send", start, err)
+	return resp, err
+}
+
+// Stream implementa Streamer
+func (ib *InstrumentedBackend) Stream(ctx context.Context, req Request, onChunk ChunkHandler) (*Response, error) {
+	ctx, span := ib.startSpan(ctx, "stream")
+	start := time.Now()
+	resp, err := Stream(ctx, ib.backend, req, onChunk)
+	ib.record(span, "stream", start, err)
+	return resp, err
+}
+
+// Title implementa Titler quando o backend envolvido também implementa
+func (ib *InstrumentedBackend) Title(ctx context.Context, req Request) (string, error) {
+	titler, ok := ib.backend.(Titler)
+	if !ok {
+		return "", errTitleUnsupported
+	}
+	ctx, span := ib.startSpan(ctx, "title")
+	start := time.Now()
+	title, err := titler.Title(ctx, req)
+	ib.record(span, "title", start, err)
+	return title, err
+}
+
+// startSpan inicia o span de uma chamada ao backend; as requisições ao n8n
+// (uma por tentativa) aparecem como filhas dele
+func (ib *InstrumentedBackend) startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "assistant "+operation, trace.WithAttributes(
+		attribute.String("assistant.backend", ib.backend.Name()),
+		attribute.String("assistant.operation", operation),
+	))
+}
+
+// record registra uma chamada ao backend e encerra o seu span
+func (ib *InstrumentedBackend) record(span trace.Span, operation string, start time.Time, err error) {
+	status := backendStatus(err)
+	metrics.RecordBackendRequest(ib.backend.Name(), operation, status, time.Since(start).Seconds())
+
+	span.SetAttributes(attribute.String("assistant.status", status))
+	tracing.RecordError(span, err)
+	span.End()
+}
+
+// backendStatus classifica o resultado de uma chamada: success, o status HTTP
+// retornado pelo serviço externo, circuit_open, timeout, canceled ou error
+func backendStatus(err error) string {
+	var upstreamErr *UpstreamError
+	switch {
+	case err == nil:
+		return "success"
+	case errors.As(err, &upstreamErr):
+		return strconv.Itoa(upstreamErr.StatusCode)
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,374 @@
+package assistant
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"chatserver/logging"
+	"chatserver/models"
+	"chatserver/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultN8NWebhookURL é o webhook usado quando N8N_WEBHOOK_URL não é configurado
+const DefaultN8NWebhookURL = "https://galaxy.conecta-tech.com.br/webhook/conversation"
+
+// N8NRequest representa a requisição para o n8n
+type N8NRequest struct {
+	Message        string           `json:"message"`
+	ConversationID string           `json:"conversationId"`
+	History        []models.Message `json:"history,omitempty"` // Histórico das últimas mensagens
+	Task           string           `json:"task,omitempty"`    // "title" quando o workflow deve sugerir um título
+}
+
+// N8NResponse representa a resposta do n8n
+type N8NResponse struct {
+	Output   string                 `json:"output"`   // Campo retornado pelo N8N
+	Response string                 `json:"response"` // Alternativa (compatibilidade)
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// GetResponse retorna a resposta (output ou response)
+func (n *N8NResponse) GetResponse() string {
+	if n.Output != "" {
+		return n.Output
+	}
+	return n.Response
+}
+
+// N8NOptions controla timeout e novas tentativas das chamadas ao webhook
+type N8NOptions struct {
+	Timeout      time.Duration // Tempo máximo de cada tentativa (0 = sem limite)
+	MaxRetries   int           // Novas tentativas após erros de rede/5xx
+	RetryBackoff time.Duration // Espera antes da primeira nova tentativa (dobra a cada tentativa)
+
+	// Workflow que sugere os títulos das conversas. Vazio desabilita Title: o
+	// webhook de chat nunca recebe pedidos de título, que seriam respondidos
+	// como mensagens comuns por workflows que não tratam o campo task.
+	TitleWebhookURL string
+}
+
+// N8NBackend envia as mensagens para um workflow do n8n via webhook
+type N8NBackend struct {
+	webhookURL string
+	client     *http.Client
+	options    N8NOptions
+}
+
+// NewN8NBackend cria um backend que chama o webhook informado. Valores
+// negativos de MaxRetries e RetryBackoff são tratados como zero.
+func NewN8NBackend(webhookURL string, options N8NOptions) *N8NBackend {
+	options.MaxRetries = max(options.MaxRetries, 0)
+	options.RetryBackoff = max(options.RetryBackoff, 0)
+	return &N8NBackend{
+		webhookURL: webhookURL,
+		client:     &http.Client{},
+		options:    options,
+	}
+}
+
+// Name implementa Backend
+func (b *N8NBackend) Name() string {
+	return KindN8N
+}
+
+// Send chama o webhook do n8n
+func (b *N8NBackend) Send(ctx context.Context, req Request) (*Response, error) {
+	resp, err := b.post(ctx, b.webhookURL, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	n8nResponse, err := parseN8NResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Content:  n8nResponse.GetResponse(),
+		Metadata: n8nResponse.Metadata,
+	}, nil
+}
+
+// Title implementa Titler enviando ao workflow de títulos (TitleWebhookURL) a
+// primeira troca de mensagens com task "title"; o workflow responde com o
+// título no campo output
+func (b *N8NBackend) Title(ctx context.Context, req Request) (string, error) {
+	if b.options.TitleWebhookURL == "" {
+		return "", errTitleUnsupported
+	}
+
+	req.Task = TaskTitle
+	resp, err := b.post(ctx, b.options.TitleWebhookURL, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	n8nResponse, err := parseN8NResponse(body)
+	if err != nil {
+		return "", err
+	}
+	return n8nResponse.GetResponse(), nil
+}
+
+// n8nStreamChunk representa uma linha da resposta em streaming do n8n
+// (webhook configurado com "Response Mode: Streaming")
+type n8nStreamChunk struct {
+	Type     string                 `json:"type"` // begin, item, end, error
+	Content  string                 `json:"content"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Stream implementa Streamer. Webhooks configurados com streaming respondem
+// com uma linha JSON por parte; respostas comuns são entregues em uma única parte.
+func (b *N8NBackend) Stream(ctx context.Context, req Request, onChunk ChunkHandler) (*Response, error) {
+	resp, err := b.post(ctx, b.webhookURL, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	firstLine, err := reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var first n8nStreamChunk
+	if json.Unmarshal(firstLine, &first) != nil || !isN8NStreamChunk(first.Type) {
+		// Resposta sem streaming: ler o restante e entregar de uma vez
+		rest, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		n8nResponse, err := parseN8NResponse(append(firstLine, rest...))
+		if err != nil {
+			return nil, err
+		}
+		content := n8nResponse.GetResponse()
+		if content != "" {
+			if err := onChunk(content); err != nil {
+				return nil, err
+			}
+		}
+		return &Response{Content: content, Metadata: n8nResponse.Metadata}, nil
+	}
+
+	var content strings.Builder
+	result := &Response{}
+	line := firstLine
+	for {
+		var chunk n8nStreamChunk
+		if len(bytes.TrimSpace(line)) > 0 {
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				return nil, fmt.Errorf("erro ao parsear parte do streaming do n8n: %v", err)
+			}
+		}
+
+		switch chunk.Type {
+		case "item":
+			content.WriteString(chunk.Content)
+			if err := onChunk(chunk.Content); err != nil {
+				return nil, err
+			}
+		case "error":
+			return nil, fmt.Errorf("n8n retornou erro no streaming: %s", chunk.Content)
+		}
+		if chunk.Metadata != nil {
+			result.Metadata = chunk.Metadata
+		}
+
+		line, err = reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+// post envia a requisição ao webhook informado, repetindo após erros de rede e respostas 5xx.
+// O contexto de cada tentativa (com o timeout configurado) só é cancelado quando
+// o corpo da resposta é fechado.
+func (b *N8NBackend) post(ctx context.Context, webhookURL string, req Request) (*http.Response, error) {
+	jsonData, err := json.Marshal(N8NRequest{
+		Message:        req.Message,
+		ConversationID: req.ConversationID,
+		History:        req.History,
+		Task:           req.Task,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := b.options.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := b.attempt(ctx, webhookURL, jsonData, attempt)
+		if err == nil {
+			return resp, nil
+		}
+
+		if attempt >= b.options.MaxRetries || !isRetryable(ctx, err) {
+			return nil, err
+		}
+
+		// Backoff exponencial com jitter, interrompido se o cliente desistir
+		wait := backoff + time.Duration(rand.Int64N(int64(backoff)/2+1))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt faz uma única chamada ao webhook e valida o status da resposta.
+// A chamada é registrada em um span, encerrado junto com o contexto da
+// tentativa, e os headers traceparent e X-Request-ID levam o trace e o ID da
+// requisição até o n8n.
+func (b *N8NBackend) attempt(ctx context.Context, webhookURL string, jsonData []byte, retry int) (*http.Response, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "POST n8n webhook",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodPost),
+			attribute.String("server.address", webhookHost(webhookURL)),
+			attribute.Int("http.request.resend_count", retry),
+		),
+	)
+	cancelAttempt := context.CancelFunc(func() {})
+	if b.options.Timeout > 0 {
+		ctx, cancelAttempt = context.WithTimeout(ctx, b.options.Timeout)
+	}
+	cancel := func() {
+		cancelAttempt()
+		span.End()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(jsonData))
+	if err != nil {
+		tracing.RecordError(span, err)
+		cancel()
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		httpReq.Header.Set(logging.RequestIDHeader, requestID)
+	}
+
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		tracing.RecordError(span, err)
+		cancel()
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	// Qualquer 2xx é uma resposta válida (workflows podem responder 201 ou 204)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer cancel()
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := &UpstreamError{StatusCode: resp.StatusCode, Body: string(body)}
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// isRetryable indica se o erro justifica uma nova tentativa
+func isRetryable(ctx context.Context, err error) bool {
+	// O cliente desistiu: não adianta tentar de novo
+	if ctx.Err() != nil {
+		return false
+	}
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.Retryable()
+	}
+	// Erros de rede e timeout da tentativa
+	return true
+}
+
+// webhookHost retorna o host do webhook (sem o caminho, que pode conter segredos)
+func webhookHost(webhookURL string) string {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// cancelOnClose libera o contexto da tentativa (e encerra o span) quando o corpo é fechado
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+func isN8NStreamChunk(chunkType string) bool {
+	switch chunkType {
+	case "begin", "item", "end", "error":
+		return true
+	}
+	return false
+}
+
+// parseN8NResponse aceita tanto um array (formato do N8N) quanto um objeto
+// direto; um corpo vazio (204) é uma resposta sem conteúdo
+func parseN8NResponse(body []byte) (*N8NResponse, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &N8NResponse{}, nil
+	}
+
+	// Tentar primeiro como array (formato do N8N)
+	var n8nArray []N8NResponse
+	if err := json.Unmarshal(body, &n8nArray); err == nil && len(n8nArray) > 0 {
+		return &n8nArray[0], nil
+	}
+
+	// Se não for array, tentar como objeto direto
+	var n8nResponse N8NResponse
+	if err := json.Unmarshal(body, &n8nResponse); err != nil {
+		return nil, fmt.Errorf("erro ao parsear resposta do n8n: %v (body: %s)", err, string(body))
+	}
+
+	return &n8nResponse, nil
+}
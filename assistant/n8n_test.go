@@ -0,0 +1,127 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newN8NServer responde a todas as chamadas com status e body, contando as chamadas
+func newN8NServer(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestN8NRetries(t *testing.T) {
+	options := N8NOptions{MaxRetries: 2, RetryBackoff: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		status    int
+		wantCalls int32
+	}{
+		{"5xx é repetido", http.StatusBadGateway, 3},
+		{"429 é repetido", http.StatusTooManyRequests, 3},
+		{"4xx não é repetido", http.StatusBadRequest, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := newN8NServer(t, tt.status, "erro")
+			_, err := NewN8NBackend(server.URL, options).Send(context.Background(), Request{Message: "oi"})
+
+			var upstreamErr *UpstreamError
+			if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != tt.status {
+				t.Fatalf("erro %v, esperado UpstreamError com status %d", err, tt.status)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("%d chamadas ao webhook, esperado %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestN8NNegativeBackoff(t *testing.T) {
+	server, calls := newN8NServer(t, http.StatusServiceUnavailable, "")
+	backend := NewN8NBackend(server.URL, N8NOptions{MaxRetries: 1, RetryBackoff: -time.Second})
+
+	if _, err := backend.Send(context.Background(), Request{Message: "oi"}); err == nil {
+		t.Fatal("esperado erro do webhook")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d chamadas ao webhook, esperado 2", got)
+	}
+}
+
+func TestN8NAcceptsAny2xx(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{http.StatusOK, `{"output":"olá"}`, "olá"},
+		{http.StatusCreated, `[{"output":"criado"}]`, "criado"},
+		{http.StatusNoContent, "", ""},
+	}
+	for _, tt := range tests {
+		server, calls := newN8NServer(t, tt.status, tt.body)
+		resp, err := NewN8NBackend(server.URL, N8NOptions{MaxRetries: 2}).Send(context.Background(), Request{Message: "oi"})
+		if err != nil {
+			t.Errorf("status %d: %v", tt.status, err)
+			continue
+		}
+		if resp.Content != tt.want {
+			t.Errorf("status %d: conteúdo %q, esperado %q", tt.status, resp.Content, tt.want)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("status %d: %d chamadas ao webhook, esperado 1", tt.status, calls.Load())
+		}
+	}
+}
+
+func TestParseN8NResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		want     string
+		metadata bool
+	}{
+		{"array", `[{"output":"primeiro"},{"output":"segundo"}]`, "primeiro", false},
+		{"objeto", `{"output":"resposta"}`, "resposta", false},
+		{"campo response", `{"response":"compatível"}`, "compatível", false},
+		{"output tem prioridade", `{"output":"a","response":"b"}`, "a", false},
+		{"metadata", `{"output":"x","metadata":{"model":"m"}}`, "x", true},
+		{"corpo vazio", " \n", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := parseN8NResponse([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.GetResponse(); got != tt.want {
+				t.Errorf("resposta %q, esperado %q", got, tt.want)
+			}
+			if (resp.Metadata != nil) != tt.metadata {
+				t.Errorf("metadata %v", resp.Metadata)
+			}
+		})
+	}
+
+	for _, body := range []string{"não é json", "[]"} {
+		if _, err := parseN8NResponse([]byte(body)); err == nil {
+			t.Errorf("%q: esperado erro", body)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package assistant
+
+import (
+	"context"
+)
+
+// ChunkHandler recebe cada parte da resposta assim que o backend a produz.
+// Retornar um erro interrompe o streaming.
+type ChunkHandler func(chunk string) error
+
+// Streamer é implementado pelos backends capazes de enviar a resposta em partes
+type Streamer interface {
+	// Stream envia a mensagem e entrega a resposta em partes para onChunk.
+	// A Response retornada contém o conteúdo completo e os metadados finais.
+	Stream(ctx context.Context, req Request, onChunk ChunkHandler) (*Response, error)
+}
+
+// Stream chama o backend em modo streaming. Backends que não implementam
+// Streamer entregam a resposta completa em uma única parte.
+func Stream(ctx context.Context, b Backend, req Request, onChunk ChunkHandler) (*Response, error) {
+	if s, ok := b.(Streamer); ok {
+		return s.Stream(ctx, req, onChunk)
+	}
+
+	resp, err := b.Send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Content != "" {
+		if err := onChunk(resp.Content); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
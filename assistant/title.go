@@ -0,0 +1,78 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TaskTitle identifica, em Request.Task, o pedido de um título para a conversa
+const TaskTitle = "title"
+
+// maxTitleLength é o tamanho máximo (em caracteres) dos títulos gerados
+const maxTitleLength = 60
+
+// errTitleUnsupported indica que o backend não sabe gerar títulos
+var errTitleUnsupported = errors.New("backend não gera títulos")
+
+// Titler é implementado pelos backends capazes de sugerir um título para a conversa
+type Titler interface {
+	// Title recebe a primeira troca de mensagens em req.History e retorna um título curto
+	Title(ctx context.Context, req Request) (string, error)
+}
+
+// Title gera um título curto para a conversa a partir da primeira troca de
+// mensagens. Usa o backend quando ele implementa Titler e, se não implementar,
+// falhar ou responder vazio, recorre a HeuristicTitle.
+func Title(ctx context.Context, b Backend, req Request) string {
+	if t, ok := b.(Titler); ok {
+		if title, err := t.Title(ctx, req); err == nil {
+			if title = cleanTitle(title); title != "" {
+				return title
+			}
+		}
+	}
+	return HeuristicTitle(req.Message)
+}
+
+// HeuristicTitle monta um título a partir da primeira frase da mensagem do usuário
+func HeuristicTitle(message string) string {
+	message = strings.TrimSpace(message)
+	if i := strings.IndexAny(message, ".?!\n"); i > 0 {
+		message = message[:i]
+	}
+	return cleanTitle(message)
+}
+
+// cleanTitle usa apenas a primeira linha, remove aspas e marcação, normaliza os
+// espaços e limita o tamanho sem cortar palavras
+func cleanTitle(title string) string {
+	for _, line := range strings.Split(title, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			title = line
+			break
+		}
+	}
+
+	title = strings.TrimLeft(title, "#*-> ")
+	title = strings.TrimPrefix(title, "Título:")
+	title = strings.Trim(title, " \t\"'`*“”‘’")
+	title = strings.Join(strings.Fields(title), " ")
+
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		runes := []rune(title)[:maxTitleLength]
+		cut := strings.LastIndexFunc(string(runes), unicode.IsSpace)
+		if cut <= 0 {
+			cut = len(string(runes))
+		}
+		title = strings.TrimRight(string(runes)[:cut], " ,;:-") + "…"
+	}
+
+	// Primeira letra maiúscula
+	if r, size := utf8.DecodeRuneInString(title); size > 0 {
+		title = string(unicode.ToUpper(r)) + title[size:]
+	}
+	return title
+}
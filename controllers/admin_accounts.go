@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DisableUser godoc
+// @Summary      Desativar conta
+// @Description  Desativa a conta: o login é recusado e os tokens e chaves de API existentes passam a receber
+// @Description  403 "Account disabled" até a conta ser reativada. As sessões são encerradas.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                     true  "ID do usuário"
+// @Param        request  body      models.DisableUserRequest  true  "Motivo"
+// @Success      200      {object}  models.AdminUser
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /admin/users/{id}/disable [post]
+func (adc *AdminController) DisableUser(c *gin.Context) {
+	var req models.DisableUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Param("id") == c.GetString("user_id") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Não é possível desativar a própria conta"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	user, ok := adc.updateUser(ctx, c, func(id string) (*models.User, error) {
+		return adc.users.Disable(ctx, id, req.Reason, now)
+	})
+	if !ok {
+		return
+	}
+
+	// As sessões revogadas com este motivo respondem "Account disabled" enquanto a conta estiver desativada
+	revokeSessions(ctx, adc.sessions, adc.options.Connections, repository.SessionFilter{UserID: user.ID}, models.RevokeReasonAccountDisabled)
+	slog.InfoContext(ctx, "Conta desativada", "target_user_id", user.ID)
+	adc.audit(ctx, c, models.AuditDisableUser, models.AuditTargetUser, user.ID, map[string]interface{}{"reason": req.Reason})
+
+	c.JSON(http.StatusOK, models.NewAdminUser(user))
+}
+
+// EnableUser godoc
+// @Summary      Reativar conta
+// @Description  Reativa uma conta desativada. O usuário precisa fazer login novamente.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "ID do usuário"
+// @Success      200  {object}  models.AdminUser
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/users/{id}/enable [post]
+func (adc *AdminController) EnableUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	user, ok := adc.updateUser(ctx, c, func(id string) (*models.User, error) {
+		return adc.users.Enable(ctx, id)
+	})
+	if !ok {
+		return
+	}
+
+	slog.InfoContext(ctx, "Conta reativada", "target_user_id", user.ID)
+	adc.audit(ctx, c, models.AuditEnableUser, models.AuditTargetUser, user.ID, nil)
+
+	c.JSON(http.StatusOK, models.NewAdminUser(user))
+}
+
+// ForceLogout godoc
+// @Summary      Encerrar sessões do usuário
+// @Description  Revoga todas as sessões do usuário; os tokens emitidos deixam de ser aceitos.
+// @Description  Chaves de API não são afetadas.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "ID do usuário"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/users/{id}/logout [post]
+func (adc *AdminController) ForceLogout(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	user, ok := adc.findUser(ctx, c)
+	if !ok {
+		return
+	}
+
+	revoked := revokeSessions(ctx, adc.sessions, adc.options.Connections, repository.SessionFilter{UserID: user.ID}, models.RevokeReasonAdminLogout)
+	adc.audit(ctx, c, models.AuditForceLogout, models.AuditTargetUser, user.ID, map[string]interface{}{"sessions": revoked})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessões encerradas", "revoked_sessions": revoked})
+}
+
+// DeleteUser godoc
+// @Summary      Excluir conta
+// @Description  Remove imediatamente a conta com suas sessões, chaves de API, conversas e mensagens,
+// @Description  sem prazo de carência. Não pode ser desfeito.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "ID do usuário"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/users/{id} [delete]
+func (adc *AdminController) DeleteUser(c *gin.Context) {
+	if c.Param("id") == c.GetString("user_id") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Use DELETE /profile para excluir a própria conta"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), accountPurgeTimeout)
+	defer cancel()
+
+	user, ok := adc.findUser(ctx, c)
+	if !ok {
+		return
+	}
+
+	if err := adc.options.Accounts.PurgeAccount(ctx, user.ID); err != nil {
+		slog.ErrorContext(ctx, "Erro ao excluir conta", "target_user_id", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir conta"})
+		return
+	}
+
+	slog.InfoContext(ctx, "Conta excluída", "target_user_id", user.ID)
+	adc.audit(ctx, c, models.AuditDeleteUser, models.AuditTargetUser, user.ID, map[string]interface{}{"email": user.Email})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conta excluída"})
+}
+
+// DeleteUserConversations godoc
+// @Summary      Excluir conversas do usuário
+// @Description  Remove todas as conversas e mensagens do usuário, mantendo a conta
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "ID do usuário"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/users/{id}/conversations [delete]
+func (adc *AdminController) DeleteUserConversations(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(requestContext(c), accountPurgeTimeout)
+	defer cancel()
+
+	user, ok := adc.findUser(ctx, c)
+	if !ok {
+		return
+	}
+
+	if err := adc.options.Conversations.DeleteUserConversations(ctx, user.ID); err != nil {
+		slog.ErrorContext(ctx, "Erro ao excluir conversas do usuário", "target_user_id", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir conversas"})
+		return
+	}
+
+	adc.audit(ctx, c, models.AuditDeleteUserConversations, models.AuditTargetUser, user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversas excluídas"})
+}
+
+// DeleteConversation godoc
+// @Summary      Excluir conversa
+// @Description  Remove uma conversa de qualquer usuário e suas mensagens, com as mesmas regras de
+// @Description  DELETE /api/v1/conversations/{id} para bifurcações
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "ID da conversa"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/conversations/{id} [delete]
+func (adc *AdminController) DeleteConversation(c *gin.Context) {
+	conversationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de conversa inválido"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 30*time.Second)
+	defer cancel()
+
+	if err := adc.options.Conversations.DeleteConversationByID(ctx, conversationID); err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversa não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar conversa"})
+		return
+	}
+
+	adc.audit(ctx, c, models.AuditDeleteConversation, models.AuditTargetConversation, conversationID.Hex(), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversa deletada com sucesso"})
+}
+
+// updateUser aplica update ao usuário do parâmetro "id" e retorna o documento
+// atualizado, respondendo a requisição quando não existe
+func (adc *AdminController) updateUser(ctx context.Context, c *gin.Context, update func(id string) (*models.User, error)) (*models.User, bool) {
+	if _, err := primitive.ObjectIDFromHex(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuário inválido"})
+		return nil, false
+	}
+
+	user, err := update(c.Param("id"))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar usuário"})
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditLog godoc
+// @Summary      Trilha de auditoria
+// @Description  Lista as ações administrativas (mais recentes primeiro), paginadas por cursor.
+// @Description  Consultar a trilha não gera registros.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        actor_id   query     string  false  "ID de quem executou a ação"
+// @Param        target_id  query     string  false  "ID do usuário ou da conversa afetada"
+// @Param        action     query     string  false  "Ação (ex.: disable_user)"
+// @Param        limit      query     int     false  "Registros por página (padrão 20, máximo 100)"
+// @Param        cursor     query     string  false  "Cursor retornado em nextCursor"
+// @Success      200        {object}  map[string]interface{}
+// @Failure      400        {object}  map[string]string
+// @Failure      401        {object}  map[string]string
+// @Failure      403        {object}  map[string]string
+// @Failure      500        {object}  map[string]string
+// @Router       /admin/audit [get]
+func (adc *AdminController) ListAuditLog(c *gin.Context) {
+	limit, err := parseLimit(c, defaultAdminLimit, maxAdminLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	after, err := parseObjectIDQuery(c, "cursor")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := repository.AuditFilter{
+		ActorID:  c.Query("actor_id"),
+		TargetID: c.Query("target_id"),
+		Action:   c.Query("action"),
+		Before:   after,
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	entries, err := adc.auditLog.List(ctx, filter, limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar auditoria"})
+		return
+	}
+
+	var nextCursor *string
+	if int64(len(entries)) > limit {
+		entries = entries[:limit]
+		next := entries[len(entries)-1].ID.Hex()
+		nextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":    entries,
+		"nextCursor": nextCursor,
+	})
+}
+
+// audit registra uma ação administrativa de quem fez a requisição. Uma falha ao
+// gravar não desfaz a ação: fica no log do servidor.
+func (adc *AdminController) audit(ctx context.Context, c *gin.Context, action, targetType, targetID string, details map[string]interface{}) {
+	entry := models.AuditEntry{
+		ActorID:    c.GetString("user_id"),
+		ActorEmail: c.GetString("email"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		IP:         c.ClientIP(),
+		CreatedAt:  time.Now(),
+	}
+	if len(entry.Details) == 0 {
+		entry.Details = nil
+	}
+
+	if err := adc.auditLog.Create(ctx, &entry); err != nil {
+		slog.ErrorContext(ctx, "Erro ao gravar auditoria", "action", action, "target_type", targetType, "target_id", targetID, "error", err)
+	}
+}
@@ -0,0 +1,442 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AdminConversationDeleter remove conversas de qualquer usuário
+type AdminConversationDeleter interface {
+	DeleteConversationByID(ctx context.Context, conversationID primitive.ObjectID) error
+	DeleteUserConversations(ctx context.Context, userID string) error
+}
+
+// AccountPurger remove definitivamente uma conta e todos os seus dados
+type AccountPurger interface {
+	PurgeAccount(ctx context.Context, userID string) error
+}
+
+// AdminOptions configura as operações destrutivas das rotas administrativas
+type AdminOptions struct {
+	Conversations AdminConversationDeleter // Exclusão de conversas (respeitando bifurcações)
+	Accounts      AccountPurger            // Exclusão imediata de contas
+	Connections   *Connections             // Conexões WebSocket encerradas com as sessões revogadas
+}
+
+// AdminController atende as rotas administrativas (/admin). O acesso é
+// controlado por papel no roteador (middleware.RequireRole), não nos handlers.
+// Toda ação é registrada na trilha de auditoria (repository.AuditRepository).
+type AdminController struct {
+	users         repository.UserRepository
+	conversations repository.ConversationRepository
+	messages      repository.MessageRepository
+	sessions      repository.SessionRepository
+	apiKeys       repository.APIKeyRepository
+	auditLog      repository.AuditRepository
+	options       AdminOptions
+}
+
+func NewAdminController(repos repository.Repositories, options AdminOptions) *AdminController {
+	return &AdminController{
+		users:         repos.Users,
+		conversations: repos.Conversations,
+		messages:      repos.Messages,
+		sessions:      repos.Sessions,
+		apiKeys:       repos.APIKeys,
+		auditLog:      repos.Audit,
+		options:       options,
+	}
+}
+
+// ListUsers godoc
+// @Summary      Listar usuários
+// @Description  Lista e busca contas (mais recentes primeiro), paginadas por cursor. "q" busca no email e no nome.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        q         query     string  false  "Trecho do email ou do nome"
+// @Param        role      query     string  false  "Filtrar por papel (user, support, admin)"
+// @Param        disabled  query     bool    false  "Somente contas desativadas"
+// @Param        limit     query     int     false  "Usuários por página (padrão 20, máximo 100)"
+// @Param        cursor    query     string  false  "Cursor retornado em nextCursor"
+// @Success      200       {object}  map[string]interface{}
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      403       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /admin/users [get]
+func (adc *AdminController) ListUsers(c *gin.Context) {
+	limit, err := parseLimit(c, defaultAdminLimit, maxAdminLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	after, err := parseObjectIDQuery(c, "cursor")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := repository.UserFilter{}
+	details := map[string]interface{}{}
+	if q := c.Query("q"); q != "" {
+		filter.Query = q
+		details["q"] = q
+	}
+	if role := models.UserRole(c.Query("role")); role != "" {
+		switch role {
+		case models.UserRoleUser, models.UserRoleSupport, models.UserRoleAdmin:
+			filter.Role = role
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Papel inválido"})
+			return
+		}
+		details["role"] = role
+	}
+	if c.Query("disabled") == "true" {
+		filter.Disabled = true
+		details["disabled"] = true
+	}
+	if after != nil {
+		filter.Before = after.Hex()
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	users, err := adc.users.List(ctx, filter, limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuários"})
+		return
+	}
+
+	var nextCursor *string
+	if int64(len(users)) > limit {
+		users = users[:limit]
+		next := users[len(users)-1].ID
+		nextCursor = &next
+	}
+
+	result := make([]models.AdminUser, 0, len(users))
+	for i := range users {
+		result = append(result, models.NewAdminUser(&users[i]))
+	}
+
+	adc.audit(ctx, c, models.AuditSearchUsers, models.AuditTargetUser, "", details)
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":      result,
+		"nextCursor": nextCursor,
+	})
+}
+
+// GetUser godoc
+// @Summary      Detalhar usuário
+// @Description  Retorna a conta com o número de sessões e chaves de API ativas
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "ID do usuário"
+// @Success      200  {object}  models.AdminUserDetail
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/users/{id} [get]
+func (adc *AdminController) GetUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	user, ok := adc.findUser(ctx, c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	sessions, err := adc.sessions.CountActive(ctx, user.ID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao contar sessões"})
+		return
+	}
+	apiKeys, err := adc.apiKeys.CountActive(ctx, user.ID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao contar chaves de API"})
+		return
+	}
+
+	adc.audit(ctx, c, models.AuditViewUser, models.AuditTargetUser, user.ID, nil)
+
+	c.JSON(http.StatusOK, models.AdminUserDetail{
+		AdminUser:      models.NewAdminUser(user),
+		ActiveSessions: sessions,
+		ActiveAPIKeys:  apiKeys,
+	})
+}
+
+// ListUserConversations godoc
+// @Summary      Listar conversas de um usuário
+// @Description  Lista as conversas do usuário, como em /api/v1/conversations
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true   "ID do usuário"
+// @Param        limit   query     int     false  "Conversas por página (padrão 20, máximo 100)"
+// @Param        cursor  query     string  false  "Cursor retornado em nextCursor"
+// @Success      200     {object}  map[string]interface{}
+// @Failure      400     {object}  map[string]string
+// @Failure      401     {object}  map[string]string
+// @Failure      403     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /admin/users/{id}/conversations [get]
+func (adc *AdminController) ListUserConversations(c *gin.Context) {
+	userID := c.Param("id")
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuário inválido"})
+		return
+	}
+	limit, err := parseLimit(c, defaultConversationsLimit, maxConversationsLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var after *repository.ConversationCursor
+	if value := c.Query("cursor"); value != "" {
+		if after, err = decodeConversationCursor(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	conversations, err := adc.conversations.List(ctx, userID, after, limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar conversas"})
+		return
+	}
+
+	var nextCursor *string
+	if int64(len(conversations)) > limit {
+		conversations = conversations[:limit]
+		next := encodeConversationCursor(&conversations[len(conversations)-1])
+		nextCursor = &next
+	}
+
+	total, err := adc.conversations.CountByUser(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao contar conversas"})
+		return
+	}
+
+	adc.audit(ctx, c, models.AuditListUserConversations, models.AuditTargetUser, userID, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversations": conversations,
+		"total":         total,
+		"nextCursor":    nextCursor,
+	})
+}
+
+// GetConversation godoc
+// @Summary      Ver conversa de um usuário
+// @Description  Retorna a conversa e suas mensagens em ordem cronológica, paginadas por ID de mensagem.
+// @Description  Em conversas bifurcadas, o histórico herdado está na conversa de origem (parentConversationId).
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true   "ID da conversa"
+// @Param        limit   query     int     false  "Mensagens por página (padrão 50, máximo 200)"
+// @Param        before  query     string  false  "Retorna mensagens anteriores a este ID"
+// @Success      200     {object}  map[string]interface{}
+// @Failure      400     {object}  map[string]string
+// @Failure      401     {object}  map[string]string
+// @Failure      403     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /admin/conversations/{id} [get]
+func (adc *AdminController) GetConversation(c *gin.Context) {
+	conversationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de conversa inválido"})
+		return
+	}
+	limit, err := parseLimit(c, defaultMessagesLimit, maxMessagesLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	before, err := parseObjectIDQuery(c, "before")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	conversation, err := adc.conversations.FindByID(ctx, conversationID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversa não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar conversa"})
+		return
+	}
+
+	// Apenas as mensagens da própria conversa, mais antigas primeiro; um item a
+	// mais (o mais antigo) indica se existe página anterior
+	messages, err := adc.messages.List(ctx,
+		[]repository.Segment{{ConversationID: conversationID}},
+		repository.MessagePage{Limit: limit + 1, Before: before},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar mensagens"})
+		return
+	}
+
+	hasMore := int64(len(messages)) > limit
+	if hasMore {
+		messages = messages[1:]
+	}
+	var nextCursor *string
+	if hasMore {
+		next := messages[0].ID.Hex()
+		nextCursor = &next
+	}
+
+	adc.audit(ctx, c, models.AuditViewConversation, models.AuditTargetConversation, conversationID.Hex(),
+		map[string]interface{}{"user_id": conversation.UserID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation": conversation,
+		"messages":     messages,
+		"hasMore":      hasMore,
+		"nextCursor":   nextCursor,
+	})
+}
+
+// UpdateUserRole godoc
+// @Summary      Alterar papel do usuário
+// @Description  Define o papel (user, support, admin) de um usuário. As sessões dele são encerradas para que
+// @Description  o novo papel valha imediatamente. Um administrador não pode alterar o próprio papel.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                    true  "ID do usuário"
+// @Param        request  body      models.UpdateRoleRequest  true  "Novo papel"
+// @Success      200      {object}  map[string]string
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /admin/users/{id}/role [put]
+func (adc *AdminController) UpdateUserRole(c *gin.Context) {
+	userID := c.Param("id")
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuário inválido"})
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Evita que o último administrador perca o acesso por engano
+	if userID == c.GetString("user_id") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Não é possível alterar o próprio papel"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	previous, err := adc.users.SetRole(ctx, userID, req.Role)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar usuário"})
+		return
+	}
+
+	// Os access tokens carregam o papel: as sessões são encerradas para aplicar a mudança
+	if previous.EffectiveRole() != req.Role {
+		revokeSessions(ctx, adc.sessions, adc.options.Connections, repository.SessionFilter{UserID: userID}, models.RevokeReasonRoleChange)
+		slog.InfoContext(ctx, "Papel do usuário alterado", "target_user_id", userID, "previous_role", previous.EffectiveRole(), "role", req.Role)
+	}
+
+	adc.audit(ctx, c, models.AuditUpdateRole, models.AuditTargetUser, userID,
+		map[string]interface{}{"from": previous.EffectiveRole(), "to": req.Role})
+
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": string(req.Role)})
+}
+
+// GrantAdminRole promove a administrador as contas existentes com os emails
+// informados (ADMIN_EMAILS), para criar o primeiro administrador. Só contas com
+// o email verificado são promovidas: quem cadastrasse o endereço antes do dono
+// se tornaria administrador sem provar que o controla.
+func (adc *AdminController) GrantAdminRole(ctx context.Context, emails []string) error {
+	if len(emails) == 0 {
+		return nil
+	}
+
+	promoted, err := adc.users.GrantAdmin(ctx, emails)
+	if err != nil {
+		return err
+	}
+	if promoted > 0 {
+		slog.InfoContext(ctx, "Contas promovidas a administrador via ADMIN_EMAILS", "count", promoted)
+	}
+
+	for _, email := range emails {
+		if email == "" {
+			continue
+		}
+		user, err := adc.users.FindByEmail(ctx, email)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if user == nil || !user.EmailVerified {
+			slog.WarnContext(ctx, "Email de ADMIN_EMAILS sem conta verificada", "email", email)
+		}
+	}
+	return nil
+}
+
+// findUser busca o usuário do parâmetro "id", respondendo a requisição quando não existe
+func (adc *AdminController) findUser(ctx context.Context, c *gin.Context) (*models.User, bool) {
+	if _, err := primitive.ObjectIDFromHex(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuário inválido"})
+		return nil, false
+	}
+
+	user, err := adc.users.FindByID(ctx, c.Param("id"))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuário"})
+		return nil, false
+	}
+	return user, true
+}
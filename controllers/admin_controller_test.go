@@ -0,0 +1,43 @@
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chatserver/controllers"
+	"chatserver/models"
+)
+
+func TestGrantAdminRoleRequiresVerifiedEmail(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	// Conta cadastrada por outra pessoa, sem provar que controla o endereço
+	s.register(t, "admin@example.com", "secret123")
+	verified := &models.User{Email: "dona@example.com", Password: "secret123", EmailVerified: true, CreatedAt: time.Now()}
+	if err := verified.HashPassword(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repos.Users.Create(ctx, verified); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := controllers.NewAdminController(s.repos, controllers.AdminOptions{})
+	if err := admin.GrantAdminRole(ctx, []string{"admin@example.com", "dona@example.com", "ninguem@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for email, want := range map[string]models.UserRole{
+		"admin@example.com": models.UserRoleUser,
+		"dona@example.com":  models.UserRoleAdmin,
+	} {
+		user, err := s.repos.Users.FindByEmail(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.EffectiveRole() != want {
+			t.Errorf("%s: papel %q, esperado %q", email, user.EffectiveRole(), want)
+		}
+	}
+}
@@ -2,28 +2,69 @@ package controllers
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"chatserver/keys"
+	"chatserver/mailer"
 	"chatserver/metrics"
 	"chatserver/models"
+	"chatserver/oidc"
+	"chatserver/repository"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var jwtSecret = []byte("your-secret-key-change-this-in-production") // Change this to env variable in production
+// AuthOptions configures token lifetimes
+type AuthOptions struct {
+	Keys            *keys.KeySet  // Keys used to sign and verify the access tokens
+	AccessTokenTTL  time.Duration // Lifetime of the JWT access tokens
+	RefreshTokenTTL time.Duration // Refresh tokens expire after this long without being used
+
+	Mailer           mailer.Mailer // Sends the password reset and email verification emails
+	AppURL           string        // Base URL of the web app, used in the links sent by email
+	APIURL           string        // Public base URL of this API, used in the email verification links
+	PasswordResetTTL time.Duration // Lifetime of the password reset tokens
+
+	PasswordResetInterval  time.Duration // Minimum interval between two reset emails to the same address (0 = no limit)
+	MaxPasswordResetsPerIP int           // Reset requests from a client IP per passwordResetIPWindow (0 = no limit)
+
+	EmailVerificationTTL time.Duration // Lifetime of the email verification tokens
+	VerificationResend   time.Duration // Minimum interval between two verification emails to the same account
+
+	MaxLoginFailures      int           // Failed logins of an email before it is locked (0 disables the protection)
+	MaxLoginFailuresPerIP int           // Failed logins from a client IP before it is blocked (0 = no IP limit)
+	LoginFailureWindow    time.Duration // Failures are forgotten after this long without a new one
+	LoginLockout          time.Duration // How long a locked email or blocked IP stays refused
+	LoginDelay            time.Duration // Wait imposed after a failed login, doubled at each new failure
+
+	MFAIssuer string // Account issuer shown by the authenticator apps
+
+	OIDC                     *oidc.Provider // Identity provider for /auth/oidc/* (nil disables the OIDC login)
+	OIDCAllowUnverifiedEmail bool           // Link and create accounts from emails the provider did not verify
+
+	Connections *Connections // WebSocket connections closed when their session or API key is revoked
+}
 
 type AuthController struct {
-	userCollection *mongo.Collection
+	users         repository.UserRepository
+	sessions      repository.SessionRepository
+	apiKeys       repository.APIKeyRepository
+	loginAttempts repository.LoginAttemptRepository
+	oidcStates    repository.OIDCStateRepository
+	options       AuthOptions
 }
 
-func NewAuthController(db *mongo.Database) *AuthController {
+func NewAuthController(repos repository.Repositories, options AuthOptions) *AuthController {
 	return &AuthController{
-		userCollection: db.Collection("users"),
+		users:         repos.Users,
+		sessions:      repos.Sessions,
+		apiKeys:       repos.APIKeys,
+		loginAttempts: repos.LoginAttempts,
+		oidcStates:    repos.OIDCStates,
+		options:       options,
 	}
 }
 
@@ -48,12 +89,11 @@ func (ac *AuthController) Register(c *gin.Context) {
 	}
 
 	// Check if user already exists
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
 	defer cancel()
 
 	start := time.Now()
-	var existingUser models.User
-	err := ac.userCollection.FindOne(ctx, bson.M{"email": req.Email}).Decode(&existingUser)
+	_, err := ac.users.FindByEmail(ctx, req.Email)
 	metrics.RecordDatabaseOperation("find", "users", "success", time.Since(start).Seconds())
 
 	if err == nil {
@@ -62,13 +102,25 @@ func (ac *AuthController) Register(c *gin.Context) {
 		return
 	}
 
+	// Verification token, sent by email once the user is created
+	verificationToken, err := generateOpaqueToken()
+	if err != nil {
+		metrics.RecordAuthAttempt("register", "failure")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
 	// Create new user
 	now := time.Now()
+	verificationExpiresAt := now.Add(ac.options.EmailVerificationTTL)
 	user := models.User{
-		Email:     req.Email,
-		Password:  req.Password,
-		CreatedAt: now,
-		UpdatedAt: now,
+		Email:                      req.Email,
+		Password:                   req.Password,
+		CreatedAt:                  now,
+		UpdatedAt:                  now,
+		EmailVerificationTokenHash: hashToken(verificationToken),
+		EmailVerificationExpiresAt: &verificationExpiresAt,
+		EmailVerificationSentAt:    &now,
 	}
 
 	// Hash password
@@ -80,20 +132,23 @@ func (ac *AuthController) Register(c *gin.Context) {
 
 	// Insert user into database
 	start = time.Now()
-	result, err := ac.userCollection.InsertOne(ctx, user)
-	if err != nil {
+	if err := ac.users.Create(ctx, &user); err != nil {
 		metrics.RecordDatabaseOperation("insert", "users", "failure", time.Since(start).Seconds())
 		metrics.RecordAuthAttempt("register", "failure")
+		// Another registration with the same email won the race
+		if err == repository.ErrDuplicate {
+			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 	metrics.RecordDatabaseOperation("insert", "users", "success", time.Since(start).Seconds())
 
-	// Get the inserted ID
-	insertedID := result.InsertedID.(primitive.ObjectID).Hex()
+	ac.sendVerificationEmail(&user, verificationToken)
 
-	// Generate JWT token
-	token, err := generateToken(req.Email, insertedID)
+	// Start a session and generate the tokens
+	response, err := ac.issueTokens(ctx, c, &user, models.LoginMethodPassword)
 	if err != nil {
 		metrics.RecordAuthAttempt("register", "failure")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
@@ -104,17 +159,14 @@ func (ac *AuthController) Register(c *gin.Context) {
 	metrics.RecordAuthAttempt("register", "success")
 	metrics.RecordTokenIssued()
 
-	c.JSON(http.StatusCreated, models.AuthResponse{
-		Token:     token,
-		Email:     req.Email,
-		UserID:    insertedID,
-		CreatedAt: user.CreatedAt,
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
 // Login godoc
 // @Summary      Login de usuário
-// @Description  Autentica um usuário e retorna token JWT (válido por 24 horas)
+// @Description  Autentica um usuário e retorna um access token JWT de curta duração e um refresh token.
+// @Description  Com MFA ativo, retorna um models.MFAChallengeResponse ("mfa_required": true) cujo token é
+// @Description  trocado por um código em /auth/mfa/verify.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -122,6 +174,8 @@ func (ac *AuthController) Register(c *gin.Context) {
 // @Success      200      {object}  models.AuthResponse
 // @Failure      400      {object}  map[string]string
 // @Failure      401      {object}  map[string]string
+// @Failure      423      {object}  map[string]string
+// @Failure      429      {object}  map[string]string
 // @Failure      500      {object}  map[string]string
 // @Router       /auth/login [post]
 func (ac *AuthController) Login(c *gin.Context) {
@@ -132,18 +186,25 @@ func (ac *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	// Find user by email
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
 	defer cancel()
 
+	// Brute-force protection, checked before spending a bcrypt comparison
+	if block := ac.checkLoginAllowed(ctx, req.Email, c.ClientIP()); block != nil {
+		block.respond(c)
+		return
+	}
+
+	// Find user by email
 	start := time.Now()
-	var user models.User
-	err := ac.userCollection.FindOne(ctx, bson.M{"email": req.Email}).Decode(&user)
+	user, err := ac.users.FindByEmail(ctx, req.Email)
 
 	if err != nil {
 		metrics.RecordDatabaseOperation("find", "users", "failure", time.Since(start).Seconds())
 		metrics.RecordAuthAttempt("login", "failure")
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
+			// Unknown emails count too, so locking does not reveal which accounts exist
+			ac.recordLoginFailure(ctx, req.Email, c.ClientIP())
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
 		}
@@ -154,13 +215,33 @@ func (ac *AuthController) Login(c *gin.Context) {
 
 	// Check password
 	if err := user.CheckPassword(req.Password); err != nil {
+		metrics.RecordAuthAttempt("login", "failure")
+		ac.recordLoginFailure(ctx, req.Email, c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
+	// Only revealed to whoever knows the password
+	if rejectDisabledAccount(c, user, "login") {
+		return
+	}
+
+	// Logging in during the grace period cancels a scheduled account deletion
+	if user.DeletionScheduledFor != nil && !ac.cancelAccountDeletion(ctx, user) {
 		metrics.RecordAuthAttempt("login", "failure")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	// Generate JWT token
-	token, err := generateToken(user.Email, user.ID)
+	// With MFA the failures are only cleared once the second factor is verified
+	if user.MFAEnabled {
+		ac.respondMFAChallenge(c, user, "login", models.LoginMethodPassword)
+		return
+	}
+	ac.clearLoginFailures(ctx, req.Email)
+
+	// Start a session and generate the tokens
+	response, err := ac.issueTokens(ctx, c, user, models.LoginMethodPassword)
 	if err != nil {
 		metrics.RecordAuthAttempt("login", "failure")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
@@ -171,36 +252,41 @@ func (ac *AuthController) Login(c *gin.Context) {
 	metrics.RecordAuthAttempt("login", "success")
 	metrics.RecordTokenIssued()
 
-	c.JSON(http.StatusOK, models.AuthResponse{
-		Token:     token,
-		Email:     user.Email,
-		UserID:    user.ID,
-		CreatedAt: user.CreatedAt,
-	})
+	c.JSON(http.StatusOK, response)
+}
+
+// cancelAccountDeletion restores an account scheduled for deletion. It fails
+// once the grace period is over, when the account may already be being purged.
+func (ac *AuthController) cancelAccountDeletion(ctx context.Context, user *models.User) bool {
+	canceled, err := ac.users.CancelDeletion(ctx, user.ID)
+	if err != nil || !canceled {
+		return false
+	}
+	slog.InfoContext(ctx, "Account deletion canceled", "user_id", user.ID)
+	return true
 }
 
-// generateToken generates a JWT token with 1 day expiration
-func generateToken(email, userID string) (string, error) {
+// generateToken generates a JWT access token bound to a session
+func (ac *AuthController) generateToken(user *models.User, sessionID string) (string, error) {
 	claims := models.Claims{
-		Email:  email,
-		UserID: userID,
+		Email:         user.Email,
+		UserID:        user.ID,
+		SessionID:     sessionID,
+		EmailVerified: user.EmailVerified,
+		Role:          user.EffectiveRole(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 1 day expiration
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ac.options.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return ac.options.Keys.Sign(claims)
 }
 
-// ValidateToken validates a JWT token
-func ValidateToken(tokenString string) (*models.Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-
+// validateToken validates a JWT token signature and expiration
+func (ac *AuthController) validateToken(tokenString string) (*models.Claims, error) {
+	token, err := ac.options.Keys.Parse(tokenString, &models.Claims{})
 	if err != nil {
 		return nil, err
 	}
@@ -211,3 +297,16 @@ func ValidateToken(tokenString string) (*models.Claims, error) {
 
 	return nil, jwt.ErrSignatureInvalid
 }
+
+// JWKS godoc
+// @Summary      Chaves públicas (JWKS)
+// @Description  Publica as chaves públicas (RS256/ES256) usadas para assinar os tokens, identificadas pelo "kid".
+// @Description  Chaves HMAC (HS256) nunca são publicadas.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  keys.JWKSet
+// @Router       /.well-known/jwks.json [get]
+func (ac *AuthController) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, ac.options.Keys.JWKS())
+}
@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"chatserver/metrics"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// maxAPIKeysPerUser bounds the active keys of an account
+	maxAPIKeysPerUser = 25
+	// apiKeyLastUsedResolution limits the writes of last_used_at to one per key per minute
+	apiKeyLastUsedResolution = time.Minute
+	// apiKeyPrefixLength is how much of the key is stored in clear to recognize it
+	apiKeyPrefixLength = len(models.APIKeyPrefix) + 8
+)
+
+var (
+	// ErrAPIKeyRevoked is returned by Authenticate for unknown or revoked API keys
+	ErrAPIKeyRevoked = errors.New("API key revoked")
+	// ErrAPIKeyExpired is returned by Authenticate for API keys past their expiration
+	ErrAPIKeyExpired = errors.New("API key expired")
+)
+
+// CreateAPIKey godoc
+// @Summary      Criar chave de API
+// @Description  Cria uma chave de API pessoal para integrações (cron jobs, bots), usada no header
+// @Description  "Authorization: Bearer srk_..." no lugar do JWT. A chave é exibida apenas nesta resposta.
+// @Description  Sem escopos, a chave recebe todos (chat:read, chat:write); sem expires_in_days, não expira.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateAPIKeyRequest  true  "Nome, escopos e validade"
+// @Success      201      {object}  models.CreateAPIKeyResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /auth/api-keys [post]
+func (ac *AuthController) CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	userID := c.GetString("user_id")
+	now := time.Now()
+	active, err := ac.apiKeys.CountActive(ctx, userID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if active >= maxAPIKeysPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "API key limit reached, revoke an unused key first"})
+		return
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate key"})
+		return
+	}
+	key := models.APIKeyPrefix + secret
+
+	apiKey := models.APIKey{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    key[:apiKeyPrefixLength],
+		KeyHash:   hashToken(key),
+		Scopes:    uniqueScopes(req.Scopes),
+		CreatedAt: now,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	start := time.Now()
+	if err := ac.apiKeys.Create(ctx, &apiKey); err != nil {
+		metrics.RecordDatabaseOperation("insert", "api_keys", "failure", time.Since(start).Seconds())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create key"})
+		return
+	}
+	metrics.RecordDatabaseOperation("insert", "api_keys", "success", time.Since(start).Seconds())
+	slog.InfoContext(ctx, "API key created", "api_key_id", apiKey.ID.Hex())
+
+	c.JSON(http.StatusCreated, models.CreateAPIKeyResponse{Key: key, APIKey: apiKey})
+}
+
+// ListAPIKeys godoc
+// @Summary      Listar chaves de API
+// @Description  Lista as chaves de API ativas do usuário (sem o valor da chave), com último uso e validade
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.APIKeyListResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/api-keys [get]
+func (ac *AuthController) ListAPIKeys(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	apiKeys, err := ac.apiKeys.ListActive(ctx, c.GetString("user_id"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIKeyListResponse{APIKeys: apiKeys})
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revogar chave de API
+// @Description  Revoga uma chave de API do usuário; ela deixa de ser aceita imediatamente
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "ID da chave"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/api-keys/{id} [delete]
+func (ac *AuthController) RevokeAPIKey(c *gin.Context) {
+	keyID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	err = ac.apiKeys.Revoke(ctx, keyID, c.GetString("user_id"), time.Now())
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	ac.options.Connections.closeAPIKeys(c.GetString("user_id"), keyID.Hex())
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// authenticateAPIKey validates a personal API key and returns the claims of its
+// owner, restricted to the scopes of the key
+func (ac *AuthController) authenticateAPIKey(ctx context.Context, key string) (*models.Claims, error) {
+	start := time.Now()
+	apiKey, err := ac.apiKeys.FindByHash(ctx, hashToken(key))
+	if err != nil && err != repository.ErrNotFound {
+		metrics.RecordDatabaseOperation("find", "api_keys", "failure", time.Since(start).Seconds())
+		return nil, err
+	}
+	metrics.RecordDatabaseOperation("find", "api_keys", "success", time.Since(start).Seconds())
+
+	now := time.Now()
+	if err == repository.ErrNotFound || apiKey.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if apiKey.ExpiresAt != nil && !now.Before(*apiKey.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	// Keys of accounts scheduled for deletion are revoked; this also covers a failed revocation
+	user, err := ac.findUserByID(ctx, apiKey.UserID)
+	if err == repository.ErrNotFound || (err == nil && user.DeletionScheduledFor != nil) {
+		return nil, ErrAPIKeyRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyLastUsedResolution {
+		if err := ac.apiKeys.TouchLastUsed(ctx, apiKey.ID, now); err != nil {
+			slog.WarnContext(ctx, "Failed to update API key last use", "api_key_id", apiKey.ID.Hex(), "error", err)
+		}
+	}
+
+	// No role: the administrative routes are never reachable with an API key
+	return &models.Claims{
+		Email:         user.Email,
+		UserID:        user.ID,
+		EmailVerified: user.EmailVerified,
+		APIKeyID:      apiKey.ID.Hex(),
+		Scopes:        apiKey.Scopes,
+	}, nil
+}
+
+// revokeAPIKeys revokes all the keys of a user, closes the WebSocket
+// connections opened with them and returns how many were revoked
+func revokeAPIKeys(ctx context.Context, apiKeys repository.APIKeyRepository, connections *Connections, userID string) int64 {
+	revoked, err := apiKeys.RevokeByUser(ctx, userID, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to revoke API keys", "user_id", userID, "error", err)
+		return 0
+	}
+	connections.closeAPIKeys(userID, "")
+	return revoked
+}
+
+// uniqueScopes removes duplicated scopes, defaulting to all of them
+func uniqueScopes(scopes []string) []string {
+	if len(scopes) == 0 {
+		return append([]string(nil), models.APIScopes...)
+	}
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatserver/metrics"
+)
+
+// maxLoginDelay caps the progressive delay between failed logins of an email
+const maxLoginDelay = 30 * time.Second
+
+// loginAttemptKeys returns the login_attempts IDs of an email and a client IP
+func loginAttemptKeys(email, ip string) (emailKey, ipKey string) {
+	return "email:" + strings.ToLower(strings.TrimSpace(email)), "ip:" + ip
+}
+
+// checkLoginAllowed refuses the attempt while the email is locked (423), the
+// client IP is blocked (429) or the progressive delay since the last failure of
+// the email has not elapsed (429). Errors reading the counters never block a login.
+func (ac *AuthController) checkLoginAllowed(ctx context.Context, email, ip string) *chatError {
+	if ac.options.MaxLoginFailures <= 0 {
+		return nil
+	}
+
+	emailKey, ipKey := loginAttemptKeys(email, ip)
+	now := time.Now()
+	attempts, err := ac.loginAttempts.FindActive(ctx, []string{emailKey, ipKey}, now)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read login attempts", "error", err)
+		return nil
+	}
+
+	for _, attempt := range attempts {
+		locked := attempt.LockedUntil != nil && attempt.LockedUntil.After(now)
+		switch {
+		case attempt.ID == ipKey && locked:
+			metrics.RecordLoginBlocked("ip_blocked")
+			return &chatError{
+				status:     http.StatusTooManyRequests,
+				message:    "Too many failed login attempts, try again later",
+				retryAfter: attempt.LockedUntil.Sub(now),
+			}
+		case attempt.ID == emailKey && locked:
+			metrics.RecordLoginBlocked("locked")
+			return &chatError{
+				status:     http.StatusLocked,
+				message:    "Account temporarily locked due to too many failed login attempts",
+				retryAfter: attempt.LockedUntil.Sub(now),
+			}
+		case attempt.ID == emailKey && attempt.Failures > 0:
+			if wait := attempt.LastFailureAt.Add(ac.loginDelay(attempt.Failures)).Sub(now); wait > 0 {
+				metrics.RecordLoginBlocked("delayed")
+				return &chatError{
+					status:     http.StatusTooManyRequests,
+					message:    "Too many failed login attempts, try again later",
+					retryAfter: wait,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed login for the email and the client IP,
+// locking them once they reach their limits
+func (ac *AuthController) recordLoginFailure(ctx context.Context, email, ip string) {
+	if ac.options.MaxLoginFailures <= 0 {
+		return
+	}
+
+	emailKey, ipKey := loginAttemptKeys(email, ip)
+	ac.countLoginFailure(ctx, emailKey, "email", ac.options.MaxLoginFailures)
+	if ac.options.MaxLoginFailuresPerIP > 0 {
+		ac.countLoginFailure(ctx, ipKey, "ip", ac.options.MaxLoginFailuresPerIP)
+	}
+}
+
+// clearLoginFailures forgets the failures of an email after a successful login.
+// The IP counter is kept: a valid account must not reset it for an attacker.
+func (ac *AuthController) clearLoginFailures(ctx context.Context, email string) {
+	if ac.options.MaxLoginFailures <= 0 {
+		return
+	}
+
+	emailKey, _ := loginAttemptKeys(email, "")
+	if err := ac.loginAttempts.Delete(ctx, emailKey); err != nil {
+		slog.WarnContext(ctx, "Failed to clear login attempts", "error", err)
+	}
+}
+
+// countLoginFailure increments a counter atomically, restarting it when the
+// previous failures are older than the window, and locks the key at maxFailures
+func (ac *AuthController) countLoginFailure(ctx context.Context, key, scope string, maxFailures int) {
+	now := time.Now()
+	attempt, err := ac.loginAttempts.RecordFailure(ctx, key, now, ac.options.LoginFailureWindow)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to record login failure", "error", err)
+		return
+	}
+	if attempt.Failures < maxFailures {
+		return
+	}
+
+	// Limit reached: lock and start counting again once the lock expires
+	lockedUntil := now.Add(ac.options.LoginLockout)
+	locked, err := ac.loginAttempts.Lock(ctx, key, attempt.Failures, lockedUntil, lockedUntil.Add(ac.options.LoginFailureWindow))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to lock login", "error", err)
+		return
+	}
+	if locked {
+		slog.WarnContext(ctx, "Login locked", "scope", scope, "failures", attempt.Failures)
+		metrics.RecordLoginLockout(scope)
+	}
+}
+
+// mfaAttemptKey returns the login_attempts ID that counts the invalid MFA codes of a user
+func mfaAttemptKey(userID string) string {
+	return "mfa:" + userID
+}
+
+// checkMFAAllowed refuses a second factor code (429) while the user or the
+// client IP is blocked after too many invalid codes or failed logins. A code has
+// only a million values, so they are guessed under the same limits as passwords.
+func (ac *AuthController) checkMFAAllowed(ctx context.Context, userID, ip string) *chatError {
+	if ac.options.MaxLoginFailures <= 0 {
+		return nil
+	}
+
+	mfaKey := mfaAttemptKey(userID)
+	_, ipKey := loginAttemptKeys("", ip)
+	now := time.Now()
+	attempts, err := ac.loginAttempts.FindActive(ctx, []string{mfaKey, ipKey}, now)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read login attempts", "error", err)
+		return nil
+	}
+
+	for _, attempt := range attempts {
+		if attempt.LockedUntil == nil || !attempt.LockedUntil.After(now) {
+			continue
+		}
+		reason := "mfa_blocked"
+		if attempt.ID == ipKey {
+			reason = "ip_blocked"
+		}
+		metrics.RecordLoginBlocked(reason)
+		return &chatError{
+			status:     http.StatusTooManyRequests,
+			message:    "Too many invalid MFA codes, try again later",
+			retryAfter: attempt.LockedUntil.Sub(now),
+		}
+	}
+	return nil
+}
+
+// recordMFAFailure counts an invalid MFA code for the user and the client IP,
+// blocking them once they reach their limits
+func (ac *AuthController) recordMFAFailure(ctx context.Context, userID, ip string) {
+	if ac.options.MaxLoginFailures <= 0 {
+		return
+	}
+
+	ac.countLoginFailure(ctx, mfaAttemptKey(userID), "mfa", ac.options.MaxLoginFailures)
+	if ac.options.MaxLoginFailuresPerIP > 0 {
+		_, ipKey := loginAttemptKeys("", ip)
+		ac.countLoginFailure(ctx, ipKey, "ip", ac.options.MaxLoginFailuresPerIP)
+	}
+}
+
+// clearMFAFailures forgets the invalid codes of a user after a valid one
+func (ac *AuthController) clearMFAFailures(ctx context.Context, userID string) {
+	if ac.options.MaxLoginFailures <= 0 {
+		return
+	}
+
+	if err := ac.loginAttempts.Delete(ctx, mfaAttemptKey(userID)); err != nil {
+		slog.WarnContext(ctx, "Failed to clear MFA attempts", "error", err)
+	}
+}
+
+// allowRequest counts a request against key and reports whether it is allowed:
+// once limit requests are made, each less than window after the previous one,
+// the key is refused for window. Errors reading the counters never refuse.
+func (ac *AuthController) allowRequest(ctx context.Context, key string, limit int, window time.Duration) bool {
+	now := time.Now()
+	attempts, err := ac.loginAttempts.FindActive(ctx, []string{key}, now)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read request counters", "error", err)
+		return true
+	}
+	for _, attempt := range attempts {
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			return false
+		}
+	}
+
+	attempt, err := ac.loginAttempts.RecordFailure(ctx, key, now, window)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to count request", "error", err)
+		return true
+	}
+	if attempt.Failures >= limit {
+		lockedUntil := now.Add(window)
+		if _, err := ac.loginAttempts.Lock(ctx, key, attempt.Failures, lockedUntil, lockedUntil); err != nil {
+			slog.WarnContext(ctx, "Failed to lock request counter", "error", err)
+		}
+	}
+	return true
+}
+
+// loginDelay returns the wait imposed after the given number of consecutive
+// failures: LoginDelay doubled at each failure, up to maxLoginDelay
+func (ac *AuthController) loginDelay(failures int) time.Duration {
+	delay := ac.options.LoginDelay
+	for i := 1; i < failures && delay < maxLoginDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxLoginDelay {
+		delay = maxLoginDelay
+	}
+	return delay
+}
@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatserver/metrics"
+	"chatserver/models"
+	"chatserver/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Two-factor authentication parameters
+const (
+	mfaChallengeTTL   = 5 * time.Minute // Lifetime of the MFA challenge token returned by login
+	mfaSkew           = 1               // Accepted clock drift, in 30s steps, each way
+	recoveryCodeCount = 10
+	recoveryCodeSize  = 10 // Random bytes per recovery code (80 bits)
+)
+
+// recoveryCodeEncoding writes recovery codes in lowercase base32
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// EnrollMFA godoc
+// @Summary      Iniciar configuração de MFA
+// @Description  Gera um segredo TOTP para o usuário autenticado e a URI otpauth:// para o app autenticador.
+// @Description  O MFA só é ativado após a confirmação com um código em /auth/mfa/confirm.
+// @Tags         mfa
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.MFAEnrollResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/mfa/enroll [post]
+func (ac *AuthController) EnrollMFA(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	user, err := ac.findUserByID(ctx, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user"})
+		return
+	}
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	// A new enrollment replaces one that was never confirmed
+	if err := ac.users.SetMFAPendingSecret(ctx, user.ID, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(ac.options.MFAIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmMFA godoc
+// @Summary      Confirmar MFA
+// @Description  Ativa o MFA com um código do app autenticador e retorna os códigos de recuperação,
+// @Description  de uso único. Os códigos de recuperação são exibidos apenas nesta resposta.
+// @Description  Após muitos códigos inválidos, novas tentativas do usuário são recusadas por um tempo (429).
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.MFACodeRequest  true  "Código TOTP"
+// @Success      200      {object}  models.MFARecoveryCodesResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      429      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /auth/mfa/confirm [post]
+func (ac *AuthController) ConfirmMFA(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	user, err := ac.findUserByID(ctx, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user"})
+		return
+	}
+	if user.MFAPendingSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No MFA enrollment in progress"})
+		return
+	}
+
+	if block := ac.checkMFAAllowed(ctx, user.ID, c.ClientIP()); block != nil {
+		block.respond(c)
+		return
+	}
+	step, ok := totp.Validate(user.MFAPendingSecret, req.Code, time.Now(), mfaSkew)
+	if !ok {
+		metrics.RecordAuthAttempt("mfa_confirm", "failure")
+		ac.recordMFAFailure(ctx, user.ID, c.ClientIP())
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+	ac.clearMFAFailures(ctx, user.ID)
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	enabled, err := ac.users.EnableMFA(ctx, user.ID, user.MFAPendingSecret, hashes, step)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No MFA enrollment in progress"})
+		return
+	}
+	metrics.RecordAuthAttempt("mfa_confirm", "success")
+
+	c.JSON(http.StatusOK, models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableMFA godoc
+// @Summary      Desativar MFA
+// @Description  Desativa o MFA do usuário autenticado. Exige a senha e um código TOTP ou de recuperação;
+// @Description  contas sem senha (criadas pelo login OIDC) informam apenas o código.
+// @Description  Após muitas tentativas inválidas, novas tentativas do usuário são recusadas por um tempo (429).
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.MFADisableRequest  true  "Senha e código"
+// @Success      200      {object}  map[string]string
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      429      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /auth/mfa/disable [post]
+func (ac *AuthController) DisableMFA(c *gin.Context) {
+	var req models.MFADisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	user, err := ac.findUserByID(ctx, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user"})
+		return
+	}
+	if !user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is not enabled"})
+		return
+	}
+	if block := ac.checkMFAAllowed(ctx, user.ID, c.ClientIP()); block != nil {
+		block.respond(c)
+		return
+	}
+	// Accounts created by an OIDC login have no password: the code alone confirms it
+	if user.Password != "" {
+		if err := user.CheckPassword(req.Password); err != nil {
+			metrics.RecordAuthAttempt("mfa_disable", "failure")
+			ac.recordMFAFailure(ctx, user.ID, c.ClientIP())
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid password"})
+			return
+		}
+	}
+	if !ac.useMFACode(ctx, user, req.Code) {
+		metrics.RecordAuthAttempt("mfa_disable", "failure")
+		ac.recordMFAFailure(ctx, user.ID, c.ClientIP())
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+	ac.clearMFAFailures(ctx, user.ID)
+
+	if err := ac.users.DisableMFA(ctx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	metrics.RecordAuthAttempt("mfa_disable", "success")
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled"})
+}
+
+// VerifyMFA godoc
+// @Summary      Concluir login com MFA
+// @Description  Troca o token de desafio retornado pelo login (com MFA ativo) e um código TOTP ou de
+// @Description  recuperação pelo par de tokens. Após muitos códigos inválidos, novas tentativas do usuário
+// @Description  são recusadas por um tempo (429 com Retry-After).
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.MFAVerifyRequest  true  "Token de desafio e código"
+// @Success      200      {object}  models.AuthResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      429      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /auth/mfa/verify [post]
+func (ac *AuthController) VerifyMFA(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims := &models.MFAChallengeClaims{}
+	token, err := ac.options.Keys.Parse(req.MFAToken, claims, jwt.WithAudience(models.MFAAudience))
+	if err != nil || !token.Valid {
+		metrics.RecordAuthAttempt("mfa_verify", "failure")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	user, err := ac.findUserByID(ctx, claims.UserID)
+	if err != nil || !user.MFAEnabled {
+		metrics.RecordAuthAttempt("mfa_verify", "failure")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+	if rejectDisabledAccount(c, user, "mfa_verify") {
+		return
+	}
+
+	if block := ac.checkMFAAllowed(ctx, user.ID, c.ClientIP()); block != nil {
+		block.respond(c)
+		return
+	}
+	if !ac.useMFACode(ctx, user, req.Code) {
+		metrics.RecordAuthAttempt("mfa_verify", "failure")
+		ac.recordMFAFailure(ctx, user.ID, c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+	ac.clearMFAFailures(ctx, user.ID)
+
+	loginMethod := claims.LoginMethod
+	if loginMethod == "" {
+		loginMethod = models.LoginMethodPassword
+	}
+	response, err := ac.issueTokens(ctx, c, user, loginMethod)
+	if err != nil {
+		metrics.RecordAuthAttempt("mfa_verify", "failure")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	metrics.RecordAuthAttempt("mfa_verify", "success")
+	metrics.RecordTokenIssued()
+
+	c.JSON(http.StatusOK, response)
+}
+
+// respondMFAChallenge answers a login whose first factor (password or identity
+// provider) was accepted but that still needs the second factor
+func (ac *AuthController) respondMFAChallenge(c *gin.Context, user *models.User, method, loginMethod string) {
+	token, err := ac.generateMFAChallenge(user, loginMethod)
+	if err != nil {
+		metrics.RecordAuthAttempt(method, "failure")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	metrics.RecordAuthAttempt(method, "mfa_required")
+	c.JSON(http.StatusOK, models.MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(mfaChallengeTTL.Seconds()),
+	})
+}
+
+// generateMFAChallenge returns the token that lets the user finish a login with the second factor
+func (ac *AuthController) generateMFAChallenge(user *models.User, loginMethod string) (string, error) {
+	now := time.Now()
+	return ac.options.Keys.Sign(models.MFAChallengeClaims{
+		UserID:      user.ID,
+		LoginMethod: loginMethod,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{models.MFAAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	})
+}
+
+// useMFACode accepts a TOTP code not used before or an unused recovery code,
+// consuming it atomically so that concurrent requests cannot both succeed
+func (ac *AuthController) useMFACode(ctx context.Context, user *models.User, code string) bool {
+	if step, ok := totp.Validate(user.MFASecret, code, time.Now(), mfaSkew); ok {
+		used, err := ac.users.UseTOTPStep(ctx, user.ID, step)
+		return err == nil && used
+	}
+
+	codeHash := hashToken(normalizeRecoveryCode(code))
+	used, err := ac.users.UseRecoveryCode(ctx, user.ID, codeHash)
+	if err != nil || !used {
+		return false
+	}
+	slog.InfoContext(ctx, "Recovery code used", "user_id", user.ID, "remaining", len(user.MFARecoveryCodeHashes)-1)
+	return true
+}
+
+// generateRecoveryCodes returns new recovery codes (xxxx-xxxx-xxxx-xxxx) and their hashes
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := recoveryCodeEncoding.EncodeToString(buf)
+		codes = append(codes, raw[0:4]+"-"+raw[4:8]+"-"+raw[8:12]+"-"+raw[12:16])
+		hashes = append(hashes, hashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode ignores case, dashes and spaces typed by the user
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
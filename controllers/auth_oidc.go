@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"chatserver/metrics"
+	"chatserver/models"
+	"chatserver/oidc"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateTTL bounds the time the user has to log in at the identity provider
+const oidcStateTTL = 10 * time.Minute
+
+// oidcReauthWindow is how long after an OIDC login an account without a
+// password may change its password or delete itself
+const oidcReauthWindow = 10 * time.Minute
+
+// OIDCLogin godoc
+// @Summary      Login com provedor de identidade (OIDC)
+// @Description  Redireciona para a página de login do provedor de identidade (authorization code + PKCE).
+// @Description  Depois do login, o provedor redireciona para /auth/oidc/callback.
+// @Tags         auth
+// @Success      302
+// @Failure      502  {object}  map[string]string
+// @Router       /auth/oidc/login [get]
+func (ac *AuthController) OIDCLogin(c *gin.Context) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	pending := models.OIDCState{ID: hashToken(state), CreatedAt: time.Now()}
+	pending.ExpiresAt = pending.CreatedAt.Add(oidcStateTTL)
+	if pending.CodeVerifier, err = oidc.RandomString(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	if pending.Nonce, err = oidc.RandomString(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	authURL, err := ac.options.OIDC.AuthCodeURL(ctx, state, pending.Nonce, pending.CodeVerifier)
+	if err != nil {
+		slog.ErrorContext(ctx, "OIDC provider unavailable", "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+	if err := ac.oidcStates.Create(ctx, &pending); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback godoc
+// @Summary      Retorno do provedor de identidade (OIDC)
+// @Description  Troca o código de autorização pelos tokens do provedor, valida o ID token e retorna o mesmo
+// @Description  par de tokens do login com senha. A conta é vinculada pelo "sub" do provedor ou, no primeiro
+// @Description  acesso, pelo email verificado; se não existir, é criada. Uma conta local cujo email nunca foi
+// @Description  verificado é assumida pelo dono do email: a senha, o MFA, as sessões e as chaves de API são removidos.
+// @Description  Com MFA ativo, retorna um models.MFAChallengeResponse ("mfa_required": true), como o login com senha.
+// @Tags         auth
+// @Produce      json
+// @Param        code   query     string  true  "Código de autorização"
+// @Param        state  query     string  true  "State gerado em /auth/oidc/login"
+// @Success      200    {object}  models.AuthResponse
+// @Failure      400    {object}  map[string]string
+// @Failure      401    {object}  map[string]string
+// @Failure      403    {object}  map[string]string
+// @Failure      409    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Failure      502    {object}  map[string]string
+// @Router       /auth/oidc/callback [get]
+func (ac *AuthController) OIDCCallback(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		metrics.RecordAuthAttempt("oidc", "failure")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Identity provider login failed: " + providerError})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 20*time.Second)
+	defer cancel()
+
+	// The state is single-use: it is deleted as it is read
+	pending, err := ac.oidcStates.Consume(ctx, hashToken(state), time.Now())
+	if err != nil {
+		metrics.RecordAuthAttempt("oidc", "failure")
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	tokens, err := ac.options.OIDC.Exchange(ctx, code, pending.CodeVerifier)
+	if err != nil {
+		slog.WarnContext(ctx, "OIDC code exchange failed", "error", err)
+		metrics.RecordAuthAttempt("oidc", "failure")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider login failed"})
+		return
+	}
+	claims, err := ac.options.OIDC.VerifyIDToken(ctx, tokens.IDToken, pending.Nonce)
+	if err != nil {
+		slog.WarnContext(ctx, "OIDC ID token rejected", "error", err)
+		metrics.RecordAuthAttempt("oidc", "failure")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+		return
+	}
+
+	user, linkErr := ac.linkOIDCUser(ctx, claims)
+	if linkErr != nil {
+		metrics.RecordAuthAttempt("oidc", "failure")
+		linkErr.respond(c)
+		return
+	}
+	if rejectDisabledAccount(c, user, "oidc") {
+		return
+	}
+	if user.DeletionScheduledFor != nil && !ac.cancelAccountDeletion(ctx, user) {
+		metrics.RecordAuthAttempt("oidc", "failure")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found"})
+		return
+	}
+
+	// The provider only replaces the password: the second factor is still required
+	if user.MFAEnabled {
+		ac.respondMFAChallenge(c, user, "oidc", models.LoginMethodOIDC)
+		return
+	}
+
+	response, err := ac.issueTokens(ctx, c, user, models.LoginMethodOIDC)
+	if err != nil {
+		metrics.RecordAuthAttempt("oidc", "failure")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	metrics.RecordAuthAttempt("oidc", "success")
+	metrics.RecordTokenIssued()
+
+	c.JSON(http.StatusOK, response)
+}
+
+// linkOIDCUser finds the user of the provider account: by issuer and subject,
+// then by verified email (linking the account on its first OIDC login), and
+// otherwise creates it. An existing account with an unverified email is only
+// linked after its local credentials are removed.
+func (ac *AuthController) linkOIDCUser(ctx context.Context, claims *oidc.IDTokenClaims) (*models.User, *chatError) {
+	user, err := ac.users.FindByOIDC(ctx, claims.Issuer, claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if err != repository.ErrNotFound {
+		return nil, &chatError{status: http.StatusInternalServerError, message: "Database error"}
+	}
+
+	emailVerified := bool(claims.EmailVerified)
+	if claims.Email == "" {
+		return nil, &chatError{status: http.StatusForbidden, message: "The identity provider did not share an email address"}
+	}
+	if !emailVerified && !ac.options.OIDCAllowUnverifiedEmail {
+		return nil, &chatError{status: http.StatusForbidden, message: "Email not verified by the identity provider"}
+	}
+
+	// Link an existing account with the same email, unless it is linked to
+	// another subject. Only a verified email proves the account is the same person.
+	now := time.Now()
+	if emailVerified {
+		user, err = ac.users.LinkOIDC(ctx, claims.Email, claims.Issuer, claims.Subject)
+		if err == nil {
+			slog.InfoContext(ctx, "User linked to identity provider account", "user_id", user.ID)
+			return user, nil
+		}
+		if err != repository.ErrNotFound {
+			return nil, &chatError{status: http.StatusInternalServerError, message: "Database error"}
+		}
+
+		// A local account whose email was never verified may have been registered
+		// by someone else to hijack the account later: the owner of the email takes
+		// it over, and the password, MFA, sessions and API keys set up before are dropped
+		user, err = ac.users.ClaimUnverifiedOIDC(ctx, claims.Email, claims.Issuer, claims.Subject, now)
+		if err == nil {
+			revokeSessions(ctx, ac.sessions, ac.options.Connections, repository.SessionFilter{UserID: user.ID}, models.RevokeReasonAccountClaimed)
+			revokeAPIKeys(ctx, ac.apiKeys, ac.options.Connections, user.ID)
+			slog.WarnContext(ctx, "Unverified account claimed by identity provider login, local credentials removed", "user_id", user.ID)
+			return user, nil
+		}
+		if err != repository.ErrNotFound {
+			return nil, &chatError{status: http.StatusInternalServerError, message: "Database error"}
+		}
+	}
+	if _, err := ac.users.FindByEmail(ctx, claims.Email); err == nil {
+		return nil, &chatError{status: http.StatusConflict, message: "Email already registered to another account"}
+	}
+
+	// First login: create the user, without a password
+	user = &models.User{
+		Email:         claims.Email,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		EmailVerified: emailVerified,
+		OIDCIssuer:    claims.Issuer,
+		OIDCSubject:   claims.Subject,
+	}
+	if emailVerified {
+		user.EmailVerifiedAt = &now
+	}
+	if claims.Name != "" {
+		user.Name = &claims.Name
+	}
+	if err := ac.users.Create(ctx, user); err != nil {
+		// The email or the provider account was registered concurrently
+		if err == repository.ErrDuplicate {
+			return nil, &chatError{status: http.StatusConflict, message: "Email already registered to another account"}
+		}
+		return nil, &chatError{status: http.StatusInternalServerError, message: "Failed to create user"}
+	}
+	metrics.RecordAuthAttempt("register", "success")
+
+	return user, nil
+}
@@ -0,0 +1,351 @@
+package controllers_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chatserver/controllers"
+	"chatserver/keys"
+	"chatserver/models"
+	"chatserver/oidc"
+	"chatserver/totp"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeIdentityProvider is an OpenID Connect provider that logs in a fixed
+// account: every authorization code is exchanged for an ID token of it
+type fakeIdentityProvider struct {
+	server *httptest.Server
+	keys   *keys.KeySet
+
+	mu            sync.Mutex
+	subject       string
+	email         string
+	emailVerified bool
+	nonces        map[string]string // Nonce of each authorization code
+}
+
+func newFakeIdentityProvider(t *testing.T, subject, email string, emailVerified bool) *fakeIdentityProvider {
+	t.Helper()
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := keys.NewPrivateKey("idp", private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet, err := keys.NewKeySet("idp", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &fakeIdentityProvider{
+		keys:          keySet,
+		subject:       subject,
+		email:         email,
+		emailVerified: emailVerified,
+		nonces:        make(map[string]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(idp.keys.JWKS())
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// token exchanges an authorization code for a signed ID token
+func (idp *fakeIdentityProvider) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	nonce, ok := idp.nonces[r.FormValue("code")]
+	idp.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	idToken, err := idp.keys.Sign(jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            "chatserver",
+		"sub":            idp.subject,
+		"email":          idp.email,
+		"email_verified": idp.emailVerified,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(oidc.TokenResponse{AccessToken: "at", TokenType: "Bearer", IDToken: idToken})
+}
+
+// configure points the auth options to the provider
+func (idp *fakeIdentityProvider) configure(t *testing.T) func(*controllers.AuthOptions) {
+	provider, err := oidc.NewProvider(oidc.Config{
+		IssuerURL:   idp.server.URL,
+		ClientID:    "chatserver",
+		RedirectURL: "http://localhost/auth/oidc/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func(options *controllers.AuthOptions) { options.OIDC = provider }
+}
+
+// login runs the authorization code flow against the server and returns the
+// response of the callback
+func (idp *fakeIdentityProvider) login(t *testing.T, s *testServer) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := s.do(t, http.MethodGet, "/auth/oidc/login", "", nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("oidc login: status %d, body %s", rec.Code, rec.Body)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The user logs in at the provider, which redirects back with a code
+	code := "code-" + location.Query().Get("state")
+	idp.mu.Lock()
+	idp.nonces[code] = location.Query().Get("nonce")
+	idp.mu.Unlock()
+
+	callback := url.Values{"code": {code}, "state": {location.Query().Get("state")}}
+	return s.do(t, http.MethodGet, "/auth/oidc/callback?"+callback.Encode(), "", nil)
+}
+
+func TestOIDCClaimsUnverifiedAccount(t *testing.T) {
+	idp := newFakeIdentityProvider(t, "victim-sub", "vitima@example.com", true)
+	s := newTestServer(t, idp.configure(t))
+
+	// Someone registers the email before its owner, without verifying it
+	squatter := s.register(t, "vitima@example.com", "squatter123")
+
+	rec := idp.login(t, s)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("oidc callback: status %d, body %s", rec.Code, rec.Body)
+	}
+	owner := decodeResponse[models.AuthResponse](t, rec)
+	if owner.UserID != squatter.UserID || !owner.EmailVerified {
+		t.Errorf("oidc callback: user %q (verified %v), want the existing account %q verified",
+			owner.UserID, owner.EmailVerified, squatter.UserID)
+	}
+
+	// The credentials set up before the owner logged in no longer work
+	rec = s.do(t, http.MethodPost, "/api/v1/chat", squatter.Token, controllers.ChatRequest{Message: "oi"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("squatter access token: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	rec = s.do(t, http.MethodPost, "/auth/refresh", "", models.RefreshRequest{RefreshToken: squatter.RefreshToken})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("squatter refresh token: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	rec = s.do(t, http.MethodPost, "/auth/login", "", models.LoginRequest{Email: "vitima@example.com", Password: "squatter123"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("squatter password: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestOIDCLinksVerifiedAccount(t *testing.T) {
+	idp := newFakeIdentityProvider(t, "owner-sub", "dono@example.com", true)
+	s := newTestServer(t, idp.configure(t))
+
+	user := &models.User{Email: "dono@example.com", Password: "secret123", EmailVerified: true, CreatedAt: time.Now()}
+	if err := user.HashPassword(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	rec := s.do(t, http.MethodPost, "/auth/login", "", models.LoginRequest{Email: "dono@example.com", Password: "secret123"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	session := decodeResponse[models.AuthResponse](t, rec)
+
+	rec = idp.login(t, s)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("oidc callback: status %d, body %s", rec.Code, rec.Body)
+	}
+	if linked := decodeResponse[models.AuthResponse](t, rec); linked.UserID != user.ID {
+		t.Errorf("oidc callback: user %q, want %q", linked.UserID, user.ID)
+	}
+
+	// Linking a verified account keeps its password and sessions
+	rec = s.do(t, http.MethodPost, "/api/v1/chat", session.Token, controllers.ChatRequest{Message: "oi"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("existing session after linking: status %d, body %s", rec.Code, rec.Body)
+	}
+	rec = s.do(t, http.MethodPost, "/auth/login", "", models.LoginRequest{Email: "dono@example.com", Password: "secret123"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("password after linking: status %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOIDCRequiresMFA(t *testing.T) {
+	idp := newFakeIdentityProvider(t, "mfa-sub", "mfa@example.com", true)
+	s := newTestServer(t, idp.configure(t))
+
+	user := &models.User{
+		Email:         "mfa@example.com",
+		Password:      "secret123",
+		EmailVerified: true,
+		MFAEnabled:    true,
+		MFASecret:     "JBSWY3DPEHPK3PXP",
+		CreatedAt:     time.Now(),
+	}
+	if err := user.HashPassword(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider login only replaces the password: the code is still asked
+	rec := idp.login(t, s)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("oidc callback: status %d, body %s", rec.Code, rec.Body)
+	}
+	challenge := decodeResponse[models.MFAChallengeResponse](t, rec)
+	if !challenge.MFARequired || challenge.MFAToken == "" {
+		t.Errorf("oidc callback: got %+v, want an MFA challenge", challenge)
+	}
+	if tokens := decodeResponse[models.AuthResponse](t, rec); tokens.Token != "" || tokens.RefreshToken != "" {
+		t.Errorf("oidc callback: tokens issued before the second factor")
+	}
+}
+
+func TestOIDCAccountWithoutPasswordSetsPassword(t *testing.T) {
+	idp := newFakeIdentityProvider(t, "new-sub", "semsenha@example.com", true)
+	s := newTestServer(t, idp.configure(t))
+
+	rec := idp.login(t, s)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("oidc callback: status %d, body %s", rec.Code, rec.Body)
+	}
+	tokens := decodeResponse[models.AuthResponse](t, rec)
+
+	// The recent provider login replaces the current password the account does not have
+	rec = s.do(t, http.MethodPut, "/profile/password", tokens.Token, models.ChangePasswordRequest{NewPassword: "nova-senha"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("set password: status %d, body %s", rec.Code, rec.Body)
+	}
+	rec = s.do(t, http.MethodPost, "/auth/login", "", models.LoginRequest{Email: "semsenha@example.com", Password: "nova-senha"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("login with the new password: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	// Once set, the password is required like for any account
+	rec = s.do(t, http.MethodPut, "/profile/password", tokens.Token, models.ChangePasswordRequest{NewPassword: "outra-senha"})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("change without the current password: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestOIDCAccountWithoutPasswordNeedsProviderLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	// An account without a password whose session did not come from the provider
+	registered := s.register(t, "sem-oidc@example.com", "secret123")
+	if _, err := s.repos.Users.ChangePassword(context.Background(), registered.UserID, mustFindUser(t, s, registered.UserID).Password, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := s.do(t, http.MethodPut, "/profile/password", registered.Token, models.ChangePasswordRequest{NewPassword: "nova-senha"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("set password: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if body := decodeResponse[map[string]string](t, rec); !strings.Contains(body["error"], "/auth/forgot-password") {
+		t.Errorf("error %q, want a pointer to /auth/forgot-password", body["error"])
+	}
+}
+
+func TestOIDCAccountWithoutPasswordDisablesMFA(t *testing.T) {
+	idp := newFakeIdentityProvider(t, "mfa-only-sub", "so-mfa@example.com", true)
+	s := newTestServer(t, idp.configure(t))
+
+	const secret = "JBSWY3DPEHPK3PXP"
+	user := &models.User{
+		Email:         "so-mfa@example.com",
+		EmailVerified: true,
+		MFAEnabled:    true,
+		MFASecret:     secret,
+		OIDCIssuer:    idp.server.URL,
+		OIDCSubject:   "mfa-only-sub",
+		CreatedAt:     time.Now(),
+	}
+	if err := s.repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := idp.login(t, s)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("oidc callback: status %d, body %s", rec.Code, rec.Body)
+	}
+	challenge := decodeResponse[models.MFAChallengeResponse](t, rec)
+	step := totp.Step(time.Now())
+	rec = s.do(t, http.MethodPost, "/auth/mfa/verify", "", models.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: totpCode(t, secret, step-1)})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("mfa verify: status %d, body %s", rec.Code, rec.Body)
+	}
+	tokens := decodeResponse[models.AuthResponse](t, rec)
+
+	// Without a password the code alone confirms the change
+	rec = s.do(t, http.MethodPost, "/auth/mfa/disable", tokens.Token, models.MFADisableRequest{Code: totpCode(t, secret, step)})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("disable mfa: status %d, body %s", rec.Code, rec.Body)
+	}
+	if stored := mustFindUser(t, s, user.ID); stored.MFAEnabled {
+		t.Errorf("mfa still enabled")
+	}
+}
+
+// mustFindUser reads the user from the repository
+func mustFindUser(t *testing.T, s *testServer, userID string) *models.User {
+	t.Helper()
+
+	user, err := s.repos.Users.FindByID(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// totpCode returns the code of the secret for the time step
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"chatserver/mailer"
+	"chatserver/metrics"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// mailTimeout bounds the delivery of an email sent in the background
+const mailTimeout = 30 * time.Second
+
+// passwordResetIPWindow is the period counted by MaxPasswordResetsPerIP
+const passwordResetIPWindow = time.Hour
+
+// ForgotPassword godoc
+// @Summary      Esqueci minha senha
+// @Description  Envia por email um link para redefinir a senha. A resposta é sempre a mesma,
+// @Description  exista ou não uma conta com o email informado. Pedidos repetidos para o mesmo email
+// @Description  ou do mesmo IP além do limite são ignorados, com a mesma resposta.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.ForgotPasswordRequest  true  "Email da conta"
+// @Success      200      {object}  map[string]string
+// @Failure      400      {object}  map[string]string
+// @Router       /auth/forgot-password [post]
+func (ac *AuthController) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Never reveal whether the email is registered
+	response := gin.H{"message": "If the email is registered, a password reset link has been sent"}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	// Throttled requests get the same answer: the limits must not reveal accounts either
+	if !ac.allowPasswordReset(ctx, req.Email, c.ClientIP()) {
+		metrics.RecordAuthAttempt("forgot_password", "throttled")
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// A new request replaces any previous token
+	expiresAt := time.Now().Add(ac.options.PasswordResetTTL)
+	user, err := ac.users.SetPasswordResetToken(ctx, req.Email, hashToken(token), expiresAt)
+	if err != nil {
+		if err != repository.ErrNotFound {
+			slog.ErrorContext(ctx, "Failed to store password reset token", "error", err)
+		}
+		metrics.RecordAuthAttempt("forgot_password", "failure")
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	metrics.RecordAuthAttempt("forgot_password", "success")
+
+	// Sent in the background so the response time does not reveal the account exists
+	link := ac.options.AppURL + "/reset-password?token=" + url.QueryEscape(token)
+	ac.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Redefinição de senha",
+		Body: fmt.Sprintf("Recebemos um pedido para redefinir a senha da sua conta.\n\n"+
+			"Para escolher uma nova senha, acesse o link abaixo (válido por %s):\n\n%s\n\n"+
+			"Se você não fez este pedido, ignore este email: sua senha continua a mesma.\n",
+			formatTTL(ac.options.PasswordResetTTL), link),
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// allowPasswordReset limits the reset emails sent to an address, so that the
+// endpoint cannot be used to flood a mailbox, and the requests of a client IP
+func (ac *AuthController) allowPasswordReset(ctx context.Context, email, ip string) bool {
+	if ac.options.MaxPasswordResetsPerIP > 0 &&
+		!ac.allowRequest(ctx, "reset:ip:"+ip, ac.options.MaxPasswordResetsPerIP, passwordResetIPWindow) {
+		return false
+	}
+	if ac.options.PasswordResetInterval > 0 {
+		emailKey, _ := loginAttemptKeys(email, ip)
+		return ac.allowRequest(ctx, "reset:"+emailKey, 1, ac.options.PasswordResetInterval)
+	}
+	return true
+}
+
+// ResetPassword godoc
+// @Summary      Redefinir senha
+// @Description  Define uma nova senha usando o token recebido por email. O token só pode ser usado uma vez
+// @Description  e todas as sessões do usuário são encerradas.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.ResetPasswordRequest  true  "Token e nova senha"
+// @Success      200      {object}  map[string]string
+// @Failure      400      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /auth/reset-password [post]
+func (ac *AuthController) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	// Consuming the token and changing the password in one update makes the token single-use
+	user, err := ac.users.ResetPassword(ctx, hashToken(req.Token), string(hashedPassword), time.Now())
+	if err != nil {
+		metrics.RecordAuthAttempt("reset_password", "failure")
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Whoever knew the old password must not stay logged in, nor keep the API keys they created
+	revokeSessions(ctx, ac.sessions, ac.options.Connections, repository.SessionFilter{UserID: user.ID}, models.RevokeReasonPasswordReset)
+	revokeAPIKeys(ctx, ac.apiKeys, ac.options.Connections, user.ID)
+	metrics.RecordAuthAttempt("reset_password", "success")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// sendMail delivers the email in the background, logging failures
+func (ac *AuthController) sendMail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := ac.options.Mailer.Send(ctx, msg); err != nil {
+			slog.ErrorContext(ctx, "Failed to send email", "subject", msg.Subject, "error", err)
+		}
+	}()
+}
+
+// formatTTL describes a duration in Portuguese for email texts ("1 hora", "30 minutos")
+func formatTTL(d time.Duration) string {
+	switch {
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d horas", int(d.Hours()))
+	case d >= time.Hour:
+		return "1 hora"
+	default:
+		return fmt.Sprintf("%d minutos", int(d.Minutes()))
+	}
+}
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatserver/metrics"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrSessionRevoked is returned by Authenticate for tokens of a revoked (or unknown) session
+	ErrSessionRevoked = errors.New("session revoked")
+	// ErrAccountDisabled is returned by Authenticate for accounts disabled by an administrator
+	ErrAccountDisabled = errors.New("account disabled")
+)
+
+// Refresh godoc
+// @Summary      Renovar tokens
+// @Description  Troca um refresh token por um novo par de tokens. O refresh token usado é invalidado (rotação);
+// @Description  reutilizar um refresh token já trocado revoga a sessão inteira.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.RefreshRequest  true  "Refresh token"
+// @Success      200      {object}  models.AuthResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /auth/refresh [post]
+func (ac *AuthController) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	tokenHash := hashToken(req.RefreshToken)
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	// Rotate: the presented token must be the current one of an active session
+	now := time.Now()
+	session, err := ac.sessions.Rotate(ctx, tokenHash, hashToken(refreshToken), now, now.Add(ac.options.RefreshTokenTTL))
+	if err == repository.ErrNotFound {
+		metrics.RecordAuthAttempt("refresh", "failure")
+
+		// A token that was already rotated is being reused: it leaked (or the
+		// legitimate client lost a race), so the whole session is revoked
+		if revokeSessions(ctx, ac.sessions, ac.options.Connections, repository.SessionFilter{PreviousTokenHash: tokenHash}, models.RevokeReasonReuse) > 0 {
+			slog.WarnContext(ctx, "Refresh token reuse detected, session revoked")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, session revoked"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		metrics.RecordAuthAttempt("refresh", "failure")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	user, err := ac.findUserByID(ctx, session.UserID)
+	if err != nil {
+		metrics.RecordAuthAttempt("refresh", "failure")
+		revokeSessions(ctx, ac.sessions, ac.options.Connections, repository.SessionFilter{ID: &session.ID}, models.RevokeReasonLogout)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if rejectDisabledAccount(c, user, "refresh") {
+		revokeSessions(ctx, ac.sessions, ac.options.Connections, repository.SessionFilter{ID: &session.ID}, models.RevokeReasonAccountDisabled)
+		return
+	}
+
+	token, err := ac.generateToken(user, session.ID.Hex())
+	if err != nil {
+		metrics.RecordAuthAttempt("refresh", "failure")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	metrics.RecordAuthAttempt("refresh", "success")
+	metrics.RecordTokenIssued()
+
+	c.JSON(http.StatusOK, models.AuthResponse{
+		Token:         token,
+		RefreshToken:  refreshToken,
+		ExpiresIn:     int64(ac.options.AccessTokenTTL.Seconds()),
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          user.EffectiveRole(),
+		UserID:        user.ID,
+		CreatedAt:     user.CreatedAt,
+	})
+}
+
+// Logout godoc
+// @Summary      Logout
+// @Description  Revoga a sessão do refresh token informado; os access tokens da sessão deixam de ser aceitos
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.RefreshRequest  true  "Refresh token"
+// @Success      200      {object}  map[string]string
+// @Failure      400      {object}  map[string]string
+// @Router       /auth/logout [post]
+func (ac *AuthController) Logout(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	// Idempotent: unknown or already revoked tokens are not reported
+	tokenHash := hashToken(req.RefreshToken)
+	revokeSessions(ctx, ac.sessions, ac.options.Connections, repository.SessionFilter{TokenHash: tokenHash}, models.RevokeReasonLogout)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// Authenticate validates an access token and checks that its session is still
+// active. Personal API keys are accepted as well.
+func (ac *AuthController) Authenticate(ctx context.Context, tokenString string) (*models.Claims, error) {
+	if strings.HasPrefix(tokenString, models.APIKeyPrefix) {
+		return ac.authenticateAPIKey(ctx, tokenString)
+	}
+
+	claims, err := ac.validateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		return nil, ErrSessionRevoked
+	}
+
+	start := time.Now()
+	session, err := ac.sessions.FindByID(ctx, sessionID, claims.UserID)
+	if err == repository.ErrNotFound {
+		metrics.RecordDatabaseOperation("find", "sessions", "success", time.Since(start).Seconds())
+		return nil, ErrSessionRevoked
+	}
+	if err != nil {
+		metrics.RecordDatabaseOperation("find", "sessions", "failure", time.Since(start).Seconds())
+		return nil, err
+	}
+	metrics.RecordDatabaseOperation("find", "sessions", "success", time.Since(start).Seconds())
+
+	if session.RevokedAt != nil {
+		// Sessions of a disabled account stay refused as such while it is disabled
+		if session.RevokeReason == models.RevokeReasonAccountDisabled {
+			if user, err := ac.findUserByID(ctx, claims.UserID); err == nil && user.DisabledAt != nil {
+				return nil, ErrAccountDisabled
+			}
+		}
+		return nil, ErrSessionRevoked
+	}
+	return claims, nil
+}
+
+// issueTokens starts a new session for the user, logged in with loginMethod,
+// and returns its token pair
+func (ac *AuthController) issueTokens(ctx context.Context, c *gin.Context, user *models.User, loginMethod string) (models.AuthResponse, error) {
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:               primitive.NewObjectID(),
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        c.Request.UserAgent(),
+		IP:               c.ClientIP(),
+		LoginMethod:      loginMethod,
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(ac.options.RefreshTokenTTL),
+	}
+
+	start := time.Now()
+	if err := ac.sessions.Create(ctx, &session); err != nil {
+		metrics.RecordDatabaseOperation("insert", "sessions", "failure", time.Since(start).Seconds())
+		return models.AuthResponse{}, err
+	}
+	metrics.RecordDatabaseOperation("insert", "sessions", "success", time.Since(start).Seconds())
+
+	token, err := ac.generateToken(user, session.ID.Hex())
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	return models.AuthResponse{
+		Token:         token,
+		RefreshToken:  refreshToken,
+		ExpiresIn:     int64(ac.options.AccessTokenTTL.Seconds()),
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          user.EffectiveRole(),
+		UserID:        user.ID,
+		CreatedAt:     user.CreatedAt,
+	}, nil
+}
+
+// recentOIDCLogin reports whether the request belongs to a session started by an
+// OIDC login less than oidcReauthWindow ago. Accounts without a password use it
+// instead of the password to confirm sensitive changes.
+func recentOIDCLogin(ctx context.Context, sessions repository.SessionRepository, c *gin.Context, user *models.User) bool {
+	sessionID, err := primitive.ObjectIDFromHex(c.GetString("session_id"))
+	if err != nil {
+		return false
+	}
+	session, err := sessions.FindByID(ctx, sessionID, user.ID)
+	if err != nil {
+		return false
+	}
+	return session.LoginMethod == models.LoginMethodOIDC && time.Since(session.CreatedAt) < oidcReauthWindow
+}
+
+// rejectDisabledAccount answers 403 when the account was disabled by an administrator
+func rejectDisabledAccount(c *gin.Context, user *models.User, authType string) bool {
+	if user.DisabledAt == nil {
+		return false
+	}
+	metrics.RecordAuthAttempt(authType, "failure")
+	c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+	return true
+}
+
+// revokeSessions revokes the active sessions matching filter, closes the
+// WebSocket connections opened with them and returns how many were revoked
+func revokeSessions(ctx context.Context, sessions repository.SessionRepository, connections *Connections, filter repository.SessionFilter, reason string) int64 {
+	revoked, err := sessions.Revoke(ctx, filter, reason, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to revoke sessions", "error", err)
+		return 0
+	}
+	connections.closeSessions(filter)
+	return revoked
+}
+
+// findUserByID loads a user by its hex ID
+func (ac *AuthController) findUserByID(ctx context.Context, userID string) (*models.User, error) {
+	return ac.users.FindByID(ctx, userID)
+}
+
+// generateOpaqueToken returns a random opaque token (256 bits, base64url), used
+// for refresh, password reset and email verification tokens
+func generateOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken returns the SHA-256 of a random token. Tokens have enough entropy
+// that a fast hash is sufficient (unlike passwords).
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
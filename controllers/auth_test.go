@@ -0,0 +1,225 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"chatserver/controllers"
+	"chatserver/models"
+	"chatserver/totp"
+)
+
+func TestRegisterLoginRefresh(t *testing.T) {
+	s := newTestServer(t)
+
+	registered := s.register(t, "ana@example.com", "secret123")
+	if registered.Token == "" || registered.RefreshToken == "" || registered.UserID == "" {
+		t.Fatalf("register: incomplete response %+v", registered)
+	}
+	if s.mail.sent("ana@example.com", 1) != 1 {
+		t.Errorf("register: verification email not sent")
+	}
+
+	rec := s.do(t, http.MethodPost, "/auth/register", "", models.RegisterRequest{Email: "ana@example.com", Password: "secret123"})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate register: status %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	rec = s.do(t, http.MethodPost, "/auth/login", "", models.LoginRequest{Email: "ana@example.com", Password: "wrong"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("login with wrong password: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = s.do(t, http.MethodPost, "/auth/login", "", models.LoginRequest{Email: "ana@example.com", Password: "secret123"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	login := decodeResponse[models.AuthResponse](t, rec)
+	if login.UserID != registered.UserID {
+		t.Errorf("login: user %q, want %q", login.UserID, registered.UserID)
+	}
+
+	rec = s.do(t, http.MethodPost, "/auth/refresh", "", models.RefreshRequest{RefreshToken: login.RefreshToken})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d, body %s", rec.Code, rec.Body)
+	}
+	refreshed := decodeResponse[models.AuthResponse](t, rec)
+	if refreshed.RefreshToken == login.RefreshToken {
+		t.Errorf("refresh: refresh token was not rotated")
+	}
+
+	// The new access token is accepted by the authenticated routes
+	rec = s.do(t, http.MethodPost, "/api/v1/chat", refreshed.Token, controllers.ChatRequest{Message: "oi"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("chat with refreshed token: status %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRegisterConcurrentDuplicate(t *testing.T) {
+	s := newTestServer(t)
+
+	// Every request may pass the email lookup: only the unique index stops the duplicates
+	const requests = 10
+	statuses := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := s.do(t, http.MethodPost, "/auth/register", "", models.RegisterRequest{Email: "bia@example.com", Password: "secret123"})
+			statuses <- rec.Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != requests-1 {
+		t.Errorf("statuses %v, want one %d and the rest %d", counts, http.StatusCreated, http.StatusConflict)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register(t, "bia@example.com", "secret123")
+
+	rec := s.do(t, http.MethodPost, "/auth/refresh", "", models.RefreshRequest{RefreshToken: registered.RefreshToken})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d, body %s", rec.Code, rec.Body)
+	}
+	rotated := decodeResponse[models.AuthResponse](t, rec)
+
+	// Presenting the old token again revokes the whole session
+	rec = s.do(t, http.MethodPost, "/auth/refresh", "", models.RefreshRequest{RefreshToken: registered.RefreshToken})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = s.do(t, http.MethodPost, "/auth/refresh", "", models.RefreshRequest{RefreshToken: rotated.RefreshToken})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after reuse: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	rec = s.do(t, http.MethodPost, "/api/v1/chat", rotated.Token, controllers.ChatRequest{Message: "oi"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token after reuse: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register(t, "caio@example.com", "secret123")
+
+	rec := s.do(t, http.MethodPost, "/auth/logout", "", models.RefreshRequest{RefreshToken: registered.RefreshToken})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("logout: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	rec = s.do(t, http.MethodPost, "/api/v1/chat", registered.Token, controllers.ChatRequest{Message: "oi"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token after logout: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "duda@example.com", "secret123")
+
+	for i := 0; i < maxLoginFailures; i++ {
+		s.do(t, http.MethodPost, "/auth/login", "", models.LoginRequest{Email: "duda@example.com", Password: "wrong"})
+	}
+
+	rec := s.do(t, http.MethodPost, "/auth/login", "", models.LoginRequest{Email: "duda@example.com", Password: "secret123"})
+	if rec.Code != http.StatusLocked {
+		t.Fatalf("login after %d failures: status %d, want %d", maxLoginFailures, rec.Code, http.StatusLocked)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("locked login: Retry-After header missing")
+	}
+}
+
+func TestMFAVerifyThrottled(t *testing.T) {
+	s := newTestServer(t)
+
+	const secret = "JBSWY3DPEHPK3PXP"
+	user := &models.User{Email: "eli@example.com", Password: "secret123", MFAEnabled: true, MFASecret: secret, CreatedAt: time.Now()}
+	if err := user.HashPassword(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := s.do(t, http.MethodPost, "/auth/login", "", models.LoginRequest{Email: "eli@example.com", Password: "secret123"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	challenge := decodeResponse[models.MFAChallengeResponse](t, rec)
+
+	for i := 0; i < maxLoginFailures; i++ {
+		rec = s.do(t, http.MethodPost, "/auth/mfa/verify", "", models.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: "wrong"})
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("invalid code %d: status %d, want %d", i+1, rec.Code, http.StatusUnauthorized)
+		}
+	}
+
+	// Once blocked, even the right code is refused
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = s.do(t, http.MethodPost, "/auth/mfa/verify", "", models.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: code})
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("code after %d failures: status %d, want %d", maxLoginFailures, rec.Code, http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("blocked code: Retry-After header missing")
+	}
+}
+
+func TestForgotPasswordThrottledPerEmail(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "fabi@example.com", "secret123")
+	if s.mail.sent("fabi@example.com", 1) != 1 {
+		t.Fatalf("verification email not sent")
+	}
+
+	for i := 0; i < 3; i++ {
+		rec := s.do(t, http.MethodPost, "/auth/forgot-password", "", models.ForgotPasswordRequest{Email: "fabi@example.com"})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want %d", i+1, rec.Code, http.StatusOK)
+		}
+	}
+
+	// The verification email plus a single reset email
+	if got := s.mail.sent("fabi@example.com", 3); got != 2 {
+		t.Errorf("emails sent: %d, want 2", got)
+	}
+}
+
+func TestForgotPasswordThrottledPerIP(t *testing.T) {
+	s := newTestServer(t, func(o *controllers.AuthOptions) { o.MaxPasswordResetsPerIP = 2 })
+	emails := []string{"gil@example.com", "hana@example.com", "ivo@example.com"}
+	for _, email := range emails {
+		s.register(t, email, "secret123")
+	}
+
+	for _, email := range emails {
+		rec := s.do(t, http.MethodPost, "/auth/forgot-password", "", models.ForgotPasswordRequest{Email: email})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, want %d", email, rec.Code, http.StatusOK)
+		}
+	}
+
+	if got := s.mail.sent("gil@example.com", 2); got != 2 {
+		t.Errorf("first request: %d emails, want 2", got)
+	}
+	if got := s.mail.sent("ivo@example.com", 2); got != 1 {
+		t.Errorf("request over the IP limit: %d emails, want 1", got)
+	}
+}
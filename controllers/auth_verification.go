@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"chatserver/mailer"
+	"chatserver/metrics"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VerifyEmail godoc
+// @Summary      Verificar email
+// @Description  Confirma o email da conta com o token enviado por email no registro (ou reenviado).
+// @Description  Tokens de acesso emitidos antes da verificação continuam sem a confirmação: use /auth/refresh.
+// @Tags         auth
+// @Produce      json
+// @Param        token  query     string  true  "Token de verificação"
+// @Success      200    {object}  map[string]string
+// @Failure      400    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /auth/verify-email [get]
+func (ac *AuthController) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification token required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	err := ac.users.VerifyEmail(ctx, hashToken(token), time.Now())
+	if err != nil {
+		metrics.RecordAuthAttempt("verify_email", "failure")
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	metrics.RecordAuthAttempt("verify_email", "success")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification godoc
+// @Summary      Reenviar email de verificação
+// @Description  Gera um novo token de verificação e o envia para o email do usuário autenticado,
+// @Description  invalidando o anterior. Limitado a um envio por intervalo (429 com Retry-After).
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/resend-verification [post]
+func (ac *AuthController) ResendVerification(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user"})
+		return
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	// The throttle check and the new token are applied in one update, so
+	// concurrent requests cannot send more than one email per interval
+	now := time.Now()
+	user, err := ac.users.RenewVerificationToken(ctx, userID, hashToken(token),
+		now, now.Add(ac.options.EmailVerificationTTL), ac.options.VerificationResend)
+	if err == repository.ErrNotFound {
+		ac.rejectResend(ctx, c, userID, now)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	ac.sendVerificationEmail(user, token)
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// rejectResend explains why a resend was refused: already verified, throttled or unknown user
+func (ac *AuthController) rejectResend(ctx context.Context, c *gin.Context, userID string, now time.Time) {
+	user, err := ac.users.FindByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user"})
+		return
+	}
+	if user.EmailVerified {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already verified"})
+		return
+	}
+
+	retryAfter := time.Second
+	if user.EmailVerificationSentAt != nil {
+		if wait := user.EmailVerificationSentAt.Add(ac.options.VerificationResend).Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Verification email sent recently, try again later"})
+}
+
+// sendVerificationEmail sends the link that confirms the user's email
+func (ac *AuthController) sendVerificationEmail(user *models.User, token string) {
+	link := ac.options.APIURL + "/auth/verify-email?token=" + url.QueryEscape(token)
+	ac.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirme seu email",
+		Body: fmt.Sprintf("Bem-vindo ao SR Robot!\n\n"+
+			"Para confirmar seu email, acesse o link abaixo (válido por %s):\n\n%s\n\n"+
+			"Se você não criou esta conta, ignore este email.\n",
+			formatTTL(ac.options.EmailVerificationTTL), link),
+	})
+}
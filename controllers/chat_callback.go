@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// callbackBlockedPrefixes são redes internas não cobertas pelos métodos de netip.Addr
+var callbackBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "Esta rede"
+	netip.MustParsePrefix("100.64.0.0/10"), // NAT de operadora (CGNAT)
+	netip.MustParsePrefix("192.0.0.0/24"),  // Atribuições de protocolo do IETF
+	netip.MustParsePrefix("198.18.0.0/15"), // Testes de desempenho
+	netip.MustParsePrefix("240.0.0.0/4"),   // Reservado, inclui 255.255.255.255
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, que pode apontar para IPv4 interno
+}
+
+// errCallbackAddressBlocked indica um callback para um endereço interno
+var errCallbackAddressBlocked = errors.New("endereço do callback não permitido")
+
+// newCallbackClient cria o cliente HTTP dos callbacks. Sem allowPrivate, a
+// conexão é recusada quando o host resolve para um endereço interno (loopback,
+// rede privada, link-local como 169.254.169.254, não especificado ou
+// multicast). A verificação é feita no IP efetivamente conectado, depois da
+// resolução do DNS, para que um DNS que muda de resposta (DNS rebinding) não
+// escape dela. Redirecionamentos não são seguidos.
+func newCallbackClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			return checkCallbackAddress(address)
+		}
+	}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// Sem proxy: a verificação precisa ver o IP do destino, não o do proxy
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		// Um redirecionamento poderia levar a um endereço interno por outro caminho
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkCallbackAddress recusa um endereço "ip:porta" interno
+func checkCallbackAddress(address string) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errCallbackAddressBlocked, address)
+	}
+	if !isPublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", errCallbackAddressBlocked, addrPort.Addr())
+	}
+	return nil
+}
+
+// isPublicAddress informa se o IP é roteável na internet
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap() // ::ffff:127.0.0.1 é 127.0.0.1
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range callbackBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestValidateCallbackURL(t *testing.T) {
+	tests := []struct {
+		url          string
+		allowPrivate bool
+		valid        bool
+	}{
+		{"https://example.com/hook", false, true},
+		{"http://93.184.216.34:8080/hook", false, true},
+		{"ftp://example.com/hook", false, false},
+		{"/hook", false, false},
+		{"http://localhost:8080/hook", false, false},
+		{"http://api.localhost/hook", false, false},
+		{"http://127.0.0.1/hook", false, false},
+		{"http://10.0.0.5/hook", false, false},
+		{"http://192.168.1.1/hook", false, false},
+		{"http://169.254.169.254/latest/meta-data/", false, false},
+		{"http://0.0.0.0/hook", false, false},
+		{"http://[::1]/hook", false, false},
+		{"http://[::ffff:127.0.0.1]/hook", false, false},
+		{"http://[fd00::1]/hook", false, false},
+		{"http://localhost:8080/hook", true, true},
+		{"http://169.254.169.254/latest/meta-data/", true, true},
+	}
+	for _, tt := range tests {
+		err := validateCallbackURL(tt.url, tt.allowPrivate)
+		if (err == nil) != tt.valid {
+			t.Errorf("validateCallbackURL(%q, %v) = %v, válida esperado %v", tt.url, tt.allowPrivate, err, tt.valid)
+		}
+	}
+}
+
+func TestCallbackClientBlocksPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	// O IP é conferido na conexão, depois da resolução do nome
+	target := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	_, err := newCallbackClient(false).Post(target, "application/json", nil)
+	if !errors.Is(err, errCallbackAddressBlocked) {
+		t.Errorf("callback para loopback: erro %v, esperado %v", err, errCallbackAddressBlocked)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("callback para loopback chegou ao servidor")
+	}
+
+	resp, err := newCallbackClient(true).Post(server.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("callback com allowPrivate: %v", err)
+	}
+	resp.Body.Close()
+	if hits.Load() != 1 {
+		t.Errorf("callback com allowPrivate: %d requisições, esperado 1", hits.Load())
+	}
+}
+
+func TestCallbackClientDoesNotFollowRedirects(t *testing.T) {
+	var hits atomic.Int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer internal.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	resp, err := newCallbackClient(true).Post(redirect.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTemporaryRedirect || hits.Load() != 0 {
+		t.Errorf("redirecionamento: status %d e %d requisições ao destino, esperado %d e 0",
+			resp.StatusCode, hits.Load(), http.StatusTemporaryRedirect)
+	}
+}
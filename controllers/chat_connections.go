@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"chatserver/repository"
+)
+
+// wsCloseTimeout limita a espera pelo aviso enviado antes de encerrar uma conexão
+const wsCloseTimeout = time.Second
+
+// Connections registra as conexões WebSocket abertas para encerrá-las quando a
+// sessão ou a chave de API usada no handshake é revogada. É compartilhado entre
+// o ChatController, que registra as conexões, e os controllers que revogam
+// credenciais (logout forçado, conta desativada, troca ou redefinição de
+// senha...). Um *Connections nil não registra nem encerra nada.
+type Connections struct {
+	mu      sync.Mutex
+	clients map[*wsClient]struct{}
+}
+
+// NewConnections cria um registro vazio
+func NewConnections() *Connections {
+	return &Connections{clients: make(map[*wsClient]struct{})}
+}
+
+func (cs *Connections) add(cl *wsClient) {
+	if cs == nil {
+		return
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.clients[cl] = struct{}{}
+}
+
+func (cs *Connections) remove(cl *wsClient) {
+	if cs == nil {
+		return
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	delete(cs.clients, cl)
+}
+
+// closeSessions encerra as conexões abertas com as sessões revogadas por
+// filter. Revogar todas as sessões de um usuário encerra também as conexões
+// abertas com chaves de API, para que uma conta desativada perca o acesso na
+// hora; as chaves continuam valendo para novas conexões se não forem revogadas.
+// Filtros por hash do refresh token não identificam a sessão: essas conexões
+// são recusadas na próxima mensagem, quando o token é validado de novo.
+func (cs *Connections) closeSessions(filter repository.SessionFilter) {
+	switch {
+	case filter.ID != nil:
+		sessionID := filter.ID.Hex()
+		cs.closeWhere(func(cl *wsClient) bool { return cl.sessionID == sessionID })
+	case filter.UserID != "" && filter.ExceptID != nil:
+		exceptID := filter.ExceptID.Hex()
+		cs.closeWhere(func(cl *wsClient) bool {
+			return cl.userID == filter.UserID && cl.apiKeyID == "" && cl.sessionID != exceptID
+		})
+	case filter.UserID != "":
+		cs.closeWhere(func(cl *wsClient) bool { return cl.userID == filter.UserID })
+	}
+}
+
+// closeAPIKeys encerra as conexões do usuário abertas com chaves de API
+// (apiKeyID vazio = todas as chaves dele)
+func (cs *Connections) closeAPIKeys(userID, apiKeyID string) {
+	cs.closeWhere(func(cl *wsClient) bool {
+		return cl.userID == userID && cl.apiKeyID != "" && (apiKeyID == "" || cl.apiKeyID == apiKeyID)
+	})
+}
+
+// closeWhere avisa e desconecta os clientes para os quais match retorna true
+func (cs *Connections) closeWhere(match func(*wsClient) bool) {
+	if cs == nil {
+		return
+	}
+
+	cs.mu.Lock()
+	var matched []*wsClient
+	for cl := range cs.clients {
+		if match(cl) {
+			matched = append(matched, cl)
+		}
+	}
+	cs.mu.Unlock()
+
+	for _, cl := range matched {
+		cl.close("Sessão encerrada")
+	}
+}
@@ -3,30 +3,29 @@ package controllers
 import (
 	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"chatserver/database"
+	"chatserver/assistant"
+	"chatserver/logging"
+	"chatserver/metrics"
 	"chatserver/models"
+	"chatserver/repository"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-const (
-	N8N_WEBHOOK_URL = "https://galaxy.conecta-tech.com.br/webhook/conversation"
 )
 
 // ChatRequest representa a requisição de chat
 type ChatRequest struct {
 	ConversationID string `json:"conversationId,omitempty"` // Opcional: se não fornecido, cria nova conversa
 	Message        string `json:"message" binding:"required"`
+	Async          bool   `json:"async,omitempty"`       // Opcional: responde 202 imediatamente e processa em segundo plano
+	CallbackURL    string `json:"callbackUrl,omitempty"` // Opcional (modo async): URL notificada ao concluir
 }
 
 // ChatResponse representa a resposta do chat
@@ -38,52 +37,138 @@ type ChatResponse struct {
 	LatencyMs      int64              `json:"latencyMs"`
 }
 
-// N8NRequest representa a requisição para o n8n
-type N8NRequest struct {
-	Message        string           `json:"message"`
-	ConversationID string           `json:"conversationId"`
-	History        []models.Message `json:"history,omitempty"` // Histórico das últimas mensagens
+// statusClientClosedRequest é usado quando o cliente desiste da requisição (convenção do nginx)
+const statusClientClosedRequest = 499
+
+// chatError representa uma falha no fluxo de chat com o status HTTP correspondente
+type chatError struct {
+	status     int
+	message    string
+	retryAfter time.Duration // Enviado no header Retry-After quando > 0
 }
 
-// N8NResponse representa a resposta do n8n
-type N8NResponse struct {
-	Output   string                 `json:"output"`   // Campo retornado pelo N8N
-	Response string                 `json:"response"` // Alternativa (compatibilidade)
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
+// respond escreve o erro como resposta JSON
+func (e *chatError) respond(c *gin.Context) {
+	if e.retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(e.retryAfter.Seconds()))))
+	}
+	c.JSON(e.status, gin.H{"error": e.message})
 }
 
-// GetResponse retorna a resposta (output ou response)
-func (n *N8NResponse) GetResponse() string {
-	if n.Output != "" {
-		return n.Output
+// newBackendError converte uma falha do backend em erro HTTP sem expor a
+// resposta do upstream ao cliente; o detalhe completo vai para o log
+func newBackendError(ctx context.Context, backendName string, err error) *chatError {
+	slog.WarnContext(ctx, "Erro ao chamar backend", "backend", backendName, "error", err)
+
+	var circuitErr *assistant.CircuitOpenError
+	switch {
+	case errors.As(err, &circuitErr):
+		return &chatError{
+			status:     http.StatusServiceUnavailable,
+			message:    "Assistente temporariamente indisponível, tente novamente em instantes",
+			retryAfter: circuitErr.RetryAfter,
+		}
+	case errors.Is(err, context.Canceled):
+		return &chatError{status: statusClientClosedRequest, message: "Requisição cancelada"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &chatError{status: http.StatusGatewayTimeout, message: "Tempo limite excedido ao aguardar o assistente"}
+	default:
+		return &chatError{status: http.StatusBadGateway, message: "Erro ao obter resposta do assistente"}
 	}
-	return n.Response
+}
+
+// requestContext retorna um contexto com o trace, o request ID e os campos de
+// log da requisição que, assim como context.Background, não é cancelado quando
+// o cliente desconecta
+func requestContext(c *gin.Context) context.Context {
+	return context.WithoutCancel(c.Request.Context())
+}
+
+// logConversation adiciona o ID da conversa aos logs da requisição, incluindo
+// a linha registrada ao final dela
+func logConversation(c *gin.Context, conversationID primitive.ObjectID) {
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "conversation_id", conversationID.Hex()))
+}
+
+// chatTurn representa uma mensagem do usuário já salva, pronta para ser enviada ao backend
+type chatTurn struct {
+	conversationID primitive.ObjectID
+	userMessage    *models.Message
+	history        []models.Message // Histórico recente, incluindo a mensagem do usuário
+
+	// Contexto da requisição sem cancelamento, usado pela resposta assíncrona e
+	// pelo título: mantém o trace, o request ID e os campos de log
+	detachedCtx context.Context
+}
+
+// ChatOptions configura o processamento de mensagens do ChatController
+type ChatOptions struct {
+	AsyncWorkers    int           // Workers que processam mensagens assíncronas
+	AsyncQueueSize  int           // Mensagens aguardando um worker antes de recusar com 503
+	AsyncJobTimeout time.Duration // Tempo máximo de cada mensagem assíncrona (0 = sem limite)
+	CallbackSecret  string        // Quando configurado, assina os callbacks com HMAC-SHA256
+	AutoTitle       string        // Geração do título após a primeira resposta: backend, heuristic ou off
+
+	// Permite callbacks para endereços internos (loopback, rede privada, link-local);
+	// apenas para desenvolvimento, pois expõe a rede interna a qualquer usuário
+	CallbackAllowPrivateNetworks bool
+
+	Authenticator WSAuthenticator // Valida de novo o token das conexões WebSocket a cada evento
+	Connections   *Connections    // Conexões WebSocket encerradas quando as credenciais são revogadas
+
+	ActiveUsersWindow   time.Duration // Usuários que enviaram mensagem neste período contam como ativos
+	ActiveUsersInterval time.Duration // Frequência do cálculo de active_users_total (0 = desabilitado)
 }
 
 // ChatController gerencia as conversas
 type ChatController struct {
-	conversationsCollection *mongo.Collection
-	messagesCollection      *mongo.Collection
+	conversations repository.ConversationRepository
+	messages      repository.MessageRepository
+	backend       assistant.Backend
+	hub           *chatHub
+	options       ChatOptions
+	jobs          chan chatJob
+	instanceID    string // Dono dos leases das respostas pendentes criadas por esta instância
+
+	callbackClient *http.Client
 }
 
-// NewChatController cria uma nova instância do controller
-func NewChatController() *ChatController {
-	return &ChatController{
-		conversationsCollection: database.GetCollection("conversations"),
-		messagesCollection:      database.GetCollection("messages"),
-	}
+// NewChatController cria uma nova instância do controller usando os repositórios e o
+// backend informados, encerra as mensagens assíncronas que ficaram pendentes e
+// inicia a renovação dos leases e os workers
+func NewChatController(conversations repository.ConversationRepository, messages repository.MessageRepository, backend assistant.Backend, options ChatOptions) *ChatController {
+	ctrl := &ChatController{
+		conversations: conversations,
+		messages:      messages,
+		backend:       backend,
+		hub:           newChatHub(),
+		options:       options,
+		jobs:          make(chan chatJob, options.AsyncQueueSize),
+		instanceID:    primitive.NewObjectID().Hex(),
+
+		callbackClient: newCallbackClient(options.CallbackAllowPrivateNetworks),
+	}
+	ctrl.startJobLeases()
+	ctrl.startWorkers()
+	ctrl.startActiveUsersGauge()
+	return ctrl
 }
 
 // SendMessage godoc
 // @Summary      Enviar mensagem para o chatbot
 // @Description  Envia uma mensagem e recebe a resposta do chatbot. Cria nova conversa ou continua existente.
+// @Description  Com "async": true responde 202 imediatamente com o ID do job (consultado em /api/v1/jobs/{id}).
 // @Tags         chat
 // @Accept       json
 // @Produce      json
 // @Param        request  body      ChatRequest  true  "Mensagem do usuário"
 // @Success      200      {object}  ChatResponse
+// @Success      202      {object}  ChatJobResponse
 // @Failure      400      {object}  map[string]string
 // @Failure      500      {object}  map[string]string
+// @Failure      502      {object}  map[string]string
+// @Failure      503      {object}  map[string]string
+// @Failure      504      {object}  map[string]string
 // @Router       /api/v1/chat [post]
 func (ctrl *ChatController) SendMessage(c *gin.Context) {
 	var req ChatRequest
@@ -92,109 +177,62 @@ func (ctrl *ChatController) SendMessage(c *gin.Context) {
 		return
 	}
 
-	// Obter user_id do contexto (setado pelo middleware de autenticação)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
-		return
-	}
-
-	ctx := context.Background()
-	startTime := time.Now()
-
-	// 1. Obter ou criar conversa
-	var conversationID primitive.ObjectID
-	var err error
-
-	if req.ConversationID != "" {
-		conversationID, err = primitive.ObjectIDFromHex(req.ConversationID)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de conversa inválido"})
+	if req.CallbackURL != "" {
+		if !req.Async {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "callbackUrl só pode ser usado com async"})
 			return
 		}
-
-		// Verificar se a conversa existe E pertence ao usuário
-		var conversation models.Conversation
-		err = ctrl.conversationsCollection.FindOne(ctx, bson.M{
-			"_id":    conversationID,
-			"userId": userID.(string), // Filtrar por userId
-		}).Decode(&conversation)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Conversa não encontrada ou acesso negado"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar conversa"})
-			}
-			return
-		}
-
-		// Atualizar updatedAt
-		ctrl.conversationsCollection.UpdateOne(
-			ctx,
-			bson.M{"_id": conversationID, "userId": userID.(string)},
-			bson.M{"$set": bson.M{"updatedAt": time.Now()}},
-		)
-	} else {
-		// Criar nova conversa com o userId do usuário autenticado
-		conversation := models.NewConversation(userID.(string))
-		result, err := ctrl.conversationsCollection.InsertOne(ctx, conversation)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar conversa"})
+		if err := validateCallbackURL(req.CallbackURL, ctrl.options.CallbackAllowPrivateNetworks); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		conversationID = result.InsertedID.(primitive.ObjectID)
 	}
 
-	// 2. Salvar mensagem do usuário
-	userMessage := models.NewMessage(conversationID, models.RoleUser, req.Message)
-	_, err = ctrl.messagesCollection.InsertOne(ctx, userMessage)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar mensagem do usuário"})
+	// Obter user_id do contexto (setado pelo middleware de autenticação)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
 		return
 	}
 
-	// 3. Buscar histórico recente (últimas 10 mensagens)
-	history, err := ctrl.getConversationHistory(ctx, conversationID, 10)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar histórico"})
-		return
-	}
+	// O contexto da requisição interrompe a chamada ao backend se o cliente desistir
+	ctx := c.Request.Context()
+	startTime := time.Now()
 
-	// 4. Chamar webhook do n8n
-	n8nRequest := N8NRequest{
-		Message:        req.Message,
-		ConversationID: conversationID.Hex(),
-		History:        history,
+	// 1-3. Obter ou criar conversa, salvar mensagem do usuário e buscar histórico
+	turn, chatErr := ctrl.prepareChat(ctx, userID.(string), req)
+	if chatErr != nil {
+		chatErr.respond(c)
+		return
 	}
+	logConversation(c, turn.conversationID)
+	ctx = c.Request.Context()
 
-	n8nResponse, err := ctrl.callN8NWebhook(n8nRequest)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Erro ao chamar n8n: %v", err)})
+	// Modo assíncrono: a resposta é gerada por um worker
+	if req.Async {
+		pending, chatErr := ctrl.enqueueAsyncReply(ctx, turn, req.CallbackURL, startTime)
+		if chatErr != nil {
+			chatErr.respond(c)
+			return
+		}
+		c.JSON(http.StatusAccepted, newChatJobResponse(pending))
 		return
 	}
 
-	// 5. Calcular latência
-	latencyMs := time.Since(startTime).Milliseconds()
-
-	// 6. Salvar resposta do assistente
-	botResponse := n8nResponse.GetResponse()
-	assistantMessage := models.NewMessage(conversationID, models.RoleAssistant, botResponse)
-	assistantMessage.LatencyMs = latencyMs
-	assistantMessage.Metadata = n8nResponse.Metadata
-
-	result, err := ctrl.messagesCollection.InsertOne(ctx, assistantMessage)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar resposta do assistente"})
+	// 4-6. Chamar o backend do assistente e salvar a resposta
+	assistantMessage, chatErr := ctrl.generateReply(ctx, turn, startTime)
+	if chatErr != nil {
+		chatErr.respond(c)
 		return
 	}
 
 	// 7. Retornar resposta
 	response := ChatResponse{
-		ConversationID: conversationID.Hex(),
-		Message:        botResponse,
+		ConversationID: turn.conversationID.Hex(),
+		Message:        assistantMessage.Content,
 		Role:           models.RoleAssistant,
-		MessageID:      result.InsertedID.(primitive.ObjectID).Hex(),
-		LatencyMs:      latencyMs,
+		MessageID:      assistantMessage.ID.Hex(),
+		LatencyMs:      assistantMessage.LatencyMs,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -202,11 +240,16 @@ func (ctrl *ChatController) SendMessage(c *gin.Context) {
 
 // GetConversationHistory godoc
 // @Summary      Obter histórico de conversa
-// @Description  Retorna todas as mensagens de uma conversa específica
+// @Description  Retorna as mensagens de uma conversa em ordem cronológica, paginadas por ID de mensagem.
+// @Description  Sem cursor retorna as mais recentes; use "before" com o nextCursor para carregar mensagens anteriores
+// @Description  ou "after" para buscar mensagens novas.
 // @Tags         chat
 // @Accept       json
 // @Produce      json
-// @Param        id    path      string  true  "Conversation ID"
+// @Param        id      path      string  true   "Conversation ID"
+// @Param        limit   query     int     false  "Mensagens por página (padrão 50, máximo 200)"
+// @Param        before  query     string  false  "Retorna mensagens anteriores a este ID"
+// @Param        after   query     string  false  "Retorna mensagens posteriores a este ID"
 // @Success      200   {object}  map[string]interface{}
 // @Failure      400   {object}  map[string]string
 // @Failure      404   {object}  map[string]string
@@ -219,6 +262,7 @@ func (ctrl *ChatController) GetConversationHistory(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de conversa inválido"})
 		return
 	}
+	logConversation(c, objectID)
 
 	// Obter user_id do contexto
 	userID, exists := c.Get("user_id")
@@ -227,16 +271,31 @@ func (ctrl *ChatController) GetConversationHistory(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	// Parâmetros de paginação
+	page := repository.MessagePage{}
+	if page.Limit, err = parseLimit(c, defaultMessagesLimit, maxMessagesLimit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page.Before, err = parseObjectIDQuery(c, "before"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page.After, err = parseObjectIDQuery(c, "after"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page.Before != nil && page.After != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use apenas before ou after"})
+		return
+	}
+
+	ctx := requestContext(c)
 
 	// Verificar se a conversa existe E pertence ao usuário
-	var conversation models.Conversation
-	err = ctrl.conversationsCollection.FindOne(ctx, bson.M{
-		"_id":    objectID,
-		"userId": userID.(string),
-	}).Decode(&conversation)
+	conversation, err := ctrl.conversations.FindOwned(ctx, objectID, userID.(string))
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Conversa não encontrada ou acesso negado"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar conversa"})
@@ -244,26 +303,58 @@ func (ctrl *ChatController) GetConversationHistory(c *gin.Context) {
 		return
 	}
 
-	// Buscar todas as mensagens da conversa
-	messages, err := ctrl.getConversationHistory(ctx, objectID, 0)
+	// Buscar a página de mensagens da conversa (incluindo o histórico herdado de bifurcações)
+	messages, hasMore, err := ctrl.findMessages(ctx, objectID, page)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar mensagens"})
 		return
 	}
 
+	var total int64
+	segments, err := ctrl.messageSegments(ctx, objectID)
+	if err == nil {
+		total, err = ctrl.messages.Count(ctx, segments)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao contar mensagens"})
+		return
+	}
+
+	// O próximo cursor continua na mesma direção: mensagens mais antigas
+	// (before) ou mais novas (after)
+	var nextCursor *string
+	if hasMore && len(messages) > 0 {
+		next := messages[0].ID.Hex()
+		if page.After != nil {
+			next = messages[len(messages)-1].ID.Hex()
+		}
+		nextCursor = &next
+	}
+
+	if messages == nil {
+		messages = []models.Message{}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"conversation": conversation,
 		"messages":     messages,
+		"total":        total,
+		"hasMore":      hasMore,
+		"nextCursor":   nextCursor,
 	})
 }
 
 // ListConversations godoc
 // @Summary      Listar conversas
-// @Description  Lista todas as conversas ordenadas por data de atualização
+// @Description  Lista as conversas ordenadas por data de atualização (mais recentes primeiro), paginadas por cursor.
+// @Description  Envie o nextCursor da resposta no parâmetro "cursor" para buscar a próxima página.
 // @Tags         chat
 // @Accept       json
 // @Produce      json
+// @Param        limit   query     int     false  "Conversas por página (padrão 20, máximo 100)"
+// @Param        cursor  query     string  false  "Cursor retornado em nextCursor"
 // @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /api/v1/conversations [get]
 func (ctrl *ChatController) ListConversations(c *gin.Context) {
@@ -274,28 +365,47 @@ func (ctrl *ChatController) ListConversations(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	limit, err := parseLimit(c, defaultConversationsLimit, maxConversationsLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Ordenar por updatedAt descendente (mais recentes primeiro)
-	findOptions := options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}})
+	var after *repository.ConversationCursor
+	if value := c.Query("cursor"); value != "" {
+		if after, err = decodeConversationCursor(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ctx := requestContext(c)
 
-	// Filtrar APENAS conversas do usuário autenticado
-	cursor, err := ctrl.conversationsCollection.Find(ctx, bson.M{"userId": userID.(string)}, findOptions)
+	// APENAS conversas do usuário autenticado, mais recentes primeiro. Um item a
+	// mais indica se existe próxima página.
+	conversations, err := ctrl.conversations.List(ctx, userID.(string), after, limit+1)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar conversas"})
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var conversations []models.Conversation
-	if err = cursor.All(ctx, &conversations); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao decodificar conversas"})
+	var nextCursor *string
+	if int64(len(conversations)) > limit {
+		conversations = conversations[:limit]
+		next := encodeConversationCursor(&conversations[len(conversations)-1])
+		nextCursor = &next
+	}
+
+	total, err := ctrl.conversations.CountByUser(ctx, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao contar conversas"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"conversations": conversations,
-		"total":         len(conversations),
+		"total":         total,
+		"nextCursor":    nextCursor,
 	})
 }
 
@@ -320,6 +430,7 @@ func (ctrl *ChatController) UpdateConversationTitle(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de conversa inválido"})
 		return
 	}
+	logConversation(c, objectID)
 
 	// Obter user_id do contexto
 	userID, exists := c.Get("user_id")
@@ -337,25 +448,13 @@ func (ctrl *ChatController) UpdateConversationTitle(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
-
-	// Atualizar o título APENAS se a conversa pertence ao usuário
-	update := bson.M{
-		"$set": bson.M{
-			"title":     request.Title,
-			"updatedAt": time.Now(),
-		},
-	}
+	ctx := requestContext(c)
 
-	result := ctrl.conversationsCollection.FindOneAndUpdate(
-		ctx,
-		bson.M{"_id": objectID, "userId": userID.(string)}, // Filtrar por userId
-		update,
-		options.FindOneAndUpdate().SetReturnDocument(options.After),
-	)
-
-	if result.Err() != nil {
-		if result.Err() == mongo.ErrNoDocuments {
+	// Atualizar o título APENAS se a conversa pertence ao usuário; o título
+	// definido pelo usuário não é substituído pelo título automático
+	conversation, err := ctrl.conversations.UpdateTitle(ctx, objectID, userID.(string), request.Title)
+	if err != nil {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Conversa não encontrada ou acesso negado"})
 			return
 		}
@@ -363,18 +462,13 @@ func (ctrl *ChatController) UpdateConversationTitle(c *gin.Context) {
 		return
 	}
 
-	var conversation models.Conversation
-	if err := result.Decode(&conversation); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao decodificar conversa"})
-		return
-	}
-
 	c.JSON(http.StatusOK, conversation)
 }
 
 // DeleteConversation godoc
 // @Summary      Deletar conversa
-// @Description  Deleta uma conversa e todas suas mensagens
+// @Description  Deleta uma conversa e todas suas mensagens. Mensagens compartilhadas com conversas bifurcadas
+// @Description  a partir dela são mantidas até que as bifurcações também sejam deletadas.
 // @Tags         chat
 // @Accept       json
 // @Produce      json
@@ -392,6 +486,7 @@ func (ctrl *ChatController) DeleteConversation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de conversa inválido"})
 		return
 	}
+	logConversation(c, objectID)
 
 	// Obter user_id do contexto
 	userID, exists := c.Get("user_id")
@@ -400,33 +495,17 @@ func (ctrl *ChatController) DeleteConversation(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := requestContext(c)
 
 	// Verificar se a conversa existe E pertence ao usuário
-	var conversation models.Conversation
-	err = ctrl.conversationsCollection.FindOne(ctx, bson.M{
-		"_id":    objectID,
-		"userId": userID.(string),
-	}).Decode(&conversation)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Conversa não encontrada ou acesso negado"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar conversa"})
-		return
-	}
-
-	// Deletar todas as mensagens da conversa
-	_, err = ctrl.messagesCollection.DeleteMany(ctx, bson.M{"conversationId": objectID})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar mensagens"})
+	conversation, chatErr := ctrl.findOwnedConversation(ctx, userID.(string), objectID)
+	if chatErr != nil {
+		chatErr.respond(c)
 		return
 	}
 
-	// Deletar a conversa
-	_, err = ctrl.conversationsCollection.DeleteOne(ctx, bson.M{"_id": objectID, "userId": userID.(string)})
-	if err != nil {
+	// Deletar a conversa e suas mensagens (preservando o histórico usado por bifurcações)
+	if err := ctrl.deleteConversation(ctx, conversation); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar conversa"})
 		return
 	}
@@ -434,68 +513,192 @@ func (ctrl *ChatController) DeleteConversation(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Conversa deletada com sucesso"})
 }
 
-// getConversationHistory busca o histórico de mensagens de uma conversa
-func (ctrl *ChatController) getConversationHistory(ctx context.Context, conversationID primitive.ObjectID, limit int64) ([]models.Message, error) {
-	filter := bson.M{"conversationId": conversationID}
+// prepareChat obtém ou cria a conversa do usuário, salva a mensagem enviada e
+// retorna o histórico recente (últimas 10 mensagens) a ser enviado ao backend
+func (ctrl *ChatController) prepareChat(ctx context.Context, userID string, req ChatRequest) (*chatTurn, *chatError) {
+	var conversationID primitive.ObjectID
+	var err error
 
-	findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
-	if limit > 0 {
-		findOptions.SetLimit(limit)
+	if req.ConversationID != "" {
+		conversationID, err = primitive.ObjectIDFromHex(req.ConversationID)
+		if err != nil {
+			return nil, &chatError{status: http.StatusBadRequest, message: "ID de conversa inválido"}
+		}
+
+		// Verificar se a conversa existe E pertence ao usuário
+		if _, chatErr := ctrl.findOwnedConversation(ctx, userID, conversationID); chatErr != nil {
+			return nil, chatErr
+		}
+
+		// Atualizar updatedAt
+		ctrl.conversations.Touch(ctx, conversationID)
+	} else {
+		// Criar nova conversa com o userId do usuário autenticado
+		conversation := models.NewConversation(userID)
+		if err := ctrl.conversations.Create(ctx, conversation); err != nil {
+			return nil, &chatError{status: http.StatusInternalServerError, message: "Erro ao criar conversa"}
+		}
+		conversationID = conversation.ID
+		metrics.RecordConversationCreated("chat")
 	}
 
-	cursor, err := ctrl.messagesCollection.Find(ctx, filter, findOptions)
-	if err != nil {
-		return nil, err
+	// Logs da resposta assíncrona e do título identificam a conversa
+	ctx = logging.With(ctx, "conversation_id", conversationID.Hex())
+
+	// Salvar mensagem do usuário
+	userMessage := models.NewMessage(conversationID, models.RoleUser, req.Message)
+	if err := ctrl.messages.Create(ctx, userMessage); err != nil {
+		return nil, &chatError{status: http.StatusInternalServerError, message: "Erro ao salvar mensagem do usuário"}
 	}
-	defer cursor.Close(ctx)
+	metrics.RecordChatMessage(string(models.RoleUser))
 
-	var messages []models.Message
-	if err = cursor.All(ctx, &messages); err != nil {
-		return nil, err
+	// Buscar histórico recente (últimas 10 mensagens)
+	history, err := ctrl.getConversationHistory(ctx, conversationID, 10)
+	if err != nil {
+		return nil, &chatError{status: http.StatusInternalServerError, message: "Erro ao buscar histórico"}
 	}
 
-	// Reverter a ordem para ter as mensagens mais antigas primeiro
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+	// Respostas pendentes ou que falharam não fazem parte do contexto enviado ao backend
+	completed := history[:0]
+	for _, msg := range history {
+		if msg.IsCompleted() {
+			completed = append(completed, msg)
+		}
 	}
+	history = completed
 
-	return messages, nil
+	return &chatTurn{
+		conversationID: conversationID,
+		userMessage:    userMessage,
+		history:        history,
+		detachedCtx:    context.WithoutCancel(ctx),
+	}, nil
 }
 
-// callN8NWebhook chama o webhook do n8n
-func (ctrl *ChatController) callN8NWebhook(request N8NRequest) (*N8NResponse, error) {
-	jsonData, err := json.Marshal(request)
+// generateReply chama o backend do assistente com o histórico do turno e salva a resposta.
+// A latência é medida a partir de startTime.
+func (ctrl *ChatController) generateReply(ctx context.Context, turn *chatTurn, startTime time.Time) (*models.Message, *chatError) {
+	backendResponse, err := ctrl.backend.Send(ctx, turn.request())
 	if err != nil {
-		return nil, err
+		return nil, newBackendError(ctx, ctrl.backend.Name(), err)
 	}
 
-	resp, err := http.Post(N8N_WEBHOOK_URL, "application/json", bytes.NewBuffer(jsonData))
+	latencyMs := time.Since(startTime).Milliseconds()
+
+	// A resposta já foi gerada: salvar mesmo que o cliente tenha desconectado
+	assistantMessage, err := ctrl.saveAssistantMessage(context.WithoutCancel(ctx), turn.conversationID, backendResponse, latencyMs)
 	if err != nil {
-		return nil, err
+		return nil, &chatError{status: http.StatusInternalServerError, message: "Erro ao salvar resposta do assistente"}
 	}
-	defer resp.Body.Close()
+	ctrl.scheduleAutoTitle(turn, assistantMessage)
+	return assistantMessage, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("n8n retornou status %d: %s", resp.StatusCode, string(body))
+// request monta a requisição ao backend para o turno
+func (t *chatTurn) request() assistant.Request {
+	return assistant.Request{
+		Message:        t.userMessage.Content,
+		ConversationID: t.conversationID.Hex(),
+		History:        t.history,
 	}
+}
 
-	body, err := io.ReadAll(resp.Body)
+// findOwnedConversation busca a conversa garantindo que pertence ao usuário
+func (ctrl *ChatController) findOwnedConversation(ctx context.Context, userID string, conversationID primitive.ObjectID) (*models.Conversation, *chatError) {
+	conversation, err := ctrl.conversations.FindOwned(ctx, conversationID, userID)
 	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, &chatError{status: http.StatusForbidden, message: "Conversa não encontrada ou acesso negado"}
+		}
+		return nil, &chatError{status: http.StatusInternalServerError, message: "Erro ao buscar conversa"}
+	}
+	return conversation, nil
+}
+
+// saveAssistantMessage salva a resposta do backend como mensagem do assistente
+func (ctrl *ChatController) saveAssistantMessage(ctx context.Context, conversationID primitive.ObjectID, resp *assistant.Response, latencyMs int64) (*models.Message, error) {
+	now := time.Now()
+	assistantMessage := models.NewMessage(conversationID, models.RoleAssistant, resp.Content)
+	assistantMessage.LatencyMs = latencyMs
+	assistantMessage.Metadata = resp.Metadata
+	assistantMessage.Status = models.StatusCompleted
+	assistantMessage.CompletedAt = &now
+
+	if err := ctrl.messages.Create(ctx, assistantMessage); err != nil {
 		return nil, err
 	}
+	metrics.RecordChatMessage(string(models.RoleAssistant))
+	return assistantMessage, nil
+}
+
+// getConversationHistory busca as últimas mensagens de uma conversa (limit 0 = todas)
+func (ctrl *ChatController) getConversationHistory(ctx context.Context, conversationID primitive.ObjectID, limit int64) ([]models.Message, error) {
+	messages, _, err := ctrl.findMessages(ctx, conversationID, repository.MessagePage{Limit: limit})
+	return messages, err
+}
 
-	// Tentar primeiro como array (formato do N8N)
-	var n8nArray []N8NResponse
-	if err := json.Unmarshal(body, &n8nArray); err == nil && len(n8nArray) > 0 {
-		return &n8nArray[0], nil
+// maxForkDepth limita a cadeia de conversas de origem percorrida ao montar o histórico
+const maxForkDepth = 32
+
+// messageSegments retorna os segmentos de mensagens que compõem a conversa: as suas e,
+// em conversas bifurcadas, as das conversas de origem até o ponto da bifurcação.
+// Como ObjectIDs seguem a ordem de criação, ordenar por _id intercala corretamente
+// o histórico herdado e as mensagens da própria conversa.
+func (ctrl *ChatController) messageSegments(ctx context.Context, conversationID primitive.ObjectID) ([]repository.Segment, error) {
+	segments := []repository.Segment{{ConversationID: conversationID}}
+
+	// Cada conversa de origem contribui com as mensagens até o menor ponto de
+	// bifurcação visto (a bifurcação pode partir de uma mensagem herdada)
+	var upTo *primitive.ObjectID
+	currentID := conversationID
+	for depth := 0; depth < maxForkDepth; depth++ {
+		conversation, err := ctrl.conversations.FindByID(ctx, currentID)
+		if err == repository.ErrNotFound {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if conversation.ParentConversationID == nil || conversation.ForkedFromMessageID == nil {
+			break
+		}
+
+		if upTo == nil || bytes.Compare(conversation.ForkedFromMessageID[:], upTo[:]) < 0 {
+			upTo = conversation.ForkedFromMessageID
+		}
+		currentID = *conversation.ParentConversationID
+		segments = append(segments, repository.Segment{ConversationID: currentID, UpTo: upTo})
 	}
+	return segments, nil
+}
 
-	// Se não for array, tentar como objeto direto
-	var n8nResponse N8NResponse
-	if err := json.Unmarshal(body, &n8nResponse); err != nil {
-		return nil, fmt.Errorf("erro ao parsear resposta do n8n: %v (body: %s)", err, string(body))
+// findMessages busca uma página de mensagens em ordem cronológica e indica se
+// há mais mensagens na direção paginada
+func (ctrl *ChatController) findMessages(ctx context.Context, conversationID primitive.ObjectID, page repository.MessagePage) ([]models.Message, bool, error) {
+	segments, err := ctrl.messageSegments(ctx, conversationID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Buscar um item a mais para saber se existe próxima página
+	limit := page.Limit
+	if limit > 0 {
+		page.Limit = limit + 1
+	}
+	messages, err := ctrl.messages.List(ctx, segments, page)
+	if err != nil {
+		return nil, false, err
 	}
 
-	return &n8nResponse, nil
+	// O item a mais é o mais distante do cursor: o mais novo depois de "after"
+	// ou o mais antigo nos demais casos
+	hasMore := limit > 0 && int64(len(messages)) > limit
+	if hasMore {
+		if page.After != nil {
+			messages = messages[:limit]
+		} else {
+			messages = messages[1:]
+		}
+	}
+	return messages, hasMore, nil
 }
@@ -0,0 +1,134 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"chatserver/assistant"
+	"chatserver/controllers"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSendMessageRequiresAuthentication(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(t, http.MethodPost, "/api/v1/chat", "", controllers.ChatRequest{Message: "oi"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSendMessageEcho(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "eco@example.com", "secret123")
+
+	rec := s.do(t, http.MethodPost, "/api/v1/chat", user.Token, controllers.ChatRequest{Message: "X"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	response := decodeResponse[controllers.ChatResponse](t, rec)
+	if response.Message != "Echo: X" || response.Role != models.RoleAssistant || response.MessageID == "" {
+		t.Errorf("resposta %+v, esperado a mensagem %q do assistente", response, "Echo: X")
+	}
+
+	// A mensagem do usuário e a resposta ficam salvas na conversa, nesta ordem
+	conversationID, err := primitive.ObjectIDFromHex(response.ConversationID)
+	if err != nil {
+		t.Fatalf("conversationId %q: %v", response.ConversationID, err)
+	}
+	if _, err := s.repos.Conversations.FindOwned(context.Background(), conversationID, user.UserID); err != nil {
+		t.Fatalf("conversa do usuário: %v", err)
+	}
+	messages, err := s.repos.Messages.List(context.Background(), []repository.Segment{{ConversationID: conversationID}}, repository.MessagePage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("%d mensagens salvas, esperado 2", len(messages))
+	}
+	if messages[0].Role != models.RoleUser || messages[0].Content != "X" {
+		t.Errorf("primeira mensagem: %s %q, esperado %s %q", messages[0].Role, messages[0].Content, models.RoleUser, "X")
+	}
+	if messages[1].Role != models.RoleAssistant || messages[1].Content != "Echo: X" || messages[1].ID.Hex() != response.MessageID {
+		t.Errorf("segunda mensagem: %s %q (%s), esperado %s %q (%s)",
+			messages[1].Role, messages[1].Content, messages[1].ID.Hex(), models.RoleAssistant, "Echo: X", response.MessageID)
+	}
+}
+
+func TestSendMessageContinuesConversation(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "eva@example.com", "secret123")
+
+	rec := s.do(t, http.MethodPost, "/api/v1/chat", user.Token, controllers.ChatRequest{Message: "primeira"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("primeira mensagem: status %d, body %s", rec.Code, rec.Body)
+	}
+	first := decodeResponse[controllers.ChatResponse](t, rec)
+
+	rec = s.do(t, http.MethodPost, "/api/v1/chat", user.Token, controllers.ChatRequest{ConversationID: first.ConversationID, Message: "segunda"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("segunda mensagem: status %d, body %s", rec.Code, rec.Body)
+	}
+	second := decodeResponse[controllers.ChatResponse](t, rec)
+	if second.ConversationID != first.ConversationID {
+		t.Errorf("conversa %q, esperado %q", second.ConversationID, first.ConversationID)
+	}
+
+	// Conversa de outro usuário é recusada
+	other := s.register(t, "fabio@example.com", "secret123")
+	rec = s.do(t, http.MethodPost, "/api/v1/chat", other.Token, controllers.ChatRequest{ConversationID: first.ConversationID, Message: "intrusa"})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("conversa de outro usuário: status %d, esperado %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestNewChatControllerFailsStaleJobs(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	conversationID := primitive.NewObjectID()
+
+	// Pendente de uma instância que parou: o lease não foi renovado
+	expired := time.Now().Add(-time.Second)
+	stale := models.NewMessage(conversationID, models.RoleAssistant, "")
+	stale.Status = models.StatusPending
+	stale.LeaseOwner = "parada"
+	stale.LeaseUntil = &expired
+	// Processada por outra instância em execução, mesmo que criada há muito tempo
+	leased := time.Now().Add(time.Minute)
+	active := models.NewMessage(conversationID, models.RoleAssistant, "")
+	active.ID = primitive.NewObjectIDFromTimestamp(time.Now().Add(-time.Hour))
+	active.Status = models.StatusPending
+	active.LeaseOwner = "ativa"
+	active.LeaseUntil = &leased
+	for _, message := range []*models.Message{stale, active} {
+		if err := repos.Messages.Create(ctx, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	controllers.NewChatController(repos.Conversations, repos.Messages, assistant.NewEchoBackend(), controllers.ChatOptions{
+		AsyncWorkers:    1,
+		AsyncQueueSize:  10,
+		AsyncJobTimeout: 0, // Sem limite de processamento: o prazo vem só do lease
+	})
+
+	message, err := repos.Messages.FindByID(ctx, stale.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Status != models.StatusFailed || message.Error == "" || message.CompletedAt == nil {
+		t.Errorf("lease vencido: status %q, erro %q, esperado failed com o motivo", message.Status, message.Error)
+	}
+	message, err = repos.Messages.FindByID(ctx, active.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Status != models.StatusPending {
+		t.Errorf("lease válido: status %q, esperado %q", message.Status, models.StatusPending)
+	}
+}
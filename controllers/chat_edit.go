@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"chatserver/assistant"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EditMessageRequest representa a edição de uma mensagem do usuário
+type EditMessageRequest struct {
+	Content    string `json:"content" binding:"required"`
+	Regenerate bool   `json:"regenerate,omitempty"` // Regenerar a resposta do assistente a esta mensagem
+}
+
+// EditMessageResponse representa o resultado da edição
+type EditMessageResponse struct {
+	Message *models.Message `json:"message"`         // Mensagem do usuário editada
+	Reply   *models.Message `json:"reply,omitempty"` // Nova resposta do assistente (quando regenerada)
+}
+
+// EditMessage godoc
+// @Summary      Editar mensagem do usuário
+// @Description  Altera o conteúdo da última mensagem enviada pelo usuário, mantendo o texto anterior em "versions".
+// @Description  Com "regenerate": true a resposta do assistente a esta mensagem é gerada novamente.
+// @Description  Retorna 409 para mensagens anteriores e para edições simultâneas da mesma mensagem.
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string              true  "Conversation ID"
+// @Param        messageId  path      string              true  "Message ID"
+// @Param        request    body      EditMessageRequest  true  "Novo conteúdo"
+// @Success      200        {object}  EditMessageResponse
+// @Failure      400        {object}  map[string]string
+// @Failure      403        {object}  map[string]string
+// @Failure      404        {object}  map[string]string
+// @Failure      409        {object}  map[string]string
+// @Failure      500        {object}  map[string]string
+// @Failure      502        {object}  map[string]string
+// @Router       /api/v1/conversations/{id}/messages/{messageId} [put]
+func (ctrl *ChatController) EditMessage(c *gin.Context) {
+	conversationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de conversa inválido"})
+		return
+	}
+	logConversation(c, conversationID)
+
+	messageID, err := primitive.ObjectIDFromHex(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de mensagem inválido"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+
+	var req EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	startTime := time.Now()
+
+	if _, chatErr := ctrl.findOwnedConversation(ctx, userID.(string), conversationID); chatErr != nil {
+		chatErr.respond(c)
+		return
+	}
+
+	message, err := ctrl.messages.FindByID(ctx, messageID)
+	if err == nil && message.ConversationID != conversationID {
+		err = repository.ErrNotFound
+	}
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Mensagem não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar mensagem"})
+		return
+	}
+
+	if message.Role != models.RoleUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Apenas mensagens do usuário podem ser editadas"})
+		return
+	}
+
+	// As mensagens seguintes foram geradas a partir do conteúdo atual: editar
+	// uma mensagem anterior deixaria o restante da conversa sem sentido (para
+	// mudar um ponto anterior, bifurque a conversa)
+	last, err := ctrl.messages.FindLast(ctx, conversationID, models.RoleUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar mensagem"})
+		return
+	}
+	if last.ID != message.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Apenas a última mensagem do usuário pode ser editada"})
+		return
+	}
+
+	// Salvar o novo conteúdo mantendo o anterior como versão
+	now := time.Now()
+	message.AddVersion(req.Content, message.Metadata, 0)
+	message.EditedAt = &now
+	if chatErr := ctrl.saveMessageVersion(ctx, message); chatErr != nil {
+		chatErr.respond(c)
+		return
+	}
+	ctrl.touchConversation(ctx, conversationID)
+
+	response := EditMessageResponse{Message: message}
+
+	if req.Regenerate {
+		reply, chatErr := ctrl.regenerateReplyTo(ctx, message, startTime)
+		if chatErr != nil {
+			chatErr.respond(c)
+			return
+		}
+		response.Reply = reply
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RegenerateReply godoc
+// @Summary      Regenerar última resposta
+// @Description  Gera novamente a última resposta do assistente usando o histórico até aquele ponto.
+// @Description  A resposta anterior é mantida em "versions" e "versionIndex" aponta para a nova.
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Conversation ID"
+// @Success      200  {object}  models.Message
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      502  {object}  map[string]string
+// @Router       /api/v1/conversations/{id}/regenerate [post]
+func (ctrl *ChatController) RegenerateReply(c *gin.Context) {
+	conversationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de conversa inválido"})
+		return
+	}
+	logConversation(c, conversationID)
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	startTime := time.Now()
+
+	if _, chatErr := ctrl.findOwnedConversation(ctx, userID.(string), conversationID); chatErr != nil {
+		chatErr.respond(c)
+		return
+	}
+
+	// Última resposta do assistente
+	reply, err := ctrl.messages.FindLast(ctx, conversationID, models.RoleAssistant)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Nenhuma resposta do assistente para regenerar"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar resposta"})
+		return
+	}
+
+	if reply.Status == models.StatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "A resposta ainda está sendo gerada"})
+		return
+	}
+
+	if chatErr := ctrl.regenerate(ctx, reply, startTime); chatErr != nil {
+		chatErr.respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, reply)
+}
+
+// regenerateReplyTo gera novamente a resposta à mensagem do usuário: adiciona uma
+// versão à resposta seguinte ou, se não houver, cria uma nova resposta
+func (ctrl *ChatController) regenerateReplyTo(ctx context.Context, userMessage *models.Message, startTime time.Time) (*models.Message, *chatError) {
+	reply, err := ctrl.messages.FindNext(ctx, userMessage.ConversationID, userMessage.ID)
+
+	switch {
+	case err == nil && reply.Role == models.RoleAssistant:
+		if reply.Status == models.StatusPending {
+			return nil, &chatError{status: http.StatusConflict, message: "A resposta ainda está sendo gerada"}
+		}
+		if chatErr := ctrl.regenerate(ctx, reply, startTime); chatErr != nil {
+			return nil, chatErr
+		}
+		return reply, nil
+	case err != nil && err != repository.ErrNotFound:
+		return nil, &chatError{status: http.StatusInternalServerError, message: "Erro ao buscar resposta"}
+	}
+
+	// Sem resposta logo após a mensagem: gerar uma nova
+	history, chatErr := ctrl.historyBefore(ctx, userMessage.ConversationID, userMessage.ID)
+	if chatErr != nil {
+		return nil, chatErr
+	}
+	turn := &chatTurn{
+		conversationID: userMessage.ConversationID,
+		userMessage:    userMessage,
+		history:        append(history, *userMessage),
+		detachedCtx:    context.WithoutCancel(ctx),
+	}
+	return ctrl.generateReply(ctx, turn, startTime)
+}
+
+// regenerate chama o backend com o histórico anterior à resposta e salva o
+// resultado como nova versão
+func (ctrl *ChatController) regenerate(ctx context.Context, reply *models.Message, startTime time.Time) *chatError {
+	history, chatErr := ctrl.historyBefore(ctx, reply.ConversationID, reply.ID)
+	if chatErr != nil {
+		return chatErr
+	}
+
+	// A mensagem respondida é a última mensagem do usuário antes da resposta
+	var userMessage *models.Message
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == models.RoleUser {
+			userMessage = &history[i]
+			break
+		}
+	}
+	if userMessage == nil {
+		return &chatError{status: http.StatusBadRequest, message: "Não há mensagem do usuário antes desta resposta"}
+	}
+
+	backendResponse, err := ctrl.backend.Send(ctx, assistant.Request{
+		Message:        userMessage.Content,
+		ConversationID: reply.ConversationID.Hex(),
+		History:        history,
+	})
+	if err != nil {
+		return newBackendError(ctx, ctrl.backend.Name(), err)
+	}
+
+	now := time.Now()
+	reply.AddVersion(backendResponse.Content, backendResponse.Metadata, time.Since(startTime).Milliseconds())
+	reply.Status = models.StatusCompleted
+	reply.Error = ""
+	reply.CompletedAt = &now
+
+	// A resposta já foi gerada: salvar mesmo que o cliente tenha desconectado
+	saveCtx := context.WithoutCancel(ctx)
+	if chatErr := ctrl.saveMessageVersion(saveCtx, reply); chatErr != nil {
+		return chatErr
+	}
+	ctrl.touchConversation(saveCtx, reply.ConversationID)
+	return nil
+}
+
+// historyBefore retorna o histórico recente (últimas 10 mensagens concluídas)
+// anterior à mensagem informada
+func (ctrl *ChatController) historyBefore(ctx context.Context, conversationID, messageID primitive.ObjectID) ([]models.Message, *chatError) {
+	messages, _, err := ctrl.findMessages(ctx, conversationID, repository.MessagePage{Limit: 10, Before: &messageID})
+	if err != nil {
+		return nil, &chatError{status: http.StatusInternalServerError, message: "Erro ao buscar histórico"}
+	}
+
+	history := messages[:0]
+	for _, msg := range messages {
+		if msg.IsCompleted() {
+			history = append(history, msg)
+		}
+	}
+	return history, nil
+}
+
+// saveMessageVersion grava a versão adicionada à mensagem, recusando-a se outra
+// requisição editou ou regenerou a mesma mensagem nesse meio tempo
+func (ctrl *ChatController) saveMessageVersion(ctx context.Context, message *models.Message) *chatError {
+	saved, err := ctrl.messages.AddVersion(ctx, message)
+	if err != nil {
+		return &chatError{status: http.StatusInternalServerError, message: "Erro ao salvar mensagem"}
+	}
+	if !saved {
+		return &chatError{status: http.StatusConflict, message: "A mensagem foi alterada por outra requisição"}
+	}
+	return nil
+}
+
+// touchConversation atualiza o updatedAt da conversa
+func (ctrl *ChatController) touchConversation(ctx context.Context, conversationID primitive.ObjectID) {
+	ctrl.conversations.Touch(ctx, conversationID)
+}
@@ -0,0 +1,192 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"chatserver/assistant"
+	"chatserver/controllers"
+	"chatserver/models"
+)
+
+// countingBackend numera as respostas, guardando as requisições, e se
+// blockCall é informado segura essa chamada até release ser fechado
+type countingBackend struct {
+	mu        sync.Mutex
+	calls     int
+	requests  []assistant.Request
+	blockCall int
+	entered   chan struct{}
+	release   chan struct{}
+}
+
+func (b *countingBackend) Name() string {
+	return "counting"
+}
+
+func (b *countingBackend) Send(ctx context.Context, req assistant.Request) (*assistant.Response, error) {
+	b.mu.Lock()
+	b.calls++
+	call := b.calls
+	b.requests = append(b.requests, req)
+	b.mu.Unlock()
+
+	if call == b.blockCall {
+		close(b.entered)
+		<-b.release
+	}
+	return &assistant.Response{Content: fmt.Sprintf("resposta %d para %s", call, req.Message)}, nil
+}
+
+// lastRequest retorna a última requisição recebida
+func (b *countingBackend) lastRequest() assistant.Request {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.requests[len(b.requests)-1]
+}
+
+// history retorna as mensagens da conversa em ordem cronológica
+func (s *testServer) history(t *testing.T, token, conversationID string) []models.Message {
+	t.Helper()
+
+	rec := s.do(t, http.MethodGet, "/api/v1/conversations/"+conversationID, token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("histórico: status %d, body %s", rec.Code, rec.Body)
+	}
+	return decodeResponse[messagesPage](t, rec).Messages
+}
+
+// versionContents retorna o conteúdo das versões da mensagem
+func versionContents(message *models.Message) []string {
+	contents := make([]string, len(message.Versions))
+	for i, version := range message.Versions {
+		contents[i] = version.Content
+	}
+	return contents
+}
+
+func TestEditMessageKeepsVersions(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "bruna@example.com", "secret123")
+	conversationID := s.sendChat(t, user.Token, "", "primeira").ConversationID
+	messages := s.history(t, user.Token, conversationID)
+	path := "/api/v1/conversations/" + conversationID + "/messages/" + messages[0].ID.Hex()
+
+	rec := s.do(t, http.MethodPut, path, user.Token, controllers.EditMessageRequest{Content: "editada", Regenerate: true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("edição: status %d, body %s", rec.Code, rec.Body)
+	}
+	response := decodeResponse[controllers.EditMessageResponse](t, rec)
+	if got := versionContents(response.Message); response.Message.Content != "editada" || response.Message.VersionIndex != 1 ||
+		fmt.Sprint(got) != "[primeira editada]" || response.Message.EditedAt == nil {
+		t.Errorf("mensagem %q, versões %v (índice %d), esperado editada em [primeira editada] (índice 1)", response.Message.Content, got, response.Message.VersionIndex)
+	}
+	if response.Reply == nil || response.Reply.ID != messages[1].ID || response.Reply.Content != "Echo: editada" ||
+		fmt.Sprint(versionContents(response.Reply)) != "[Echo: primeira Echo: editada]" {
+		t.Errorf("resposta %+v, esperado a mesma resposta com a nova versão", response.Reply)
+	}
+
+	// Nova edição sem regenerar: a resposta fica como está
+	rec = s.do(t, http.MethodPut, path, user.Token, controllers.EditMessageRequest{Content: "de novo"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("segunda edição: status %d, body %s", rec.Code, rec.Body)
+	}
+	messages = s.history(t, user.Token, conversationID)
+	if got := versionContents(&messages[0]); fmt.Sprint(got) != "[primeira editada de novo]" || messages[0].VersionIndex != 2 {
+		t.Errorf("versões salvas %v (índice %d), esperado [primeira editada de novo] (índice 2)", got, messages[0].VersionIndex)
+	}
+	if len(messages) != 2 || messages[1].Content != "Echo: editada" {
+		t.Errorf("histórico %+v, esperado a resposta anterior sem nova mensagem", messages)
+	}
+}
+
+func TestEditMessageOnlyLastUserTurn(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "caio@example.com", "secret123")
+	conversationID := s.sendChat(t, user.Token, "", "primeira").ConversationID
+	s.sendChat(t, user.Token, conversationID, "segunda")
+	messages := s.history(t, user.Token, conversationID)
+	path := "/api/v1/conversations/" + conversationID + "/messages/"
+
+	tests := []struct {
+		name      string
+		messageID string
+		status    int
+	}{
+		{"mensagem anterior", messages[0].ID.Hex(), http.StatusConflict},
+		{"resposta do assistente", messages[3].ID.Hex(), http.StatusBadRequest},
+		{"ID inválido", "123", http.StatusBadRequest},
+		{"última mensagem", messages[2].ID.Hex(), http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := s.do(t, http.MethodPut, path+tt.messageID, user.Token, controllers.EditMessageRequest{Content: "editada"})
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, esperado %d", tt.name, rec.Code, tt.status)
+		}
+	}
+
+	if messages := s.history(t, user.Token, conversationID); messages[0].Content != "primeira" || len(messages[0].Versions) != 0 {
+		t.Errorf("mensagem anterior %q com %d versões, esperado sem alteração", messages[0].Content, len(messages[0].Versions))
+	}
+
+	other := s.register(t, "dora@example.com", "secret123")
+	rec := s.do(t, http.MethodPut, path+messages[2].ID.Hex(), other.Token, controllers.EditMessageRequest{Content: "intrusa"})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("conversa de outro usuário: status %d, esperado %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestRegenerateReply(t *testing.T) {
+	backend := &countingBackend{}
+	s := newTestServerWithBackend(t, backend)
+	user := s.register(t, "davi@example.com", "secret123")
+	conversationID := s.sendChat(t, user.Token, "", "oi").ConversationID
+
+	rec := s.do(t, http.MethodPost, "/api/v1/conversations/"+conversationID+"/regenerate", user.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	reply := decodeResponse[models.Message](t, rec)
+	if got := versionContents(&reply); reply.Content != "resposta 2 para oi" || reply.VersionIndex != 1 ||
+		fmt.Sprint(got) != "[resposta 1 para oi resposta 2 para oi]" {
+		t.Errorf("resposta %q, versões %v (índice %d), esperado a segunda resposta como versão 1", reply.Content, got, reply.VersionIndex)
+	}
+
+	messages := s.history(t, user.Token, conversationID)
+	if len(messages) != 2 || messages[1].ID != reply.ID || messages[1].Content != reply.Content {
+		t.Errorf("histórico %+v, esperado a resposta regenerada no lugar da anterior", messages)
+	}
+}
+
+func TestRegenerateReplyConcurrent(t *testing.T) {
+	backend := &countingBackend{blockCall: 2, entered: make(chan struct{}), release: make(chan struct{})}
+	s := newTestServerWithBackend(t, backend)
+	user := s.register(t, "elisa@example.com", "secret123")
+	conversationID := s.sendChat(t, user.Token, "", "oi").ConversationID
+	path := "/api/v1/conversations/" + conversationID + "/regenerate"
+
+	// A primeira regeneração fica presa no backend enquanto a segunda termina
+	first := make(chan int)
+	go func() {
+		first <- s.do(t, http.MethodPost, path, user.Token, nil).Code
+	}()
+	<-backend.entered
+
+	rec := s.do(t, http.MethodPost, path, user.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("segunda regeneração: status %d, body %s", rec.Code, rec.Body)
+	}
+	close(backend.release)
+
+	// A primeira leu a resposta antes da segunda versão: não pode sobrescrevê-la
+	if status := <-first; status != http.StatusConflict {
+		t.Errorf("primeira regeneração: status %d, esperado %d", status, http.StatusConflict)
+	}
+	messages := s.history(t, user.Token, conversationID)
+	if got := versionContents(&messages[1]); fmt.Sprint(got) != "[resposta 1 para oi resposta 3 para oi]" || messages[1].Content != "resposta 3 para oi" {
+		t.Errorf("resposta %q, versões %v, esperado só a versão da segunda regeneração", messages[1].Content, got)
+	}
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"chatserver/metrics"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ForkConversationRequest representa a bifurcação de uma conversa
+type ForkConversationRequest struct {
+	MessageID string `json:"messageId" binding:"required"` // Última mensagem compartilhada com a nova conversa
+	Title     string `json:"title,omitempty"`              // Opcional: padrão é o título da conversa de origem
+}
+
+// ForkConversation godoc
+// @Summary      Bifurcar conversa
+// @Description  Cria uma nova conversa que compartilha o histórico da conversa de origem até a mensagem informada,
+// @Description  permitindo explorar respostas alternativas sem alterar a conversa original.
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                   true  "Conversation ID"
+// @Param        request  body      ForkConversationRequest  true  "Mensagem onde a conversa é bifurcada"
+// @Success      201      {object}  models.Conversation
+// @Failure      400      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /api/v1/conversations/{id}/fork [post]
+func (ctrl *ChatController) ForkConversation(c *gin.Context) {
+	conversationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de conversa inválido"})
+		return
+	}
+	logConversation(c, conversationID)
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+
+	var req ForkConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	messageID, err := primitive.ObjectIDFromHex(req.MessageID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de mensagem inválido"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	parent, chatErr := ctrl.findOwnedConversation(ctx, userID.(string), conversationID)
+	if chatErr != nil {
+		chatErr.respond(c)
+		return
+	}
+
+	// A mensagem precisa fazer parte do histórico da conversa (própria ou herdada)
+	segments, err := ctrl.messageSegments(ctx, conversationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar mensagem"})
+		return
+	}
+	found, err := ctrl.messages.Contains(ctx, segments, messageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar mensagem"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mensagem não encontrada"})
+		return
+	}
+
+	conversation := models.NewForkedConversation(parent, messageID)
+	if req.Title != "" {
+		conversation.Title = req.Title
+		conversation.TitleSource = models.TitleSourceUser
+	}
+	if err := ctrl.conversations.Create(ctx, conversation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar conversa"})
+		return
+	}
+	metrics.RecordConversationCreated("fork")
+
+	c.JSON(http.StatusCreated, conversation)
+}
+
+// deleteConversation remove a conversa e suas mensagens. Se outras conversas foram
+// bifurcadas dela, as mensagens até o último ponto de bifurcação são mantidas e a
+// conversa fica sem dono (deixa de aparecer para o usuário) até que a última
+// bifurcação seja removida.
+func (ctrl *ChatController) deleteConversation(ctx context.Context, conversation *models.Conversation) error {
+	forks, err := ctrl.conversations.FindForks(ctx, conversation.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(forks) > 0 {
+		var lastForkPoint primitive.ObjectID
+		for _, fork := range forks {
+			if fork.ForkedFromMessageID != nil && bytes.Compare(fork.ForkedFromMessageID[:], lastForkPoint[:]) > 0 {
+				lastForkPoint = *fork.ForkedFromMessageID
+			}
+		}
+
+		if err := ctrl.messages.DeleteAfter(ctx, conversation.ID, lastForkPoint); err != nil {
+			return err
+		}
+		return ctrl.conversations.MarkDeleted(ctx, conversation.ID)
+	}
+
+	if err := ctrl.messages.DeleteByConversation(ctx, conversation.ID); err != nil {
+		return err
+	}
+	if err := ctrl.conversations.Delete(ctx, conversation.ID); err != nil {
+		return err
+	}
+
+	// Remover a conversa de origem já deletada pelo usuário quando esta era sua última bifurcação
+	if conversation.ParentConversationID != nil {
+		parent, err := ctrl.conversations.FindByID(ctx, *conversation.ParentConversationID)
+		if err == repository.ErrNotFound || (err == nil && parent.DeletedAt == nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return ctrl.deleteConversation(ctx, parent)
+	}
+	return nil
+}
+
+// DeleteUserConversations remove todas as conversas do usuário e suas mensagens
+// (exclusão de conta). As bifurcações são sempre do mesmo usuário, então as
+// conversas de origem mantidas por deleteConversation também acabam removidas.
+func (ctrl *ChatController) DeleteUserConversations(ctx context.Context, userID string) error {
+	conversations, err := ctrl.conversations.FindByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range conversations {
+		if err := ctrl.deleteConversation(ctx, &conversations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteConversationByID remove uma conversa de qualquer usuário (rotas
+// administrativas), com as mesmas regras de DeleteConversation para bifurcações.
+// Retorna repository.ErrNotFound se a conversa não existe.
+func (ctrl *ChatController) DeleteConversationByID(ctx context.Context, conversationID primitive.ObjectID) error {
+	conversation, err := ctrl.conversations.FindByID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	return ctrl.deleteConversation(ctx, conversation)
+}
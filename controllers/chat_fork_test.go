@@ -0,0 +1,131 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"chatserver/controllers"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fork bifurca a conversa na mensagem e retorna a nova conversa
+func (s *testServer) fork(t *testing.T, token, conversationID string, messageID primitive.ObjectID) models.Conversation {
+	t.Helper()
+
+	rec := s.do(t, http.MethodPost, "/api/v1/conversations/"+conversationID+"/fork", token, controllers.ForkConversationRequest{MessageID: messageID.Hex()})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("bifurcação: status %d, body %s", rec.Code, rec.Body)
+	}
+	return decodeResponse[models.Conversation](t, rec)
+}
+
+// contentsOf retorna o conteúdo das mensagens
+func contentsOf(messages []models.Message) string {
+	contents := make([]string, len(messages))
+	for i, message := range messages {
+		contents[i] = message.Content
+	}
+	return fmt.Sprint(contents)
+}
+
+func TestForkConversationLineage(t *testing.T) {
+	backend := &countingBackend{}
+	s := newTestServerWithBackend(t, backend)
+	user := s.register(t, "fabiana@example.com", "secret123")
+	original := s.sendChat(t, user.Token, "", "um").ConversationID
+	s.sendChat(t, user.Token, original, "dois")
+	messages := s.history(t, user.Token, original)
+
+	fork := s.fork(t, user.Token, original, messages[1].ID)
+	if fork.ParentConversationID == nil || fork.ParentConversationID.Hex() != original ||
+		fork.ForkedFromMessageID == nil || *fork.ForkedFromMessageID != messages[1].ID {
+		t.Fatalf("bifurcação %+v, esperado a origem %s na mensagem %s", fork, original, messages[1].ID.Hex())
+	}
+
+	// A bifurcação herda o histórico até a mensagem e segue sozinha
+	s.sendChat(t, user.Token, fork.ID.Hex(), "três")
+	if got := contentsOf(backend.lastRequest().History); got != "[um resposta 1 para um três]" {
+		t.Errorf("histórico enviado ao assistente %s, esperado as mensagens herdadas e a nova", got)
+	}
+	if got := contentsOf(s.history(t, user.Token, fork.ID.Hex())); got != "[um resposta 1 para um três resposta 3 para três]" {
+		t.Errorf("histórico da bifurcação %s", got)
+	}
+	if got := contentsOf(s.history(t, user.Token, original)); got != "[um resposta 1 para um dois resposta 2 para dois]" {
+		t.Errorf("histórico da conversa original %s, esperado sem alteração", got)
+	}
+
+	// Uma bifurcação da bifurcação pode partir de uma mensagem herdada
+	nested := s.fork(t, user.Token, fork.ID.Hex(), messages[0].ID)
+	if got := contentsOf(s.history(t, user.Token, nested.ID.Hex())); got != "[um]" {
+		t.Errorf("histórico da bifurcação aninhada %s, esperado [um]", got)
+	}
+
+	// Mensagens fora do histórico (posteriores ao ponto de bifurcação) e de outro usuário
+	rec := s.do(t, http.MethodPost, "/api/v1/conversations/"+fork.ID.Hex()+"/fork", user.Token, controllers.ForkConversationRequest{MessageID: messages[2].ID.Hex()})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("mensagem posterior ao ponto de bifurcação: status %d, esperado %d", rec.Code, http.StatusNotFound)
+	}
+	other := s.register(t, "gil@example.com", "secret123")
+	rec = s.do(t, http.MethodPost, "/api/v1/conversations/"+original+"/fork", other.Token, controllers.ForkConversationRequest{MessageID: messages[1].ID.Hex()})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("conversa de outro usuário: status %d, esperado %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestDeleteConversationWithForks(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	user := s.register(t, "helena@example.com", "secret123")
+	original := s.sendChat(t, user.Token, "", "um").ConversationID
+	s.sendChat(t, user.Token, original, "dois")
+	messages := s.history(t, user.Token, original)
+	fork := s.fork(t, user.Token, original, messages[1].ID)
+	originalID := fork.ParentConversationID
+
+	ownMessages := func() []models.Message {
+		t.Helper()
+		result, err := s.repos.Messages.List(ctx, []repository.Segment{{ConversationID: *originalID}}, repository.MessagePage{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	rec := s.do(t, http.MethodDelete, "/api/v1/conversations/"+original, user.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("deletar a origem: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	// A origem some para o usuário, mas mantém as mensagens herdadas pela bifurcação
+	rec = s.do(t, http.MethodGet, "/api/v1/conversations/"+original, user.Token, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("origem deletada: status %d, esperado %d", rec.Code, http.StatusForbidden)
+	}
+	rec = s.do(t, http.MethodGet, "/api/v1/conversations", user.Token, nil)
+	if page := decodeResponse[conversationsPage](t, rec); len(page.Conversations) != 1 || page.Conversations[0].ID != fork.ID {
+		t.Errorf("conversas %+v, esperado só a bifurcação", page.Conversations)
+	}
+	if got := contentsOf(ownMessages()); got != "[um Echo: um]" {
+		t.Errorf("mensagens mantidas na origem %s, esperado até o ponto de bifurcação", got)
+	}
+	if got := contentsOf(s.history(t, user.Token, fork.ID.Hex())); got != "[um Echo: um]" {
+		t.Errorf("histórico da bifurcação %s, esperado as mensagens herdadas", got)
+	}
+
+	// Deletar a última bifurcação remove a origem de vez
+	rec = s.do(t, http.MethodDelete, "/api/v1/conversations/"+fork.ID.Hex(), user.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("deletar a bifurcação: status %d, body %s", rec.Code, rec.Body)
+	}
+	if _, err := s.repos.Conversations.FindByID(ctx, *originalID); err != repository.ErrNotFound {
+		t.Errorf("origem após deletar a bifurcação: %v, esperado ErrNotFound", err)
+	}
+	if remaining := ownMessages(); len(remaining) != 0 {
+		t.Errorf("%d mensagens da origem restantes, esperado 0", len(remaining))
+	}
+}
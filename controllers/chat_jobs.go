@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"chatserver/assistant"
+	"chatserver/metrics"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// callbackMaxAttempts é o número de tentativas de entrega do callback
+const callbackMaxAttempts = 3
+
+// jobLeaseDuration é o prazo do lease das respostas pendentes. Cada instância
+// renova o das suas a cada terço desse prazo enquanto está em execução; se
+// para, as suas respostas são encerradas pelas outras quando o prazo vence.
+const jobLeaseDuration = time.Minute
+
+// ChatJobResponse representa o estado de uma mensagem processada em modo assíncrono.
+// O ID do job é o ID da mensagem do assistente.
+type ChatJobResponse struct {
+	JobID          string               `json:"jobId"`
+	ConversationID string               `json:"conversationId"`
+	MessageID      string               `json:"messageId"`
+	Status         models.MessageStatus `json:"status"`
+	Message        string               `json:"message,omitempty"` // Resposta do assistente (status completed)
+	Role           models.MessageRole   `json:"role"`
+	LatencyMs      int64                `json:"latencyMs,omitempty"`
+	Error          string               `json:"error,omitempty"`
+}
+
+// chatJob é uma mensagem aguardando resposta do backend
+type chatJob struct {
+	turn        *chatTurn
+	message     *models.Message // Mensagem do assistente com status pending
+	callbackURL string
+	startTime   time.Time
+}
+
+// startWorkers inicia os workers que processam as mensagens assíncronas
+func (ctrl *ChatController) startWorkers() {
+	for i := 0; i < ctrl.options.AsyncWorkers; i++ {
+		go func() {
+			for job := range ctrl.jobs {
+				ctrl.processJob(job)
+			}
+		}()
+	}
+}
+
+// startJobLeases renova periodicamente o lease das respostas pendentes desta
+// instância e encerra as das instâncias que pararam (lease vencido): a fila fica
+// só em memória e elas nunca seriam concluídas. A primeira verificação é feita
+// antes de retornar, para encerrar as respostas de uma execução anterior.
+func (ctrl *ChatController) startJobLeases() {
+	ctrl.failStaleJobs()
+
+	go func() {
+		ticker := time.NewTicker(jobLeaseDuration / 3)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctrl.renewJobLeases()
+			ctrl.failStaleJobs()
+		}
+	}()
+}
+
+// renewJobLeases estende o lease das respostas pendentes desta instância
+func (ctrl *ChatController) renewJobLeases() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := ctrl.messages.RenewLeases(ctx, ctrl.instanceID, start.Add(jobLeaseDuration)); err != nil {
+		metrics.RecordDatabaseOperation("update", "messages", "failure", time.Since(start).Seconds())
+		slog.ErrorContext(ctx, "Erro ao renovar o lease das mensagens pendentes", "error", err)
+		return
+	}
+	metrics.RecordDatabaseOperation("update", "messages", "success", time.Since(start).Seconds())
+}
+
+// failStaleJobs encerra as respostas pendentes cujo lease venceu
+func (ctrl *ChatController) failStaleJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	failed, err := ctrl.messages.FailPending(ctx, start, "processamento interrompido pela parada do servidor")
+	if err != nil {
+		metrics.RecordDatabaseOperation("update", "messages", "failure", time.Since(start).Seconds())
+		slog.ErrorContext(ctx, "Erro ao encerrar mensagens pendentes", "error", err)
+		return
+	}
+	metrics.RecordDatabaseOperation("update", "messages", "success", time.Since(start).Seconds())
+
+	if failed > 0 {
+		slog.WarnContext(ctx, "Mensagens pendentes com lease vencido encerradas", "count", failed)
+	}
+}
+
+// enqueueAsyncReply cria a mensagem pendente do assistente e a coloca na fila
+func (ctrl *ChatController) enqueueAsyncReply(ctx context.Context, turn *chatTurn, callbackURL string, startTime time.Time) (*models.Message, *chatError) {
+	pending := models.NewMessage(turn.conversationID, models.RoleAssistant, "")
+	pending.Status = models.StatusPending
+	leaseUntil := time.Now().Add(jobLeaseDuration)
+	pending.LeaseOwner = ctrl.instanceID
+	pending.LeaseUntil = &leaseUntil
+
+	if err := ctrl.messages.Create(ctx, pending); err != nil {
+		return nil, &chatError{status: http.StatusInternalServerError, message: "Erro ao criar mensagem pendente"}
+	}
+	metrics.RecordChatMessage(string(models.RoleAssistant))
+
+	job := chatJob{
+		turn:        turn,
+		message:     pending,
+		callbackURL: callbackURL,
+		startTime:   startTime,
+	}
+
+	select {
+	case ctrl.jobs <- job:
+		return pending, nil
+	default:
+		// Fila cheia: registrar a falha para que o polling não fique pendente para sempre
+		ctrl.finishJob(job, nil, errors.New("fila de processamento cheia"))
+		return nil, &chatError{status: http.StatusServiceUnavailable, message: "Muitas mensagens em processamento, tente novamente em instantes", retryAfter: 5 * time.Second}
+	}
+}
+
+// processJob chama o backend e atualiza a mensagem pendente com o resultado
+func (ctrl *ChatController) processJob(job chatJob) {
+	ctx := job.turn.detachedCtx
+	if ctrl.options.AsyncJobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ctrl.options.AsyncJobTimeout)
+		defer cancel()
+	}
+
+	resp, err := ctrl.backend.Send(ctx, job.turn.request())
+	if err != nil {
+		err = errors.New(newBackendError(ctx, ctrl.backend.Name(), err).message)
+	}
+	ctrl.finishJob(job, resp, err)
+}
+
+// finishJob salva o resultado do job e notifica o callback, se houver
+func (ctrl *ChatController) finishJob(job chatJob, resp *assistant.Response, jobErr error) {
+	now := time.Now()
+	msg := job.message
+	msg.LatencyMs = now.Sub(job.startTime).Milliseconds()
+	msg.CompletedAt = &now
+	msg.LeaseOwner = ""
+	msg.LeaseUntil = nil
+
+	if jobErr != nil {
+		msg.Status = models.StatusFailed
+		msg.Error = jobErr.Error()
+	} else {
+		msg.Status = models.StatusCompleted
+		msg.Content = resp.Content
+		msg.Metadata = resp.Metadata
+	}
+
+	if err := ctrl.messages.Update(context.Background(), msg); err != nil {
+		slog.ErrorContext(job.turn.detachedCtx, "Erro ao salvar resultado do job", "job_id", msg.ID.Hex(), "error", err)
+	} else if msg.Status == models.StatusCompleted {
+		ctrl.scheduleAutoTitle(job.turn, msg)
+	}
+
+	// A entrega do callback (com novas tentativas) não deve ocupar o worker
+	if job.callbackURL != "" {
+		go ctrl.notifyCallback(job.turn.detachedCtx, job.callbackURL, newChatJobResponse(msg))
+	}
+}
+
+// notifyCallback envia o resultado do job para a URL informada pelo cliente.
+// Quando CallbackSecret está configurado, o corpo é assinado com HMAC-SHA256
+// no header X-Signature ("sha256=<hex>").
+func (ctrl *ChatController) notifyCallback(ctx context.Context, callbackURL string, payload ChatJobResponse) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	backoff := time.Second
+
+	for attempt := 1; attempt <= callbackMaxAttempts; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(body))
+		if err != nil {
+			slog.WarnContext(ctx, "Callback inválido", "job_id", payload.JobID, "error", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if ctrl.options.CallbackSecret != "" {
+			mac := hmac.New(sha256.New, []byte(ctrl.options.CallbackSecret))
+			mac.Write(body)
+			req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		resp, err := ctrl.callbackClient.Do(req)
+		if errors.Is(err, errCallbackAddressBlocked) {
+			slog.WarnContext(ctx, "Callback recusado", "job_id", payload.JobID, "error", err)
+			return
+		}
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+
+		slog.WarnContext(ctx, "Falha ao notificar callback", "job_id", payload.JobID, "attempt", attempt, "max_attempts", callbackMaxAttempts, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// validateCallbackURL aceita apenas URLs absolutas http(s). Sem allowPrivate,
+// recusa já na requisição os hosts que são endereços internos; nomes que
+// resolvem para eles são barrados na conexão (newCallbackClient).
+func validateCallbackURL(callbackURL string, allowPrivate bool) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("callbackUrl deve ser uma URL http(s) válida")
+	}
+	if allowPrivate {
+		return nil
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("callbackUrl não pode apontar para um endereço interno")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddress(addr) {
+		return errors.New("callbackUrl não pode apontar para um endereço interno")
+	}
+	return nil
+}
+
+func newChatJobResponse(msg *models.Message) ChatJobResponse {
+	status := msg.Status
+	if status == "" {
+		status = models.StatusCompleted
+	}
+	return ChatJobResponse{
+		JobID:          msg.ID.Hex(),
+		ConversationID: msg.ConversationID.Hex(),
+		MessageID:      msg.ID.Hex(),
+		Status:         status,
+		Message:        msg.Content,
+		Role:           msg.Role,
+		LatencyMs:      msg.LatencyMs,
+		Error:          msg.Error,
+	}
+}
+
+// GetChatJob godoc
+// @Summary      Consultar mensagem assíncrona
+// @Description  Retorna o estado (pending, completed, failed) de uma mensagem enviada com "async": true
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Job ID (ID da mensagem do assistente)"
+// @Success      200  {object}  ChatJobResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/jobs/{id} [get]
+func (ctrl *ChatController) GetChatJob(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de job inválido"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+
+	ctx := requestContext(c)
+
+	message, err := ctrl.messages.FindByID(ctx, objectID)
+	if err == nil && message.Role != models.RoleAssistant {
+		err = repository.ErrNotFound
+	}
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar job"})
+		return
+	}
+
+	// Verificar se a conversa da mensagem pertence ao usuário
+	if _, err := ctrl.conversations.FindOwned(ctx, message.ConversationID, userID.(string)); err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar conversa"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newChatJobResponse(message))
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"chatserver/metrics"
+)
+
+// startActiveUsersGauge recalcula periodicamente a métrica active_users_total:
+// usuários distintos que enviaram mensagem na janela ActiveUsersWindow
+func (ctrl *ChatController) startActiveUsersGauge() {
+	if ctrl.options.ActiveUsersInterval <= 0 || ctrl.options.ActiveUsersWindow <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(ctrl.options.ActiveUsersInterval)
+		defer ticker.Stop()
+		for {
+			ctrl.updateActiveUsers()
+			<-ticker.C
+		}
+	}()
+}
+
+// updateActiveUsers conta os usuários com conversas atualizadas dentro da janela
+// (o updatedAt da conversa muda a cada mensagem enviada)
+func (ctrl *ChatController) updateActiveUsers() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	users, err := ctrl.conversations.CountActiveUsers(ctx, time.Now().Add(-ctrl.options.ActiveUsersWindow))
+	if err != nil {
+		metrics.RecordDatabaseOperation("aggregate", "conversations", "failure", time.Since(start).Seconds())
+		slog.ErrorContext(ctx, "Erro ao calcular usuários ativos", "error", err)
+		return
+	}
+	metrics.RecordDatabaseOperation("aggregate", "conversations", "success", time.Since(start).Seconds())
+
+	metrics.SetActiveUsers(float64(users))
+}
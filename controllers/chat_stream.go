@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"chatserver/assistant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamChunkEvent é enviado a cada parte da resposta produzida pelo backend
+type StreamChunkEvent struct {
+	Content string `json:"content"`
+}
+
+// StreamDoneEvent é o último evento do streaming
+type StreamDoneEvent struct {
+	ConversationID string `json:"conversationId"`
+	MessageID      string `json:"messageId"`
+	LatencyMs      int64  `json:"latencyMs"`
+}
+
+// StreamMessage godoc
+// @Summary      Enviar mensagem com resposta em streaming (SSE)
+// @Description  Envia uma mensagem e recebe a resposta do chatbot via Server-Sent Events.
+// @Description  Eventos: "start" ({conversationId}), "chunk" ({content}), "error" ({error}) e "done" ({conversationId, messageId, latencyMs}).
+// @Description  A resposta montada é salva ao final do streaming, inclusive quando interrompido.
+// @Tags         chat
+// @Accept       json
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        request  body      ChatRequest  true  "Mensagem do usuário"
+// @Success      200      {object}  StreamDoneEvent
+// @Failure      400      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /api/v1/chat/stream [post]
+func (ctrl *ChatController) StreamMessage(c *gin.Context) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+
+	ctx := requestContext(c)
+	startTime := time.Now()
+
+	turn, chatErr := ctrl.prepareChat(ctx, userID.(string), req)
+	if chatErr != nil {
+		chatErr.respond(c)
+		return
+	}
+	logConversation(c, turn.conversationID)
+	conversationID := turn.conversationID
+
+	// A partir daqui a resposta é um stream de eventos
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Desabilita buffering em proxies nginx
+	c.Status(http.StatusOK)
+
+	c.SSEvent("start", gin.H{"conversationId": conversationID.Hex()})
+	c.Writer.Flush()
+
+	// O contexto da requisição é cancelado quando o cliente desconecta,
+	// interrompendo também a chamada ao backend
+	requestCtx := c.Request.Context()
+
+	var content strings.Builder
+	backendResponse, streamErr := assistant.Stream(requestCtx, ctrl.backend, turn.request(), func(chunk string) error {
+		content.WriteString(chunk)
+		c.SSEvent("chunk", StreamChunkEvent{Content: chunk})
+		c.Writer.Flush()
+		return requestCtx.Err()
+	})
+
+	latencyMs := time.Since(startTime).Milliseconds()
+
+	// Montar a mensagem final; em caso de interrupção, salva o que foi recebido
+	if backendResponse == nil {
+		backendResponse = &assistant.Response{}
+	}
+	backendResponse.Content = content.String()
+	if streamErr != nil {
+		if backendResponse.Metadata == nil {
+			backendResponse.Metadata = map[string]interface{}{}
+		}
+		backendResponse.Metadata["aborted"] = true
+		backendResponse.Metadata["abortReason"] = streamErr.Error()
+
+		if requestCtx.Err() == nil {
+			c.SSEvent("error", gin.H{"error": newBackendError(requestCtx, ctrl.backend.Name(), streamErr).message})
+			c.Writer.Flush()
+		}
+
+		// Nada recebido: não há resposta a ser salva
+		if backendResponse.Content == "" {
+			return
+		}
+	}
+
+	assistantMessage, err := ctrl.saveAssistantMessage(ctx, conversationID, backendResponse, latencyMs)
+	if err != nil {
+		if requestCtx.Err() == nil {
+			c.SSEvent("error", gin.H{"error": "Erro ao salvar resposta do assistente"})
+			c.Writer.Flush()
+		}
+		return
+	}
+
+	if streamErr == nil {
+		ctrl.scheduleAutoTitle(turn, assistantMessage)
+	}
+
+	if requestCtx.Err() == nil {
+		c.SSEvent("done", StreamDoneEvent{
+			ConversationID: conversationID.Hex(),
+			MessageID:      assistantMessage.ID.Hex(),
+			LatencyMs:      latencyMs,
+		})
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,244 @@
+package controllers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chatserver/assistant"
+	"chatserver/controllers"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sseEvent é um evento Server-Sent Events recebido
+type sseEvent struct {
+	Name string
+	Data string
+}
+
+// readSSE lê os eventos do corpo até o fim ou até stop retornar true
+func readSSE(t *testing.T, body *bufio.Reader, stop func(sseEvent) bool) []sseEvent {
+	t.Helper()
+
+	var events []sseEvent
+	var current sseEvent
+	for {
+		line, err := body.ReadString('\n')
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			current.Name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			current.Data = strings.TrimPrefix(line, "data:")
+		case line == "" && current.Name != "":
+			events = append(events, current)
+			if stop != nil && stop(current) {
+				return events
+			}
+			current = sseEvent{}
+		}
+		if err != nil {
+			return events
+		}
+	}
+}
+
+// eventNames retorna os nomes dos eventos, em ordem
+func eventNames(events []sseEvent) []string {
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = event.Name
+	}
+	return names
+}
+
+// chunkBackend entrega chunks e então termina com err, ou espera o cliente
+// desistir quando block é true
+type chunkBackend struct {
+	chunks []string
+	err    error
+	block  bool
+}
+
+func (b *chunkBackend) Name() string {
+	return "chunks"
+}
+
+func (b *chunkBackend) Send(ctx context.Context, req assistant.Request) (*assistant.Response, error) {
+	return &assistant.Response{Content: strings.Join(b.chunks, "")}, b.err
+}
+
+func (b *chunkBackend) Stream(ctx context.Context, req assistant.Request, onChunk assistant.ChunkHandler) (*assistant.Response, error) {
+	for _, chunk := range b.chunks {
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+	if b.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &assistant.Response{Content: strings.Join(b.chunks, "")}, nil
+}
+
+// assistantMessages retorna as respostas do assistente salvas na conversa,
+// esperando um pouco pelas que ainda estão sendo salvas
+func (s *testServer) assistantMessages(t *testing.T, conversationID string, want int) []models.Message {
+	t.Helper()
+
+	objectID, err := primitive.ObjectIDFromHex(conversationID)
+	if err != nil {
+		t.Fatalf("conversationId %q: %v", conversationID, err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		messages, err := s.repos.Messages.List(context.Background(), []repository.Segment{{ConversationID: objectID}}, repository.MessagePage{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var replies []models.Message
+		for _, message := range messages {
+			if message.Role == models.RoleAssistant {
+				replies = append(replies, message)
+			}
+		}
+		if len(replies) >= want || time.Now().After(deadline) {
+			return replies
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStreamMessageEvents(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "sara@example.com", "secret123")
+
+	rec := s.do(t, http.MethodPost, "/api/v1/chat/stream", user.Token, controllers.ChatRequest{Message: "um dois"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		t.Errorf("Content-Type %q, esperado text/event-stream", contentType)
+	}
+
+	events := readSSE(t, bufio.NewReader(rec.Body), nil)
+	want := []string{"start", "chunk", "chunk", "chunk", "done"}
+	if got := eventNames(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("eventos %v, esperado %v", got, want)
+	}
+
+	var start struct{ ConversationID string }
+	var done controllers.StreamDoneEvent
+	if err := json.Unmarshal([]byte(events[0].Data), &start); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(events[len(events)-1].Data), &done); err != nil {
+		t.Fatal(err)
+	}
+	var content strings.Builder
+	for _, event := range events[1 : len(events)-1] {
+		var chunk controllers.StreamChunkEvent
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		content.WriteString(chunk.Content)
+	}
+	if content.String() != "Echo: um dois" {
+		t.Errorf("chunks montam %q, esperado %q", content.String(), "Echo: um dois")
+	}
+	if done.ConversationID != start.ConversationID || done.MessageID == "" {
+		t.Errorf("done %+v, start %+v", done, start)
+	}
+
+	replies := s.assistantMessages(t, done.ConversationID, 1)
+	if len(replies) != 1 || replies[0].ID.Hex() != done.MessageID || replies[0].Content != "Echo: um dois" {
+		t.Errorf("respostas salvas %+v, esperado a mensagem %s com o conteúdo completo", replies, done.MessageID)
+	}
+}
+
+func TestStreamMessageBackendError(t *testing.T) {
+	backend := &chunkBackend{chunks: []string{"parcial "}, err: errors.New("conexão perdida")}
+	s := newTestServerWithBackend(t, backend)
+	user := s.register(t, "tais@example.com", "secret123")
+
+	rec := s.do(t, http.MethodPost, "/api/v1/chat/stream", user.Token, controllers.ChatRequest{Message: "oi"})
+	events := readSSE(t, bufio.NewReader(rec.Body), nil)
+	// O erro é avisado e o done informa a mensagem salva com o que chegou
+	want := []string{"start", "chunk", "error", "done"}
+	if got := eventNames(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("eventos %v, esperado %v", got, want)
+	}
+
+	var start struct{ ConversationID string }
+	if err := json.Unmarshal([]byte(events[0].Data), &start); err != nil {
+		t.Fatal(err)
+	}
+	replies := s.assistantMessages(t, start.ConversationID, 1)
+	if len(replies) != 1 || replies[0].Content != "parcial " || replies[0].Metadata["aborted"] != true {
+		t.Errorf("respostas salvas %+v, esperado o conteúdo parcial marcado como aborted", replies)
+	}
+}
+
+func TestStreamMessageClientAbortSavesPartialContent(t *testing.T) {
+	backend := &chunkBackend{chunks: []string{"primeira ", "parte"}, block: true}
+	s := newTestServerWithBackend(t, backend)
+	user := s.register(t, "ursula@example.com", "secret123")
+
+	server := httptest.NewServer(s.router)
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	body, err := json.Marshal(controllers.ChatRequest{Message: "oi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/chat/stream", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+user.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// O cliente desiste depois do segundo chunk
+	chunks := 0
+	events := readSSE(t, bufio.NewReader(resp.Body), func(event sseEvent) bool {
+		if event.Name == "chunk" {
+			chunks++
+		}
+		return chunks == 2
+	})
+	cancel()
+	if len(events) == 0 || events[0].Name != "start" {
+		t.Fatalf("eventos %v, esperado start primeiro", eventNames(events))
+	}
+
+	var start struct{ ConversationID string }
+	if err := json.Unmarshal([]byte(events[0].Data), &start); err != nil {
+		t.Fatal(err)
+	}
+	replies := s.assistantMessages(t, start.ConversationID, 1)
+	if len(replies) != 1 {
+		t.Fatalf("%d respostas salvas, esperado 1", len(replies))
+	}
+	if replies[0].Content != "primeira parte" || replies[0].Metadata["aborted"] != true {
+		t.Errorf("resposta salva %q (metadata %v), esperado o conteúdo recebido marcado como aborted", replies[0].Content, replies[0].Metadata)
+	}
+}
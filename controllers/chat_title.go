@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"chatserver/assistant"
+	"chatserver/models"
+)
+
+// Modos de geração automática do título das conversas (ChatOptions.AutoTitle)
+const (
+	AutoTitleBackend   = "backend"   // Pede o título ao backend (n8n: N8N_TITLE_WEBHOOK_URL), com a heurística local como alternativa
+	AutoTitleHeuristic = "heuristic" // Usa apenas a heurística local
+	AutoTitleOff       = "off"       // Mantém o título padrão até o usuário alterá-lo
+)
+
+// autoTitleTimeout limita o tempo gasto gerando o título de uma conversa
+const autoTitleTimeout = 30 * time.Second
+
+// scheduleAutoTitle gera o título da conversa em segundo plano, sem atrasar a resposta
+func (ctrl *ChatController) scheduleAutoTitle(turn *chatTurn, reply *models.Message) {
+	switch ctrl.options.AutoTitle {
+	case AutoTitleBackend, AutoTitleHeuristic:
+		go ctrl.generateTitle(turn, reply)
+	}
+}
+
+// generateTitle substitui o título padrão por um gerado a partir da troca de
+// mensagens. Conversas com título gerado ou definido pelo usuário não são alteradas.
+func (ctrl *ChatController) generateTitle(turn *chatTurn, reply *models.Message) {
+	ctx, cancel := context.WithTimeout(turn.detachedCtx, autoTitleTimeout)
+	defer cancel()
+
+	// Evitar chamar o backend quando a conversa já tem título
+	pending, err := ctrl.conversations.HasDefaultTitle(ctx, turn.conversationID)
+	if err != nil || !pending {
+		return
+	}
+
+	var title string
+	if ctrl.options.AutoTitle == AutoTitleHeuristic {
+		title = assistant.HeuristicTitle(turn.userMessage.Content)
+	} else {
+		title = assistant.Title(ctx, ctrl.backend, assistant.Request{
+			Message:        turn.userMessage.Content,
+			ConversationID: turn.conversationID.Hex(),
+			History:        []models.Message{*turn.userMessage, *reply},
+			Task:           assistant.TaskTitle,
+		})
+	}
+	if title == "" {
+		return
+	}
+
+	// A atualização verifica o título de novo para não sobrescrever um título
+	// definido pelo usuário enquanto o título era gerado
+	if err := ctrl.conversations.SetAutoTitle(ctx, turn.conversationID, title); err != nil {
+		slog.ErrorContext(ctx, "Erro ao salvar título da conversa", "error", err)
+	}
+}
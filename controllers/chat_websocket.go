@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"chatserver/logging"
+	"chatserver/models"
+	"chatserver/repository"
+	"chatserver/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/websocket"
+)
+
+// Tipos de evento trocados pelo WebSocket
+const (
+	WSEventJoin    = "join"    // cliente -> servidor: entrar em uma conversa
+	WSEventJoined  = "joined"  // servidor -> cliente: entrada confirmada
+	WSEventMessage = "message" // ambos: mensagem do usuário ou resposta do assistente
+	WSEventStatus  = "status"  // servidor -> cliente: typing/idle
+	WSEventError   = "error"   // servidor -> cliente: falha ao processar o evento
+)
+
+// Status enviados nos eventos "status"
+const (
+	WSStatusTyping = "typing"
+	WSStatusIdle   = "idle"
+)
+
+// WSBearerProtocol é o subprotocolo usado por navegadores para enviar o token
+// no handshake: "Sec-WebSocket-Protocol: bearer, <token>"
+const WSBearerProtocol = "bearer"
+
+// WSClientEvent representa um evento enviado pelo cliente
+type WSClientEvent struct {
+	Type           string `json:"type"`
+	ConversationID string `json:"conversationId,omitempty"`
+	Content        string `json:"content,omitempty"`
+}
+
+// WSServerEvent representa um evento enviado pelo servidor
+type WSServerEvent struct {
+	Type           string             `json:"type"`
+	ConversationID string             `json:"conversationId,omitempty"`
+	MessageID      string             `json:"messageId,omitempty"`
+	Role           models.MessageRole `json:"role,omitempty"`
+	Content        string             `json:"content,omitempty"`
+	LatencyMs      int64              `json:"latencyMs,omitempty"`
+	Status         string             `json:"status,omitempty"`
+	Error          string             `json:"error,omitempty"`
+}
+
+// WSAuthenticator valida de novo, antes de cada evento, o token usado no
+// handshake (implementado pelo AuthController)
+type WSAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (*models.Claims, error)
+}
+
+// wsClient é uma conexão WebSocket de um usuário autenticado
+type wsClient struct {
+	conn           *websocket.Conn
+	userID         string
+	sessionID      string // Sessão do token de acesso (vazio com chave de API)
+	apiKeyID       string // Chave de API usada no handshake (vazio com token de acesso)
+	token          string
+	conversationID string
+	writeMu        sync.Mutex
+	closeOnce      sync.Once
+}
+
+// send envia um evento ao cliente; conexões podem receber eventos de outras goroutines
+func (cl *wsClient) send(event WSServerEvent) error {
+	cl.writeMu.Lock()
+	defer cl.writeMu.Unlock()
+	return websocket.JSON.Send(cl.conn, event)
+}
+
+// close envia um evento de erro com o motivo e encerra a conexão, sem esperar
+// mais que wsCloseTimeout por um cliente lento
+func (cl *wsClient) close(reason string) {
+	cl.closeOnce.Do(func() {
+		cl.writeMu.Lock()
+		defer cl.writeMu.Unlock()
+		cl.conn.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+		websocket.JSON.Send(cl.conn, WSServerEvent{Type: WSEventError, Error: reason})
+		cl.conn.Close()
+	})
+}
+
+// chatHub mantém os clientes conectados em cada conversa
+type chatHub struct {
+	mu            sync.RWMutex
+	conversations map[string]map[*wsClient]struct{}
+}
+
+func newChatHub() *chatHub {
+	return &chatHub{
+		conversations: make(map[string]map[*wsClient]struct{}),
+	}
+}
+
+// join move o cliente para a conversa informada
+func (h *chatHub) join(cl *wsClient, conversationID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeLocked(cl)
+	if h.conversations[conversationID] == nil {
+		h.conversations[conversationID] = make(map[*wsClient]struct{})
+	}
+	h.conversations[conversationID][cl] = struct{}{}
+	cl.conversationID = conversationID
+}
+
+// leave remove o cliente da conversa atual
+func (h *chatHub) leave(cl *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(cl)
+}
+
+func (h *chatHub) removeLocked(cl *wsClient) {
+	if cl.conversationID == "" {
+		return
+	}
+	clients := h.conversations[cl.conversationID]
+	delete(clients, cl)
+	if len(clients) == 0 {
+		delete(h.conversations, cl.conversationID)
+	}
+	cl.conversationID = ""
+}
+
+// broadcast envia o evento para todos os clientes da conversa
+func (h *chatHub) broadcast(conversationID string, event WSServerEvent) {
+	h.mu.RLock()
+	clients := make([]*wsClient, 0, len(h.conversations[conversationID]))
+	for cl := range h.conversations[conversationID] {
+		clients = append(clients, cl)
+	}
+	h.mu.RUnlock()
+
+	for _, cl := range clients {
+		cl.send(event)
+	}
+}
+
+// WebSocket godoc
+// @Summary      Canal WebSocket de chat
+// @Description  Abre uma conexão WebSocket autenticada. O token JWT pode ser enviado no parâmetro "token",
+// @Description  no subprotocolo ("Sec-WebSocket-Protocol: bearer, <token>") ou no header Authorization.
+// @Description  Eventos do cliente: {"type":"join","conversationId"} e {"type":"message","content","conversationId?"}.
+// @Description  Eventos do servidor: "joined", "message", "status" (typing/idle) e "error".
+// @Description  O token é validado de novo a cada evento e a conexão é encerrada quando ele expira ou quando
+// @Description  a sessão ou a chave de API é revogada (logout, troca de senha, conta desativada...).
+// @Tags         chat
+// @Param        token  query  string  false  "Token JWT"
+// @Success      101
+// @Failure      401  {object}  map[string]string
+// @Router       /api/v1/ws [get]
+func (ctrl *ChatController) WebSocket(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+
+	server := websocket.Server{
+		// O token já foi validado pelo middleware; aceita clientes sem Origin (apps, bots)
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			for _, protocol := range config.Protocol {
+				if protocol == WSBearerProtocol {
+					config.Protocol = []string{WSBearerProtocol}
+					return nil
+				}
+			}
+			config.Protocol = nil
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			ctrl.serveWebSocket(conn, &wsClient{
+				userID:    userID.(string),
+				sessionID: c.GetString("session_id"),
+				apiKeyID:  c.GetString("api_key_id"),
+				token:     c.GetString("token"),
+			}, c.GetTime("token_expires_at"))
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// serveWebSocket processa os eventos de uma conexão até ela ser encerrada.
+// A conexão é encerrada quando o token expira (expiresAt) ou é revogado.
+func (ctrl *ChatController) serveWebSocket(conn *websocket.Conn, client *wsClient, expiresAt time.Time) {
+	client.conn = conn
+	ctrl.options.Connections.add(client)
+	defer ctrl.options.Connections.remove(client)
+	defer ctrl.hub.leave(client)
+
+	if !expiresAt.IsZero() {
+		timer := time.AfterFunc(time.Until(expiresAt), func() { client.close("Token expirado") })
+		defer timer.Stop()
+	}
+
+	for {
+		var event WSClientEvent
+		if err := websocket.JSON.Receive(conn, &event); err != nil {
+			// Conexão encerrada ou frame inválido
+			return
+		}
+
+		// A sessão ou a chave de API pode ter sido revogada depois do handshake
+		if !ctrl.revalidateWebSocket(client) {
+			return
+		}
+
+		switch event.Type {
+		case WSEventJoin:
+			ctrl.handleWSJoin(client, event)
+		case WSEventMessage:
+			ctrl.handleWSMessage(client, event)
+		default:
+			client.send(WSServerEvent{Type: WSEventError, Error: "Tipo de evento desconhecido"})
+		}
+	}
+}
+
+// revalidateWebSocket confere se o token do handshake ainda é aceito; se não
+// for, encerra a conexão
+func (ctrl *ChatController) revalidateWebSocket(client *wsClient) bool {
+	if ctrl.options.Authenticator == nil {
+		return true
+	}
+	if _, err := ctrl.options.Authenticator.Authenticate(client.conn.Request().Context(), client.token); err != nil {
+		client.close("Sessão expirada ou revogada")
+		return false
+	}
+	return true
+}
+
+// handleWSJoin verifica se a conversa pertence ao usuário e entra nela
+func (ctrl *ChatController) handleWSJoin(client *wsClient, event WSClientEvent) {
+	objectID, err := primitive.ObjectIDFromHex(event.ConversationID)
+	if err != nil {
+		client.send(WSServerEvent{Type: WSEventError, Error: "ID de conversa inválido"})
+		return
+	}
+
+	ctx := context.Background()
+
+	if _, err := ctrl.conversations.FindOwned(ctx, objectID, client.userID); err != nil {
+		if err == repository.ErrNotFound {
+			client.send(WSServerEvent{Type: WSEventError, Error: "Conversa não encontrada ou acesso negado"})
+		} else {
+			client.send(WSServerEvent{Type: WSEventError, Error: "Erro ao buscar conversa"})
+		}
+		return
+	}
+
+	ctrl.hub.join(client, objectID.Hex())
+	client.send(WSServerEvent{Type: WSEventJoined, ConversationID: objectID.Hex()})
+}
+
+// handleWSMessage salva a mensagem do usuário, chama o backend e distribui a resposta
+// para todos os clientes da conversa, usando o mesmo fluxo de SendMessage
+func (ctrl *ChatController) handleWSMessage(client *wsClient, event WSClientEvent) {
+	if event.Content == "" {
+		client.send(WSServerEvent{Type: WSEventError, Error: "Mensagem é obrigatória"})
+		return
+	}
+
+	conversationID := event.ConversationID
+	if conversationID == "" {
+		conversationID = client.conversationID
+	}
+
+	// Cancelado quando a conexão é encerrada; cada mensagem tem o seu span,
+	// filho do span da conexão
+	ctx, span := tracing.Tracer().Start(client.conn.Request().Context(), "WS message",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("enduser.id", client.userID)),
+	)
+	defer span.End()
+	startTime := time.Now()
+
+	turn, chatErr := ctrl.prepareChat(ctx, client.userID, ChatRequest{
+		ConversationID: conversationID,
+		Message:        event.Content,
+	})
+	if chatErr != nil {
+		client.send(WSServerEvent{Type: WSEventError, ConversationID: conversationID, Error: chatErr.message})
+		return
+	}
+
+	// Nova conversa (ou conversa diferente da atual): entrar automaticamente
+	conversationID = turn.conversationID.Hex()
+	ctx = logging.With(ctx, "conversation_id", conversationID)
+	if client.conversationID != conversationID {
+		ctrl.hub.join(client, conversationID)
+		client.send(WSServerEvent{Type: WSEventJoined, ConversationID: conversationID})
+	}
+
+	ctrl.hub.broadcast(conversationID, WSServerEvent{
+		Type:           WSEventMessage,
+		ConversationID: conversationID,
+		MessageID:      turn.userMessage.ID.Hex(),
+		Role:           models.RoleUser,
+		Content:        turn.userMessage.Content,
+	})
+	ctrl.hub.broadcast(conversationID, WSServerEvent{
+		Type:           WSEventStatus,
+		ConversationID: conversationID,
+		Status:         WSStatusTyping,
+	})
+
+	assistantMessage, chatErr := ctrl.generateReply(ctx, turn, startTime)
+
+	ctrl.hub.broadcast(conversationID, WSServerEvent{
+		Type:           WSEventStatus,
+		ConversationID: conversationID,
+		Status:         WSStatusIdle,
+	})
+
+	if chatErr != nil {
+		client.send(WSServerEvent{Type: WSEventError, ConversationID: conversationID, Error: chatErr.message})
+		return
+	}
+
+	ctrl.hub.broadcast(conversationID, WSServerEvent{
+		Type:           WSEventMessage,
+		ConversationID: conversationID,
+		MessageID:      assistantMessage.ID.Hex(),
+		Role:           models.RoleAssistant,
+		Content:        assistantMessage.Content,
+		LatencyMs:      assistantMessage.LatencyMs,
+	})
+}
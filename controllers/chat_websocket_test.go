@@ -0,0 +1,99 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chatserver/controllers"
+	"chatserver/models"
+
+	"golang.org/x/net/websocket"
+)
+
+// dialWebSocket abre o canal de chat com o token informado
+func (s *testServer) dialWebSocket(t *testing.T, token string) *websocket.Conn {
+	t.Helper()
+
+	server := httptest.NewServer(s.router)
+	t.Cleanup(server.Close)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/ws?token=" + token
+	conn, err := websocket.Dial(wsURL, "", server.URL)
+	if err != nil {
+		t.Fatalf("websocket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// receiveEvent lê o próximo evento do servidor
+func receiveEvent(t *testing.T, conn *websocket.Conn) (controllers.WSServerEvent, error) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var event controllers.WSServerEvent
+	err := websocket.JSON.Receive(conn, &event)
+	return event, err
+}
+
+// expectClosed confere que o servidor avisou e encerrou a conexão
+func expectClosed(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+
+	event, err := receiveEvent(t, conn)
+	if err != nil || event.Type != controllers.WSEventError {
+		t.Fatalf("aviso de encerramento: evento %+v, erro %v", event, err)
+	}
+	if event, err := receiveEvent(t, conn); err == nil {
+		t.Errorf("conexão continua aberta: evento %+v", event)
+	}
+}
+
+func TestWebSocketClosedOnPasswordChange(t *testing.T) {
+	s := newTestServer(t)
+	current := s.register(t, "gabi@example.com", "secret123")
+	rec := s.do(t, http.MethodPost, "/auth/login", "", models.LoginRequest{Email: "gabi@example.com", Password: "secret123"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	other := decodeResponse[models.AuthResponse](t, rec)
+
+	currentConn := s.dialWebSocket(t, current.Token)
+	otherConn := s.dialWebSocket(t, other.Token)
+
+	rec = s.do(t, http.MethodPut, "/profile/password", current.Token, models.ChangePasswordRequest{CurrentPassword: "secret123", NewPassword: "nova-senha"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("troca de senha: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	// A outra sessão é encerrada na hora, sem esperar uma nova mensagem
+	expectClosed(t, otherConn)
+
+	// A sessão que trocou a senha continua conectada
+	if err := websocket.JSON.Send(currentConn, controllers.WSClientEvent{Type: controllers.WSEventMessage, Content: "oi"}); err != nil {
+		t.Fatal(err)
+	}
+	if event, err := receiveEvent(t, currentConn); err != nil || event.Type != controllers.WSEventJoined {
+		t.Errorf("sessão atual: evento %+v, erro %v, esperado %q", event, err, controllers.WSEventJoined)
+	}
+}
+
+func TestWebSocketRevalidatesToken(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "hugo@example.com", "secret123")
+	conn := s.dialWebSocket(t, user.Token)
+
+	// O logout revoga a sessão pelo refresh token; a conexão é recusada no próximo evento
+	rec := s.do(t, http.MethodPost, "/auth/logout", "", models.RefreshRequest{RefreshToken: user.RefreshToken})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("logout: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	if err := websocket.JSON.Send(conn, controllers.WSClientEvent{Type: controllers.WSEventMessage, Content: "oi"}); err != nil {
+		t.Fatal(err)
+	}
+	expectClosed(t, conn)
+}
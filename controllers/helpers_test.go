@@ -0,0 +1,205 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"chatserver/assistant"
+	"chatserver/controllers"
+	"chatserver/keys"
+	"chatserver/mailer"
+	"chatserver/middleware"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLoginFailures is the lockout limit of the test server
+const maxLoginFailures = 5
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testMailer records the emails instead of sending them
+type testMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// sent returns how many emails were sent to the address, waiting briefly
+// for the ones still being sent (emails are sent in the background)
+func (m *testMailer) sent(to string, want int) int {
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.Lock()
+		count := 0
+		for _, msg := range m.messages {
+			if msg.To == to {
+				count++
+			}
+		}
+		m.mu.Unlock()
+
+		if count >= want || time.Now().After(deadline) {
+			return count
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// testServer is the API routed as in main.go, backed by the in-memory
+// repositories and the echo backend
+type testServer struct {
+	router  *gin.Engine
+	repos   repository.Repositories
+	auth    *controllers.AuthController
+	chat    *controllers.ChatController
+	profile *controllers.ProfileController
+	mail    *testMailer
+}
+
+// newTestServer creates the server; configure adjusts the auth options
+func newTestServer(t *testing.T, configure ...func(*controllers.AuthOptions)) *testServer {
+	t.Helper()
+	return newTestServerWithBackend(t, assistant.NewEchoBackend(), configure...)
+}
+
+// newTestServerWithBackend creates the server with another assistant backend
+func newTestServerWithBackend(t *testing.T, backend assistant.Backend, configure ...func(*controllers.AuthOptions)) *testServer {
+	t.Helper()
+
+	key, err := keys.NewHMACKey("test", []byte("test-secret-with-at-least-32-bytes!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet, err := keys.NewKeySet("test", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mail := &testMailer{}
+	connections := controllers.NewConnections()
+	options := controllers.AuthOptions{
+		Keys:                  keySet,
+		AccessTokenTTL:        15 * time.Minute,
+		RefreshTokenTTL:       24 * time.Hour,
+		Mailer:                mail,
+		PasswordResetTTL:      time.Hour,
+		PasswordResetInterval: 5 * time.Minute,
+		EmailVerificationTTL:  time.Hour,
+		VerificationResend:    time.Minute,
+		MaxLoginFailures:      maxLoginFailures,
+		MaxLoginFailuresPerIP: 50,
+		LoginFailureWindow:    15 * time.Minute,
+		LoginLockout:          15 * time.Minute,
+		MFAIssuer:             "SR Robot",
+		Connections:           connections,
+	}
+	for _, apply := range configure {
+		apply(&options)
+	}
+
+	s := &testServer{repos: repository.NewMemory(), mail: mail}
+	s.auth = controllers.NewAuthController(s.repos, options)
+	s.chat = controllers.NewChatController(s.repos.Conversations, s.repos.Messages, backend, controllers.ChatOptions{
+		AsyncWorkers:   1,
+		AsyncQueueSize: 10,
+		AutoTitle:      controllers.AutoTitleHeuristic,
+		Authenticator:  s.auth,
+		Connections:    connections,
+	})
+	s.profile = controllers.NewProfileController(s.repos, controllers.ProfileOptions{
+		Conversations: s.chat,
+		Connections:   connections,
+	})
+
+	s.router = gin.New()
+	auth := s.router.Group("/auth")
+	{
+		auth.POST("/register", s.auth.Register)
+		auth.POST("/login", s.auth.Login)
+		auth.POST("/refresh", s.auth.Refresh)
+		auth.POST("/logout", s.auth.Logout)
+		auth.POST("/mfa/verify", s.auth.VerifyMFA)
+		auth.POST("/mfa/disable", middleware.AuthMiddleware(s.auth), middleware.RequireSession(), s.auth.DisableMFA)
+		auth.POST("/forgot-password", s.auth.ForgotPassword)
+		if options.OIDC != nil {
+			auth.GET("/oidc/login", s.auth.OIDCLogin)
+			auth.GET("/oidc/callback", s.auth.OIDCCallback)
+		}
+	}
+	api := s.router.Group("/api/v1")
+	api.Use(middleware.AuthMiddleware(s.auth))
+	{
+		api.POST("/chat", middleware.RequireScope(models.ScopeChatWrite), s.chat.SendMessage)
+		api.POST("/chat/stream", middleware.RequireScope(models.ScopeChatWrite), s.chat.StreamMessage)
+		api.GET("/conversations", middleware.RequireScope(models.ScopeChatRead), s.chat.ListConversations)
+		api.GET("/conversations/:id", middleware.RequireScope(models.ScopeChatRead), s.chat.GetConversationHistory)
+		api.PUT("/conversations/:id/messages/:messageId", middleware.RequireScope(models.ScopeChatWrite), s.chat.EditMessage)
+		api.POST("/conversations/:id/regenerate", middleware.RequireScope(models.ScopeChatWrite), s.chat.RegenerateReply)
+		api.POST("/conversations/:id/fork", middleware.RequireScope(models.ScopeChatWrite), s.chat.ForkConversation)
+		api.DELETE("/conversations/:id", middleware.RequireScope(models.ScopeChatWrite), s.chat.DeleteConversation)
+		api.GET("/search", middleware.RequireScope(models.ScopeChatRead), controllers.NewSearchController(s.repos.Conversations, s.repos.Messages).Search)
+	}
+	s.router.GET("/api/v1/ws", middleware.WebSocketAuthMiddleware(s.auth), middleware.RequireScope(models.ScopeChatWrite), s.chat.WebSocket)
+	s.router.PUT("/profile/password", middleware.AuthMiddleware(s.auth), middleware.RequireSession(), s.profile.ChangePassword)
+	return s
+}
+
+// do sends a JSON request, authenticated when token is not empty
+func (s *testServer) do(t *testing.T, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// register creates an account and returns its tokens
+func (s *testServer) register(t *testing.T, email, password string) models.AuthResponse {
+	t.Helper()
+
+	rec := s.do(t, http.MethodPost, "/auth/register", "", models.RegisterRequest{Email: email, Password: password})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d, body %s", rec.Code, rec.Body)
+	}
+	return decodeResponse[models.AuthResponse](t, rec)
+}
+
+// decodeResponse decodes the JSON body of the response
+func decodeResponse[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var body T
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Limites de paginação
+const (
+	defaultConversationsLimit = 20
+	maxConversationsLimit     = 100
+	defaultMessagesLimit      = 50
+	maxMessagesLimit          = 200
+	defaultAdminLimit         = 20 // Usuários e registros de auditoria (rotas administrativas)
+	maxAdminLimit             = 100
+)
+
+// parseLimit lê o parâmetro "limit", aplicando o padrão e o máximo
+func parseLimit(c *gin.Context, defaultLimit, maxLimit int64) (int64, error) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit deve ser um número positivo")
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return limit, nil
+}
+
+// parseObjectIDQuery lê um ObjectID opcional da query string
+func parseObjectIDQuery(c *gin.Context, key string) (*primitive.ObjectID, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s deve ser um ID válido", key)
+	}
+	return &objectID, nil
+}
+
+// encodeConversationCursor gera o cursor opaco enviado ao cliente como nextCursor,
+// apontando para a última conversa da página (ordenada por updatedAt e _id, decrescentes)
+func encodeConversationCursor(last *models.Conversation) string {
+	raw := strconv.FormatInt(last.UpdatedAt.UnixMilli(), 10) + ":" + last.ID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeConversationCursor interpreta o cursor recebido no parâmetro "cursor"
+func decodeConversationCursor(value string) (*repository.ConversationCursor, error) {
+	invalid := errors.New("cursor inválido")
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalid
+	}
+
+	millis, hexID, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, invalid
+	}
+
+	unixMilli, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return nil, invalid
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil {
+		return nil, invalid
+	}
+
+	return &repository.ConversationCursor{UpdatedAt: time.UnixMilli(unixMilli), ID: objectID}, nil
+}
@@ -0,0 +1,177 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"chatserver/controllers"
+	"chatserver/models"
+)
+
+// conversationsPage é a resposta de GET /api/v1/conversations
+type conversationsPage struct {
+	Conversations []models.Conversation `json:"conversations"`
+	Total         int64                 `json:"total"`
+	NextCursor    *string               `json:"nextCursor"`
+}
+
+// messagesPage é a resposta de GET /api/v1/conversations/:id
+type messagesPage struct {
+	Messages   []models.Message `json:"messages"`
+	Total      int64            `json:"total"`
+	HasMore    bool             `json:"hasMore"`
+	NextCursor *string          `json:"nextCursor"`
+}
+
+// sendChat envia uma mensagem e retorna a resposta
+func (s *testServer) sendChat(t *testing.T, token, conversationID, message string) controllers.ChatResponse {
+	t.Helper()
+
+	rec := s.do(t, http.MethodPost, "/api/v1/chat", token, controllers.ChatRequest{ConversationID: conversationID, Message: message})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("chat %q: status %d, body %s", message, rec.Code, rec.Body)
+	}
+	return decodeResponse[controllers.ChatResponse](t, rec)
+}
+
+func TestListConversationsCursor(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "vera@example.com", "secret123")
+
+	// Criadas em sequência rápida: várias têm o mesmo updatedAt em milissegundos
+	var created []string
+	for _, message := range []string{"a", "b", "c", "d", "e"} {
+		created = append(created, s.sendChat(t, user.Token, "", message).ConversationID)
+	}
+
+	var listed []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(created) {
+			t.Fatal("a paginação não termina")
+		}
+		path := "/api/v1/conversations?limit=2"
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		rec := s.do(t, http.MethodGet, path, user.Token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("página %d: status %d, body %s", pages+1, rec.Code, rec.Body)
+		}
+		page := decodeResponse[conversationsPage](t, rec)
+		if page.Total != int64(len(created)) {
+			t.Errorf("total %d, esperado %d", page.Total, len(created))
+		}
+		for _, conversation := range page.Conversations {
+			listed = append(listed, conversation.ID.Hex())
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+	}
+
+	// Todas, uma vez cada, das mais recentes para as mais antigas
+	if len(listed) != len(created) {
+		t.Fatalf("conversas listadas %v, esperado %d", listed, len(created))
+	}
+	for i, id := range listed {
+		if want := created[len(created)-1-i]; id != want {
+			t.Errorf("posição %d: %s, esperado %s", i, id, want)
+		}
+	}
+}
+
+func TestListConversationsInvalidCursor(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "wagner@example.com", "secret123")
+
+	for _, cursor := range []string{"@@@", "c2VtLXNlcGFyYWRvcg", "MTIzOm5hby1oZXg"} {
+		rec := s.do(t, http.MethodGet, "/api/v1/conversations?cursor="+cursor, user.Token, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("cursor %q: status %d, esperado %d", cursor, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestConversationHistoryCursors(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "xavier@example.com", "secret123")
+
+	conversationID := s.sendChat(t, user.Token, "", "um").ConversationID
+	s.sendChat(t, user.Token, conversationID, "dois")
+	s.sendChat(t, user.Token, conversationID, "três")
+
+	history := func(query string) messagesPage {
+		t.Helper()
+		rec := s.do(t, http.MethodGet, "/api/v1/conversations/"+conversationID+query, user.Token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, body %s", query, rec.Code, rec.Body)
+		}
+		return decodeResponse[messagesPage](t, rec)
+	}
+	contents := func(messages []models.Message) []string {
+		result := make([]string, len(messages))
+		for i, message := range messages {
+			result[i] = message.Content
+		}
+		return result
+	}
+
+	all := history("")
+	want := []string{"um", "Echo: um", "dois", "Echo: dois", "três", "Echo: três"}
+	if got := contents(all.Messages); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("histórico %v, esperado %v", got, want)
+	}
+	if all.Total != 6 || all.HasMore || all.NextCursor != nil {
+		t.Errorf("total %d, hasMore %v, nextCursor %v, esperado 6 sem próxima página", all.Total, all.HasMore, all.NextCursor)
+	}
+
+	// before: das mais recentes para as mais antigas, em ordem cronológica em cada página
+	var older []string
+	query := "?limit=4"
+	for {
+		page := history(query)
+		older = append(contents(page.Messages), older...)
+		if page.NextCursor == nil {
+			if page.HasMore {
+				t.Error("hasMore sem nextCursor")
+			}
+			break
+		}
+		if *page.NextCursor != page.Messages[0].ID.Hex() {
+			t.Fatalf("nextCursor %v, esperado a mensagem mais antiga da página", page.NextCursor)
+		}
+		query = "?limit=4&before=" + *page.NextCursor
+	}
+	if fmt.Sprint(older) != fmt.Sprint(want) {
+		t.Errorf("páginas com before montam %v, esperado %v", older, want)
+	}
+
+	// after: mensagens novas a partir de uma conhecida
+	page := history("?limit=2&after=" + all.Messages[1].ID.Hex())
+	if got := contents(page.Messages); len(got) != 2 || got[0] != "dois" || got[1] != "Echo: dois" {
+		t.Errorf("after: %v, esperado [dois Echo: dois]", got)
+	}
+	if !page.HasMore || page.NextCursor == nil || *page.NextCursor != page.Messages[1].ID.Hex() {
+		t.Errorf("after: hasMore %v, nextCursor %v, esperado a mensagem mais nova da página", page.HasMore, page.NextCursor)
+	}
+	page = history("?after=" + *page.NextCursor)
+	if got := contents(page.Messages); len(got) != 2 || got[0] != "três" || page.HasMore {
+		t.Errorf("after, última página: %v (hasMore %v), esperado [três Echo: três]", got, page.HasMore)
+	}
+
+	// before e after juntos, limit e IDs inválidos
+	for _, query := range []string{
+		"?before=" + all.Messages[0].ID.Hex() + "&after=" + all.Messages[1].ID.Hex(),
+		"?limit=0",
+		"?before=123",
+	} {
+		rec := s.do(t, http.MethodGet, "/api/v1/conversations/"+conversationID+query, user.Token, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, esperado %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"chatserver/metrics"
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Limites da remoção definitiva de contas
+const (
+	maxAccountPurgeInterval = time.Hour
+	minAccountPurgeInterval = time.Minute
+	accountPurgeBatch       = 100
+	accountPurgeTimeout     = 5 * time.Minute
+)
+
+// ConversationDeleter remove as conversas e mensagens de um usuário
+type ConversationDeleter interface {
+	DeleteUserConversations(ctx context.Context, userID string) error
+}
+
+// ProfileOptions configura a exclusão de contas e o encerramento das conexões
+type ProfileOptions struct {
+	Conversations       ConversationDeleter // Remove os dados de chat das contas excluídas
+	DeletionGracePeriod time.Duration       // Prazo até a remoção definitiva (0 = imediata)
+	Connections         *Connections        // Conexões WebSocket encerradas com as sessões revogadas
+}
+
+// ChangePassword godoc
+// @Summary      Alterar senha
+// @Description  Altera a senha do usuário autenticado, exigindo a senha atual. As demais sessões são encerradas;
+// @Description  a sessão atual continua válida. Contas sem senha (criadas pelo login OIDC) definem uma senha
+// @Description  em até 10 minutos após um login OIDC, sem informar current_password.
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.ChangePasswordRequest  true  "Senha atual e nova senha"
+// @Success      200      {object}  map[string]string
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /profile/password [put]
+func (pc *ProfileController) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	user, ok := pc.authorizeWithPassword(ctx, c, req.CurrentPassword, "change_password")
+	if !ok {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar hash da senha"})
+		return
+	}
+
+	// O hash lido é parte da condição: duas trocas simultâneas não se sobrepõem.
+	// Um link de redefinição pendente foi pedido com a senha antiga e é descartado.
+	changed, err := pc.users.ChangePassword(ctx, user.ID, user.Password, string(hashedPassword))
+	if err != nil {
+		metrics.RecordAuthAttempt("change_password", "failure")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao alterar senha"})
+		return
+	}
+	if !changed {
+		metrics.RecordAuthAttempt("change_password", "failure")
+		c.JSON(http.StatusConflict, gin.H{"error": "A senha foi alterada por outra requisição"})
+		return
+	}
+
+	// Encerrar as outras sessões: quem conhecia a senha antiga não continua logado
+	filter := repository.SessionFilter{UserID: user.ID}
+	if sessionID, err := primitive.ObjectIDFromHex(c.GetString("session_id")); err == nil {
+		filter.ExceptID = &sessionID
+	}
+	revoked := revokeSessions(ctx, pc.sessions, pc.options.Connections, filter, models.RevokeReasonPasswordChange)
+	metrics.RecordAuthAttempt("change_password", "success")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Senha alterada",
+		"revoked_sessions": revoked,
+	})
+}
+
+// DeleteAccount godoc
+// @Summary      Excluir conta
+// @Description  Exclui a conta do usuário autenticado com todas as suas conversas e mensagens, exigindo a senha
+// @Description  (contas sem senha, criadas pelo login OIDC, em até 10 minutos após um login OIDC).
+// @Description  Com prazo de carência configurado, a conta é desativada (todas as sessões são encerradas) e
+// @Description  removida definitivamente ao fim do prazo; fazer login antes disso cancela a exclusão.
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.DeleteAccountRequest  true  "Senha atual"
+// @Success      200      {object}  models.DeleteAccountResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /profile [delete]
+func (pc *ProfileController) DeleteAccount(c *gin.Context) {
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	user, ok := pc.authorizeWithPassword(ctx, c, req.Password, "delete_account")
+	if !ok {
+		return
+	}
+
+	if pc.options.DeletionGracePeriod <= 0 {
+		purgeCtx, cancelPurge := context.WithTimeout(requestContext(c), accountPurgeTimeout)
+		defer cancelPurge()
+		if err := pc.PurgeAccount(purgeCtx, user.ID); err != nil {
+			slog.ErrorContext(ctx, "Erro ao excluir conta", "user_id", user.ID, "error", err)
+			metrics.RecordAuthAttempt("delete_account", "failure")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir conta"})
+			return
+		}
+		metrics.RecordAuthAttempt("delete_account", "success")
+		c.JSON(http.StatusOK, models.DeleteAccountResponse{Message: "Conta excluída"})
+		return
+	}
+
+	deleteAfter := time.Now().Add(pc.options.DeletionGracePeriod)
+	if err := pc.users.ScheduleDeletion(ctx, user.ID, deleteAfter); err != nil {
+		metrics.RecordAuthAttempt("delete_account", "failure")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir conta"})
+		return
+	}
+	revokeSessions(ctx, pc.sessions, pc.options.Connections, repository.SessionFilter{UserID: user.ID}, models.RevokeReasonAccountDeleted)
+	revokeAPIKeys(ctx, pc.apiKeys, pc.options.Connections, user.ID)
+	metrics.RecordAuthAttempt("delete_account", "success")
+
+	c.JSON(http.StatusOK, models.DeleteAccountResponse{
+		Message:              "Conta agendada para exclusão; faça login antes da data para cancelar",
+		DeletionScheduledFor: &deleteAfter,
+	})
+}
+
+// authorizeWithPassword carrega o usuário autenticado e confere a senha informada,
+// respondendo a requisição quando não confere. Contas sem senha (criadas pelo
+// login OIDC) confirmam com um login OIDC recente.
+func (pc *ProfileController) authorizeWithPassword(ctx context.Context, c *gin.Context, password, action string) (*models.User, bool) {
+	userID := c.GetString("user_id")
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return nil, false
+	}
+
+	user, err := pc.users.FindByID(ctx, userID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não encontrado"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuário"})
+		return nil, false
+	}
+
+	if user.Password == "" {
+		if recentOIDCLogin(ctx, pc.sessions, c, user) {
+			return user, true
+		}
+		metrics.RecordAuthAttempt(action, "failure")
+		c.JSON(http.StatusForbidden, gin.H{"error": "A conta não tem senha: entre novamente pelo provedor de identidade " +
+			"e repita em até 10 minutos, ou defina uma senha em /auth/forgot-password"})
+		return nil, false
+	}
+
+	if err := user.CheckPassword(password); err != nil {
+		metrics.RecordAuthAttempt(action, "failure")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Senha atual incorreta"})
+		return nil, false
+	}
+	return user, true
+}
+
+// PurgeAccount remove definitivamente a conta, suas sessões, chaves de API, conversas e mensagens.
+// O documento do usuário é removido por último: se algo falhar, a remoção é
+// retomada na próxima execução do purgeAccounts. Também usado pela exclusão
+// imediata feita por um administrador.
+func (pc *ProfileController) PurgeAccount(ctx context.Context, userID string) error {
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		return err
+	}
+	if pc.options.Conversations != nil {
+		if err := pc.options.Conversations.DeleteUserConversations(ctx, userID); err != nil {
+			return err
+		}
+	}
+	if err := pc.sessions.DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := pc.apiKeys.DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	return pc.users.Delete(ctx, userID)
+}
+
+// startAccountPurger remove periodicamente as contas cujo prazo de carência terminou
+func (pc *ProfileController) startAccountPurger() {
+	interval := pc.options.DeletionGracePeriod
+	if interval > maxAccountPurgeInterval {
+		interval = maxAccountPurgeInterval
+	}
+	if interval < minAccountPurgeInterval {
+		interval = minAccountPurgeInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			pc.purgeAccounts()
+			<-ticker.C
+		}
+	}()
+}
+
+// purgeAccounts remove as contas com exclusão vencida
+func (pc *ProfileController) purgeAccounts() {
+	ctx, cancel := context.WithTimeout(context.Background(), accountPurgeTimeout)
+	defer cancel()
+
+	users, err := pc.users.FindDeletionDue(ctx, time.Now(), accountPurgeBatch)
+	if err != nil {
+		slog.ErrorContext(ctx, "Erro ao buscar contas para exclusão", "error", err)
+		return
+	}
+
+	for _, user := range users {
+		if err := pc.PurgeAccount(ctx, user.ID); err != nil {
+			slog.ErrorContext(ctx, "Erro ao excluir conta", "user_id", user.ID, "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "Conta excluída definitivamente", "user_id", user.ID)
+	}
+}
@@ -6,21 +6,28 @@ import (
 	"time"
 
 	"chatserver/models"
+	"chatserver/repository"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type ProfileController struct {
-	userCollection *mongo.Collection
+	users    repository.UserRepository
+	sessions repository.SessionRepository
+	apiKeys  repository.APIKeyRepository
+	options  ProfileOptions
 }
 
-func NewProfileController(db *mongo.Database) *ProfileController {
-	return &ProfileController{
-		userCollection: db.Collection("users"),
+func NewProfileController(repos repository.Repositories, options ProfileOptions) *ProfileController {
+	pc := &ProfileController{
+		users:    repos.Users,
+		sessions: repos.Sessions,
+		apiKeys:  repos.APIKeys,
+		options:  options,
 	}
+	pc.startAccountPurger()
+	return pc
 }
 
 // GetProfile godoc
@@ -43,21 +50,19 @@ func (pc *ProfileController) GetProfile(c *gin.Context) {
 		return
 	}
 
-	// Converter string para ObjectID
-	objectID, err := primitive.ObjectIDFromHex(userID.(string))
-	if err != nil {
+	// Validar o ID
+	if _, err := primitive.ObjectIDFromHex(userID.(string)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuário inválido"})
 		return
 	}
 
 	// Buscar usuário no banco
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
 	defer cancel()
 
-	var user models.User
-	err = pc.userCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	user, err := pc.users.FindByID(ctx, userID.(string))
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
 			return
 		}
@@ -67,9 +72,12 @@ func (pc *ProfileController) GetProfile(c *gin.Context) {
 
 	// Retornar profile (com valores nulos se não existirem)
 	c.JSON(http.StatusOK, models.ProfileResponse{
-		Email: user.Email,
-		Name:  user.Name,
-		Bio:   user.Bio,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          user.EffectiveRole(),
+		MFAEnabled:    user.MFAEnabled,
+		Name:          user.Name,
+		Bio:           user.Bio,
 	})
 }
 
@@ -95,9 +103,8 @@ func (pc *ProfileController) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	// Converter string para ObjectID
-	objectID, err := primitive.ObjectIDFromHex(userID.(string))
-	if err != nil {
+	// Validar o ID
+	if _, err := primitive.ObjectIDFromHex(userID.(string)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuário inválido"})
 		return
 	}
@@ -109,34 +116,13 @@ func (pc *ProfileController) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	// Preparar update
-	update := bson.M{
-		"$set": bson.M{
-			"updated_at": time.Now(),
-		},
-	}
-
-	// Adicionar campos ao update apenas se foram fornecidos
-	if req.Name != nil {
-		update["$set"].(bson.M)["name"] = req.Name
-	}
-	if req.Bio != nil {
-		update["$set"].(bson.M)["bio"] = req.Bio
-	}
-
-	// Atualizar no banco
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Atualizar no banco apenas os campos fornecidos
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
 	defer cancel()
 
-	result := pc.userCollection.FindOneAndUpdate(
-		ctx,
-		bson.M{"_id": objectID},
-		update,
-		// Opções para retornar o documento atualizado
-	)
-
-	if result.Err() != nil {
-		if result.Err() == mongo.ErrNoDocuments {
+	updatedUser, err := pc.users.UpdateProfile(ctx, userID.(string), req.Name, req.Bio)
+	if err != nil {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
 			return
 		}
@@ -144,19 +130,13 @@ func (pc *ProfileController) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	// Buscar usuário atualizado
-	var updatedUser models.User
-	err = pc.userCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&updatedUser)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuário atualizado"})
-		return
-	}
-
 	// Retornar perfil atualizado
 	c.JSON(http.StatusOK, models.ProfileResponse{
-		Email: updatedUser.Email,
-		Name:  updatedUser.Name,
-		Bio:   updatedUser.Bio,
+		Email:         updatedUser.Email,
+		EmailVerified: updatedUser.EmailVerified,
+		MFAEnabled:    updatedUser.MFAEnabled,
+		Name:          updatedUser.Name,
+		Bio:           updatedUser.Bio,
 	})
 }
 
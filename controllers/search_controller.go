@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chatserver/models"
+	"chatserver/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Parâmetros da busca
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	snippetRadius      = 80 // Caracteres exibidos antes e depois do termo encontrado
+)
+
+// Tipos de resultado da busca
+const (
+	SearchHitConversation = "conversation"
+	SearchHitMessage      = "message"
+)
+
+// SearchHit representa um resultado da busca. O snippet tem o HTML escapado e os
+// termos encontrados envolvidos em <mark></mark>.
+type SearchHit struct {
+	Type              string             `json:"type"` // conversation ou message
+	ConversationID    string             `json:"conversationId"`
+	ConversationTitle string             `json:"conversationTitle"`
+	MessageID         string             `json:"messageId,omitempty"`
+	Role              models.MessageRole `json:"role,omitempty"`
+	Snippet           string             `json:"snippet"`
+	Score             float64            `json:"score"`
+	CreatedAt         time.Time          `json:"createdAt"`
+}
+
+// SearchResponse representa a resposta da busca
+type SearchResponse struct {
+	Query string      `json:"query"`
+	Hits  []SearchHit `json:"hits"`
+	Total int         `json:"total"`
+}
+
+// SearchController busca nas conversas e mensagens do usuário
+type SearchController struct {
+	conversations repository.ConversationRepository
+	messages      repository.MessageRepository
+}
+
+// NewSearchController cria uma nova instância do controller
+func NewSearchController(conversations repository.ConversationRepository, messages repository.MessageRepository) *SearchController {
+	return &SearchController{
+		conversations: conversations,
+		messages:      messages,
+	}
+}
+
+// Search godoc
+// @Summary      Buscar nas conversas
+// @Description  Busca textual no conteúdo das mensagens e no título das conversas do usuário autenticado,
+// @Description  ordenada por relevância. Suporta frases entre aspas e exclusão de termos com "-".
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        q      query     string  true   "Termos da busca"
+// @Param        limit  query     int     false  "Máximo de resultados (padrão 20, máximo 50)"
+// @Success      200    {object}  SearchResponse
+// @Failure      400    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /api/v1/search [get]
+func (sc *SearchController) Search(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parâmetro q é obrigatório"})
+		return
+	}
+
+	limit, err := parseLimit(c, defaultSearchLimit, maxSearchLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(c), 10*time.Second)
+	defer cancel()
+
+	// 1. Conversas do usuário: restringem a busca de mensagens e fornecem os títulos
+	conversations, err := sc.conversations.FindByUser(ctx, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar conversas"})
+		return
+	}
+
+	response := SearchResponse{Query: query, Hits: []SearchHit{}}
+	if len(conversations) == 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	titles := make(map[primitive.ObjectID]string, len(conversations))
+	conversationIDs := make([]primitive.ObjectID, 0, len(conversations))
+	for _, conversation := range conversations {
+		titles[conversation.ID] = conversation.Title
+		conversationIDs = append(conversationIDs, conversation.ID)
+	}
+
+	terms := searchTerms(query)
+
+	// 2. Conversas cujo título corresponde à busca
+	titleHits, err := sc.conversations.Search(ctx, userID.(string), query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar conversas"})
+		return
+	}
+
+	for _, hit := range titleHits {
+		response.Hits = append(response.Hits, SearchHit{
+			Type:              SearchHitConversation,
+			ConversationID:    hit.ID.Hex(),
+			ConversationTitle: hit.Title,
+			Snippet:           highlightSnippet(hit.Title, terms),
+			Score:             hit.Score,
+			CreatedAt:         hit.CreatedAt,
+		})
+	}
+
+	// 3. Mensagens das conversas do usuário que correspondem à busca
+	messageHits, err := sc.messages.Search(ctx, conversationIDs, query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar mensagens"})
+		return
+	}
+
+	for _, hit := range messageHits {
+		response.Hits = append(response.Hits, SearchHit{
+			Type:              SearchHitMessage,
+			ConversationID:    hit.ConversationID.Hex(),
+			ConversationTitle: titles[hit.ConversationID],
+			MessageID:         hit.ID.Hex(),
+			Role:              hit.Role,
+			Snippet:           highlightSnippet(hit.Content, terms),
+			Score:             hit.Score,
+			CreatedAt:         hit.CreatedAt,
+		})
+	}
+
+	// Juntar os dois tipos de resultado por relevância
+	sort.SliceStable(response.Hits, func(i, j int) bool {
+		return response.Hits[i].Score > response.Hits[j].Score
+	})
+	if int64(len(response.Hits)) > limit {
+		response.Hits = response.Hits[:limit]
+	}
+	response.Total = len(response.Hits)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// searchTerms extrai os termos a destacar da busca, ignorando exclusões ("-termo")
+// e mantendo frases entre aspas
+func searchTerms(query string) []string {
+	var terms []string
+
+	for i, part := range strings.Split(query, `"`) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		// Partes ímpares estavam entre aspas
+		if i%2 == 1 {
+			terms = append(terms, part)
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			if !strings.HasPrefix(word, "-") {
+				terms = append(terms, word)
+			}
+		}
+	}
+
+	// Termos maiores primeiro para que frases tenham prioridade no destaque
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	return terms
+}
+
+// highlightSnippet recorta o texto ao redor do primeiro termo encontrado e
+// destaca todas as ocorrências dos termos com <mark>
+func highlightSnippet(text string, terms []string) string {
+	// Posição do primeiro termo encontrado (a busca do MongoDB usa stemming,
+	// então pode não haver correspondência exata)
+	first := -1
+	for i := 0; i < len(text) && first < 0; i += runeSize(text[i:]) {
+		if matchTerm(text[i:], terms) != "" {
+			first = i
+		}
+	}
+
+	start, end := 0, len(text)
+	if first >= 0 {
+		start = max(0, first-snippetRadius)
+		end = min(len(text), first+snippetRadius)
+	} else {
+		end = min(len(text), 2*snippetRadius)
+	}
+
+	// Ajustar os limites para não cortar caracteres UTF-8
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	snippet := text[start:end]
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := 0; i < len(snippet); {
+		if matched := matchTerm(snippet[i:], terms); matched != "" {
+			b.WriteString("<mark>" + html.EscapeString(matched) + "</mark>")
+			i += len(matched)
+			continue
+		}
+		size := runeSize(snippet[i:])
+		b.WriteString(html.EscapeString(snippet[i : i+size]))
+		i += size
+	}
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+// matchTerm retorna o trecho do início de text que corresponde a algum termo
+// (sem diferenciar maiúsculas), ou "" se nenhum corresponder
+func matchTerm(text string, terms []string) string {
+	for _, term := range terms {
+		if len(term) <= len(text) && strings.EqualFold(text[:len(term)], term) {
+			return text[:len(term)]
+		}
+	}
+	return ""
+}
+
+// runeSize retorna o tamanho em bytes do primeiro caractere de text
+func runeSize(text string) int {
+	_, size := utf8.DecodeRuneInString(text)
+	return max(size, 1)
+}
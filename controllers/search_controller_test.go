@@ -0,0 +1,112 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"chatserver/controllers"
+	"chatserver/models"
+)
+
+// seedConversation cria uma conversa com título e mensagens direto no repositório
+func (s *testServer) seedConversation(t *testing.T, userID, title string, contents ...string) *models.Conversation {
+	t.Helper()
+
+	ctx := context.Background()
+	conversation := models.NewConversation(userID)
+	conversation.Title = title
+	if err := s.repos.Conversations.Create(ctx, conversation); err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range contents {
+		if err := s.repos.Messages.Create(ctx, models.NewMessage(conversation.ID, models.RoleUser, content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return conversation
+}
+
+// search faz a busca e retorna a resposta
+func (s *testServer) search(t *testing.T, token, query string) controllers.SearchResponse {
+	t.Helper()
+
+	rec := s.do(t, http.MethodGet, "/api/v1/search?"+query, token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("busca %q: status %d, body %s", query, rec.Code, rec.Body)
+	}
+	return decodeResponse[controllers.SearchResponse](t, rec)
+}
+
+func TestSearchEscapesSnippet(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "yara@example.com", "secret123")
+	s.seedConversation(t, user.UserID, "Sem relação", `<script>alert("gato")</script> Gato & cia`, "a<b vale para todo a")
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"gato", `&lt;script&gt;alert(&#34;<mark>gato</mark>&#34;)&lt;/script&gt; <mark>Gato</mark> &amp; cia`},
+		// O termo também é escapado dentro do <mark>
+		{`"a<b"`, `<mark>a&lt;b</mark> vale para todo a`},
+	}
+	for _, tt := range tests {
+		response := s.search(t, user.Token, "q="+url.QueryEscape(tt.query))
+		if len(response.Hits) != 1 {
+			t.Fatalf("busca %q: %d resultados, esperado 1", tt.query, len(response.Hits))
+		}
+		if got := response.Hits[0].Snippet; got != tt.want {
+			t.Errorf("busca %q: snippet %q, esperado %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchMergesByScore(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "zeca@example.com", "secret123")
+	conversation := s.seedConversation(t, user.UserID, "Gato gato gato", "um gato", "gato e mais gato")
+	other := s.register(t, "alice@example.com", "secret123")
+	s.seedConversation(t, other.UserID, "Gato gato gato gato", "gato gato gato gato gato")
+
+	// Título (3 ocorrências) e mensagens (2 e 1) intercalados pela relevância,
+	// sem as conversas de outro usuário
+	response := s.search(t, user.Token, "q=gato")
+	want := []struct {
+		hitType string
+		snippet string
+	}{
+		{controllers.SearchHitConversation, "<mark>Gato</mark> <mark>gato</mark> <mark>gato</mark>"},
+		{controllers.SearchHitMessage, "<mark>gato</mark> e mais <mark>gato</mark>"},
+		{controllers.SearchHitMessage, "um <mark>gato</mark>"},
+	}
+	if len(response.Hits) != len(want) || response.Total != len(want) {
+		t.Fatalf("%d resultados (total %d), esperado %d: %+v", len(response.Hits), response.Total, len(want), response.Hits)
+	}
+	for i, hit := range response.Hits {
+		if hit.Type != want[i].hitType || hit.Snippet != want[i].snippet {
+			t.Errorf("resultado %d: %s %q, esperado %s %q", i, hit.Type, hit.Snippet, want[i].hitType, want[i].snippet)
+		}
+		if hit.ConversationID != conversation.ID.Hex() || hit.ConversationTitle != conversation.Title {
+			t.Errorf("resultado %d: conversa %s %q, esperado %s %q", i, hit.ConversationID, hit.ConversationTitle, conversation.ID.Hex(), conversation.Title)
+		}
+	}
+
+	// O limite vale para a lista já intercalada
+	response = s.search(t, user.Token, "q=gato&limit=2")
+	if len(response.Hits) != 2 || response.Total != 2 || response.Hits[0].Type != controllers.SearchHitConversation || response.Hits[1].Snippet != want[1].snippet {
+		t.Errorf("com limit=2: %+v, esperado os dois primeiros resultados", response.Hits)
+	}
+
+	// Exclusão de termos
+	response = s.search(t, user.Token, "q="+url.QueryEscape("gato -mais"))
+	if len(response.Hits) != 2 {
+		t.Errorf("com exclusão: %d resultados, esperado 2", len(response.Hits))
+	}
+
+	rec := s.do(t, http.MethodGet, "/api/v1/search?q=", user.Token, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("busca vazia: status %d, esperado %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -2,9 +2,13 @@ package database
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
+	"chatserver/models"
+	"chatserver/tracing"
+
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -19,8 +23,9 @@ func Connect(mongoURI, dbName string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	clientOptions := options.Client().ApplyURI(mongoURI)
-	
+	// O monitor registra um span para cada comando executado durante uma requisição rastreada
+	clientOptions := options.Client().ApplyURI(mongoURI).SetMonitor(tracing.NewMongoMonitor())
+
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return err
@@ -35,7 +40,7 @@ func Connect(mongoURI, dbName string) error {
 	Client = client
 	Database = client.Database(dbName)
 
-	log.Println("✅ Conectado ao MongoDB Atlas!")
+	slog.Info("Conectado ao MongoDB", "database", dbName)
 	return nil
 }
 
@@ -51,7 +56,103 @@ func Disconnect() error {
 	return Client.Disconnect(ctx)
 }
 
-// GetCollection retorna uma collection do MongoDB
-func GetCollection(collectionName string) *mongo.Collection {
-	return Database.Collection(collectionName)
+// EnsureIndexes cria os índices usados pelas consultas da API (operação idempotente)
+func EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	indexes := map[string][]mongo.IndexModel{
+		"conversations": {
+			// Listagem paginada de conversas do usuário (updatedAt desc, _id desc)
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "updatedAt", Value: -1}, {Key: "_id", Value: -1}}},
+			// Busca textual no título
+			{Keys: bson.D{{Key: "title", Value: "text"}}, Options: textIndexOptions()},
+			// Usuários ativos recentemente (métrica active_users_total)
+			{Keys: bson.D{{Key: "updatedAt", Value: -1}, {Key: "userId", Value: 1}}},
+			// Bifurcações de uma conversa
+			{Keys: bson.D{{Key: "parentConversationId", Value: 1}}, Options: options.Index().SetSparse(true)},
+		},
+		"messages": {
+			// Histórico paginado por _id dentro da conversa
+			{Keys: bson.D{{Key: "conversationId", Value: 1}, {Key: "_id", Value: -1}}},
+			// Busca textual no conteúdo
+			{Keys: bson.D{{Key: "content", Value: "text"}}, Options: textIndexOptions()},
+			// Respostas assíncronas pendentes: renovação do lease de cada instância
+			// e encerramento das que tiveram o lease vencido
+			{
+				Keys: bson.D{{Key: "leaseOwner", Value: 1}},
+				Options: options.Index().
+					SetPartialFilterExpression(bson.M{"status": models.StatusPending}),
+			},
+			{
+				Keys: bson.D{{Key: "leaseUntil", Value: 1}},
+				Options: options.Index().
+					SetPartialFilterExpression(bson.M{"status": models.StatusPending}),
+			},
+		},
+		"users": {
+			// Um email por conta: o cadastro e o primeiro login OIDC verificam o email
+			// antes de criar a conta, mas só o índice impede duas criações simultâneas
+			{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+			// Busca do token de redefinição de senha
+			{Keys: bson.D{{Key: "password_reset_token_hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+			// Busca do token de verificação de email
+			{Keys: bson.D{{Key: "email_verification_token_hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+			// Contas desativadas por um administrador
+			{Keys: bson.D{{Key: "disabled_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+			// Contas com exclusão agendada
+			{Keys: bson.D{{Key: "deletion_scheduled_for", Value: 1}}, Options: options.Index().SetSparse(true)},
+			// Conta do provedor de identidade (OIDC) vinculada
+			{
+				Keys: bson.D{{Key: "oidc_issuer", Value: 1}, {Key: "oidc_subject", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"oidc_subject": bson.M{"$exists": true}}),
+			},
+		},
+		"sessions": {
+			// Rotação e detecção de reuso de refresh tokens
+			{Keys: bson.D{{Key: "refresh_token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "previous_token_hashes", Value: 1}}},
+			// Sessões do usuário (revogação em massa)
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+			// Remove sessões cujo refresh token expirou
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"api_keys": {
+			// Autenticação por chave de API
+			{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			// Chaves do usuário (listagem e revogação em massa)
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		"admin_audit": {
+			// Trilha de auditoria filtrada por autor ou alvo (mais recentes primeiro)
+			{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "_id", Value: -1}}},
+			{Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "_id", Value: -1}}},
+		},
+		"oidc_states": {
+			// Remove logins OIDC não concluídos
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"login_attempts": {
+			// Remove contadores de falhas de login esquecidos
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+	}
+
+	for collection, indexModels := range indexes {
+		if _, err := Database.Collection(collection).Indexes().CreateMany(ctx, indexModels); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Índices do MongoDB verificados")
+	return nil
+}
+
+// textIndexOptions configura os índices de texto para português. O campo de
+// idioma padrão ("language") é trocado para não conflitar com campos dos documentos.
+func textIndexOptions() *options.IndexOptions {
+	return options.Index().
+		SetDefaultLanguage("portuguese").
+		SetLanguageOverride("textSearchLanguage")
 }
@@ -0,0 +1,152 @@
+// Package keys manages the keys used to sign and verify the API's JWTs.
+//
+// A KeySet has one signing key and any number of verification keys, each
+// identified by a key ID (kid) written in the token header. Rotating keys is a
+// matter of adding the new key, switching the signing key ID and removing the
+// old key once the tokens it signed have expired.
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// Key is a signing or verification key
+type Key struct {
+	ID        string
+	Algorithm string
+	private   interface{} // []byte (HMAC), *rsa.PrivateKey or *ecdsa.PrivateKey; nil for verification-only keys
+	public    interface{} // []byte (HMAC), *rsa.PublicKey or *ecdsa.PublicKey
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("key %q: HMAC secret must have at least 32 bytes", id)
+	}
+	return &Key{ID: id, Algorithm: HS256, private: secret, public: secret}, nil
+}
+
+// NewPrivateKey creates a signing key from an RSA (RS256) or P-256 ECDSA (ES256) private key
+func NewPrivateKey(id string, key interface{}) (*Key, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Algorithm: RS256, private: k, public: &k.PublicKey}, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("key %q: ES256 requires a P-256 key", id)
+		}
+		return &Key{ID: id, Algorithm: ES256, private: k, public: &k.PublicKey}, nil
+	}
+	return nil, fmt.Errorf("key %q: unsupported private key type %T", id, key)
+}
+
+// NewPublicKey creates a verification-only key from an RSA or P-256 ECDSA public key
+func NewPublicKey(id string, key interface{}) (*Key, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: id, Algorithm: RS256, public: k}, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("key %q: ES256 requires a P-256 key", id)
+		}
+		return &Key{ID: id, Algorithm: ES256, public: k}, nil
+	}
+	return nil, fmt.Errorf("key %q: unsupported public key type %T", id, key)
+}
+
+// CanSign reports whether the key holds the private part
+func (k *Key) CanSign() bool {
+	return k.private != nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// KeySet signs tokens with its signing key and verifies them with any of its keys
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewKeySet creates a KeySet that signs with the key signingKeyID
+func NewKeySet(signingKeyID string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, key := range keys {
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	signing, ok := ks.keys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", signingKeyID)
+	}
+	if !signing.CanSign() {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKeyID)
+	}
+	ks.signing = signing
+	return ks, nil
+}
+
+// SigningKey returns the key used to sign new tokens
+func (ks *KeySet) SigningKey() *Key {
+	return ks.signing
+}
+
+// Keys returns all keys sorted by ID
+func (ks *KeySet) Keys() []*Key {
+	keys := make([]*Key, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// Sign signs the claims with the signing key, writing its ID in the "kid" header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method(), claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.private)
+}
+
+// Parse verifies the token signature with the key named in its "kid" header
+// and decodes it into claims. The token algorithm must match the key's.
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, ks.keyfunc, opts...)
+}
+
+func (ks *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		// Tokens issued before key IDs were introduced
+		if kid != "" || len(ks.keys) != 1 {
+			return nil, fmt.Errorf("unknown key ID %q", kid)
+		}
+		key = ks.signing
+	}
+
+	// Never let the token choose the algorithm (e.g. HS256 with an RSA public key)
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("token algorithm does not match the key")
+	}
+	return key.public, nil
+}
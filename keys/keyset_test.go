@@ -0,0 +1,281 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newRSAKey(t *testing.T, id string) (*Key, *rsa.PrivateKey) {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewPrivateKey(id, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, private
+}
+
+func newECKey(t *testing.T, id string) (*Key, *ecdsa.PrivateKey) {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewPrivateKey(id, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, private
+}
+
+func newHMACKey(t *testing.T, id string) *Key {
+	t.Helper()
+	key, err := NewHMACKey(id, []byte("test-secret-with-at-least-32-bytes!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newKeySet(t *testing.T, signingKeyID string, keys ...*Key) *KeySet {
+	t.Helper()
+	ks, err := NewKeySet(signingKeyID, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+// parseSubject verifies the token and returns its subject
+func parseSubject(ks *KeySet, token string) (string, error) {
+	var claims jwt.RegisteredClaims
+	if _, err := ks.Parse(token, &claims); err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+func TestSignParseRoundTrip(t *testing.T) {
+	rsaKey, _ := newRSAKey(t, "rsa")
+	ecKey, _ := newECKey(t, "ec")
+	hmacKey := newHMACKey(t, "hmac")
+
+	for _, key := range []*Key{rsaKey, ecKey, hmacKey} {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			ks := newKeySet(t, key.ID, key)
+			token, err := ks.Sign(jwt.RegisteredClaims{Subject: "user-1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["kid"] != key.ID || parsed.Header["alg"] != key.Algorithm {
+				t.Errorf("header %v, want kid %q and alg %q", parsed.Header, key.ID, key.Algorithm)
+			}
+
+			subject, err := parseSubject(ks, token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if subject != "user-1" {
+				t.Errorf("subject %q, want %q", subject, "user-1")
+			}
+		})
+	}
+}
+
+func TestParseRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, private := newRSAKey(t, "rsa")
+	ks := newKeySet(t, "rsa", rsaKey)
+
+	// HS256 signed with the published RSA public key as the HMAC secret
+	publicDER, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	for _, secret := range [][]byte{publicPEM, publicDER} {
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "admin"})
+		forged.Header["kid"] = "rsa"
+		token, err := forged.SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseSubject(ks, token); err == nil {
+			t.Error("HS256 token signed with the RSA public key was accepted")
+		}
+	}
+
+	// alg none
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{Subject: "admin"})
+	unsigned.Header["kid"] = "rsa"
+	token, err := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseSubject(ks, token); err == nil {
+		t.Error("unsigned token was accepted")
+	}
+}
+
+func TestParseRejectsUnknownKeyID(t *testing.T) {
+	current, _ := newECKey(t, "current")
+	other, _ := newECKey(t, "other")
+
+	token, err := newKeySet(t, "other", other).Sign(jwt.RegisteredClaims{Subject: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseSubject(newKeySet(t, "current", current), token); err == nil {
+		t.Error("token with an unknown kid was accepted")
+	}
+}
+
+func TestParseAcceptsRetiredKey(t *testing.T) {
+	retired, _ := newRSAKey(t, "2024")
+	current, _ := newECKey(t, "2025")
+
+	token, err := newKeySet(t, "2024", retired).Sign(jwt.RegisteredClaims{Subject: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After the rotation the old key only verifies the tokens it signed
+	rotated := newKeySet(t, "2025", retired, current)
+	if rotated.SigningKey().ID != "2025" {
+		t.Fatalf("signing key %q, want %q", rotated.SigningKey().ID, "2025")
+	}
+	if subject, err := parseSubject(rotated, token); err != nil || subject != "user-1" {
+		t.Errorf("token signed by the retired key: subject %q, error %v", subject, err)
+	}
+
+	// Once removed, its tokens are rejected
+	if _, err := parseSubject(newKeySet(t, "2025", current), token); err == nil {
+		t.Error("token signed by a removed key was accepted")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, rsaPrivate := newRSAKey(t, "rsa")
+	ecKey, ecPrivate := newECKey(t, "ec")
+	ks := newKeySet(t, "rsa", rsaKey, ecKey, newHMACKey(t, "hmac"))
+
+	set := ks.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("%d keys published, want 2 (HMAC keys are secret)", len(set.Keys))
+	}
+
+	want := map[string]interface{}{"rsa": &rsaPrivate.PublicKey, "ec": &ecPrivate.PublicKey}
+	for _, jwk := range set.Keys {
+		if jwk.KeyID == "hmac" || jwk.KeyType == "oct" {
+			t.Errorf("HMAC key published: %+v", jwk)
+			continue
+		}
+		if jwk.Use != "sig" {
+			t.Errorf("%s: use %q, want sig", jwk.KeyID, jwk.Use)
+		}
+		public, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatalf("%s: %v", jwk.KeyID, err)
+		}
+		if !reflect.DeepEqual(public, want[jwk.KeyID]) {
+			t.Errorf("%s: public key does not round-trip", jwk.KeyID)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writePEM := func(name, blockType string, der []byte) {
+		t.Helper()
+		data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, rsaPrivate := newRSAKey(t, "")
+	_, ecPrivate := newECKey(t, "")
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(rsaPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(ecPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&ecPrivate.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM("pkcs1.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPrivate))
+	writePEM("pkcs8.pem", "PRIVATE KEY", pkcs8)
+	writePEM("sec1.pem", "EC PRIVATE KEY", sec1)
+	writePEM("public.pem", "PUBLIC KEY", public)
+	if err := os.WriteFile(filepath.Join(dir, "hmac.secret"), []byte("test-secret-with-at-least-32-bytes!\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Several keys can sign: the signing key ID is required
+	if _, err := loadDir(dir, ""); err == nil {
+		t.Error("missing signing key ID: expected an error")
+	}
+
+	ks, err := loadDir(dir, "sec1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct {
+		algorithm string
+		canSign   bool
+	}{
+		"pkcs1":  {RS256, true},
+		"pkcs8":  {RS256, true},
+		"sec1":   {ES256, true},
+		"public": {ES256, false},
+		"hmac":   {HS256, true},
+	}
+	keys := ks.Keys()
+	if len(keys) != len(want) {
+		t.Fatalf("%d keys loaded, want %d", len(keys), len(want))
+	}
+	for _, key := range keys {
+		w, ok := want[key.ID]
+		if !ok {
+			t.Errorf("unexpected key %q", key.ID)
+			continue
+		}
+		if key.Algorithm != w.algorithm || key.CanSign() != w.canSign {
+			t.Errorf("%s: %s (can sign %v), want %s (can sign %v)", key.ID, key.Algorithm, key.CanSign(), w.algorithm, w.canSign)
+		}
+	}
+
+	// The public key verifies the tokens signed with its private key (sec1)
+	token, err := ks.Sign(jwt.RegisteredClaims{Subject: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := newKeySet(t, "pkcs8", ks.keys["pkcs8"], &Key{ID: "sec1", Algorithm: ES256, public: ks.keys["public"].public})
+	if _, err := parseSubject(verifier, token); err != nil {
+		t.Errorf("token verified with the public key: %v", err)
+	}
+}
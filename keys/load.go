@@ -0,0 +1,144 @@
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultKeyID is the ID of the key created from Config.Secret
+const DefaultKeyID = "default"
+
+// Config describes where the keys come from
+type Config struct {
+	// Dir holds one file per key, named after its ID: "<kid>.pem" with an RSA or
+	// P-256 ECDSA key in PEM (private keys sign and verify, public keys only
+	// verify) or "<kid>.secret" with an HMAC secret.
+	Dir string
+	// SigningKeyID selects the key in Dir used to sign new tokens. Optional
+	// when Dir has a single key that can sign.
+	SigningKeyID string
+	// Secret is the HMAC secret used when Dir is not set
+	Secret string
+}
+
+// Load builds the KeySet described by cfg. With neither Dir nor Secret set a
+// random HMAC key is generated, so tokens do not survive a restart; ephemeral
+// reports whether that happened.
+func Load(cfg Config) (ks *KeySet, ephemeral bool, err error) {
+	if cfg.Dir != "" {
+		ks, err := loadDir(cfg.Dir, cfg.SigningKeyID)
+		return ks, false, err
+	}
+
+	secret := []byte(cfg.Secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, false, err
+		}
+		ephemeral = true
+	}
+
+	key, err := NewHMACKey(DefaultKeyID, secret)
+	if err != nil {
+		return nil, false, err
+	}
+	ks, err = NewKeySet(DefaultKeyID, key)
+	return ks, ephemeral, err
+}
+
+func loadDir(dir, signingKeyID string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	var signers []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if ext != ".pem" && ext != ".secret" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(name, ext)
+		var key *Key
+		if ext == ".secret" {
+			key, err = NewHMACKey(id, []byte(strings.TrimSpace(string(data))))
+		} else {
+			key, err = parsePEMKey(id, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+		if key.CanSign() {
+			signers = append(signers, id)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found in %s", dir)
+	}
+	if signingKeyID == "" {
+		if len(signers) != 1 {
+			sort.Strings(signers)
+			return nil, fmt.Errorf("signing key ID required, keys that can sign: %v", signers)
+		}
+		signingKeyID = signers[0]
+	}
+
+	return NewKeySet(signingKeyID, keys...)
+}
+
+// parsePEMKey accepts PKCS#8, PKCS#1 (RSA) and SEC 1 (EC) private keys and PKIX public keys
+func parsePEMKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: invalid PEM", id)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		return NewPrivateKey(id, key)
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		return NewPrivateKey(id, key)
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		return NewPrivateKey(id, key)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		return NewPublicKey(id, key)
+	}
+	return nil, fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+}
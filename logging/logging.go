@@ -0,0 +1,124 @@
+// Package logging configures the structured logger (log/slog) used by the
+// server. Fields attached to a context with With (request ID, user ID,
+// conversation ID) and the trace of the context are added to every entry
+// logged with the *Context functions of slog (slog.InfoContext, ...).
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Supported output formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New creates a logger that writes to w in the given format (json or text)
+// and discards entries below level (debug, info, warn or error)
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q (use debug, info, warn or error)", level)
+	}
+	options := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	case FormatText:
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (use json or text)", format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// RequestIDHeader carries the request ID from the client (or proxy), back in
+// the response and forward to the assistant backend
+const RequestIDHeader = "X-Request-ID"
+
+type attrsKey struct{}
+type requestIDKey struct{}
+
+// With returns a copy of ctx whose log entries include the given fields,
+// as key-value pairs or slog.Attr values (same as slog.Logger.With)
+func With(ctx context.Context, args ...any) context.Context {
+	record := slog.NewRecord(time.Time{}, 0, "", 0)
+	record.Add(args...)
+
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	attrs := make([]slog.Attr, len(existing), len(existing)+record.NumAttrs())
+	copy(attrs, existing)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID, which is
+// logged as request_id and forwarded to the services called by the request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	return With(ctx, "request_id", requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or an empty string
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// contextHandler adds the fields of the context to each entry
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+			record.AddAttrs(missingAttrs(record, attrs)...)
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			record.AddAttrs(
+				slog.String("trace_id", sc.TraceID().String()),
+				slog.String("span_id", sc.SpanID().String()),
+			)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// missingAttrs returns the attributes whose keys the entry does not set yet,
+// so that an explicit field (e.g. user_id) is not repeated by the context
+func missingAttrs(record slog.Record, attrs []slog.Attr) []slog.Attr {
+	keys := make(map[string]bool, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		keys[attr.Key] = true
+		return true
+	})
+	missing := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		if !keys[attr.Key] {
+			missing = append(missing, attr)
+		}
+	}
+	return missing
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes each email to a .eml file in a directory or, without a
+// directory, to the log. Meant for local development and tests: links sent by
+// email (password reset, verification) can be read without an SMTP server.
+// Never use the log in production: the links give access to the accounts.
+type FileMailer struct {
+	dir   string
+	from  string
+	count atomic.Int64
+}
+
+// NewFileMailer creates a mailer that writes to dir (or to the log when dir is empty)
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+// Send implements Mailer
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if m.dir == "" {
+		slog.InfoContext(ctx, "Email written to log", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%03d.eml", time.Now().Format("20060102-150405"), m.count.Add(1)%1000)
+	return os.WriteFile(filepath.Join(m.dir, name), buildMessage(m.from, msg), 0o600)
+}
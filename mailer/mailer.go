@@ -0,0 +1,59 @@
+// Package mailer sends the transactional emails of the API (password reset,
+// email verification, ...).
+package mailer
+
+import (
+	"context"
+	"fmt"
+)
+
+// Supported drivers
+const (
+	DriverSMTP = "smtp"
+	DriverFile = "file"
+	DriverLog  = "log"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config selects and configures the driver
+type Config struct {
+	Driver string // smtp, file or log (default)
+	From   string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	Dir string // file driver: directory where the .eml files are written
+}
+
+// New creates the mailer described by cfg
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case DriverSMTP:
+		if cfg.SMTPHost == "" || cfg.From == "" {
+			return nil, fmt.Errorf("SMTP host and sender address are required")
+		}
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	case DriverFile:
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("directory is required by the file mailer")
+		}
+		return NewFileMailer(cfg.Dir, cfg.From), nil
+	case "", DriverLog:
+		return NewFileMailer("", cfg.From), nil
+	}
+	return nil, fmt.Errorf("unknown mail driver: %q", cfg.Driver)
+}
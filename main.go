@@ -1,14 +1,27 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"chatserver/assistant"
 	"chatserver/controllers"
 	"chatserver/database"
 	_ "chatserver/docs" // Importa a documentação gerada pelo Swagger
+	"chatserver/keys"
+	"chatserver/logging"
+	"chatserver/mailer"
+	"chatserver/metrics"
 	"chatserver/middleware"
+	"chatserver/models"
+	"chatserver/oidc"
+	"chatserver/repository"
+	"chatserver/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -37,14 +50,23 @@ import (
 
 func main() {
 	// Carregar variáveis de ambiente
-	if err := godotenv.Load(); err != nil {
-		log.Println("⚠️  Arquivo .env não encontrado, usando variáveis de ambiente do sistema")
+	envErr := godotenv.Load()
+
+	// Logs estruturados (JSON por padrão); o pacote log também passa a usá-lo
+	logger, err := logging.New(os.Stdout, getEnv("LOG_FORMAT", logging.FormatJSON), getEnv("LOG_LEVEL", "info"))
+	if err != nil {
+		fatal("Configuração de log inválida", "error", err)
+	}
+	slog.SetDefault(logger)
+
+	if envErr != nil {
+		slog.Info("Arquivo .env não encontrado, usando variáveis de ambiente do sistema")
 	}
 
 	// Obter configurações
 	mongoURI := os.Getenv("MONGODB_URL")
 	if mongoURI == "" {
-		log.Fatal("❌ MONGODB_URL não configurado")
+		fatal("MONGODB_URL não configurado")
 	}
 
 	dbName := os.Getenv("MONGODB_DATABASE")
@@ -57,24 +79,86 @@ func main() {
 		port = "8080"
 	}
 
+	n8nWebhookURL := os.Getenv("N8N_WEBHOOK_URL")
+	if n8nWebhookURL == "" {
+		n8nWebhookURL = assistant.DefaultN8NWebhookURL
+	}
+
+	// Backend do assistente (n8n por padrão, "echo" para desenvolvimento local)
+	chatBackend, err := assistant.New(assistant.Config{
+		Kind:          os.Getenv("CHAT_BACKEND"),
+		N8NWebhookURL: n8nWebhookURL,
+		N8NOptions: assistant.N8NOptions{
+			Timeout:      getEnvDuration("N8N_TIMEOUT", 60*time.Second),
+			MaxRetries:   getEnvInt("N8N_MAX_RETRIES", 2),
+			RetryBackoff: getEnvDuration("N8N_RETRY_BACKOFF", 500*time.Millisecond),
+
+			TitleWebhookURL: os.Getenv("N8N_TITLE_WEBHOOK_URL"),
+		},
+		BreakerThreshold: getEnvInt("N8N_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:  getEnvDuration("N8N_BREAKER_COOLDOWN", 30*time.Second),
+	})
+	if err != nil {
+		fatal("Erro ao configurar backend de chat", "error", err)
+	}
+	slog.Info("Backend de chat configurado", "backend", chatBackend.Name())
+
+	// Tracing distribuído: spans das requisições, do MongoDB e do n8n (desabilitado por padrão)
+	tracingConfig := tracing.Config{
+		Exporter:    getEnv("OTEL_TRACES_EXPORTER", tracing.ExporterNone),
+		ServiceName: getEnv("OTEL_SERVICE_NAME", "chatserver"),
+		SampleRatio: getEnvFloat("OTEL_TRACES_SAMPLER_ARG", 1),
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingConfig)
+	if err != nil {
+		fatal("Erro ao configurar tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+	if tracingConfig.Exporter != tracing.ExporterNone {
+		slog.Info("Tracing habilitado", "exporter", tracingConfig.Exporter)
+	}
+
 	// Conectar ao MongoDB
 	if err := database.Connect(mongoURI, dbName); err != nil {
-		log.Fatalf("❌ Erro ao conectar ao MongoDB: %v", err)
+		fatal("Erro ao conectar ao MongoDB", "error", err)
 	}
 	defer database.Disconnect()
 
+	if err := database.EnsureIndexes(); err != nil {
+		slog.Warn("Erro ao criar índices do MongoDB", "error", err)
+	}
+
 	// Configurar Gin
 	if os.Getenv("ENV") == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.Default()
+	// Sem os middlewares de log em texto de gin.Default(): cada requisição gera
+	// uma entrada JSON com o request ID, o usuário e o trace
+	router := gin.New()
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.RequestLogger())
+	router.Use(middleware.Recovery())
+
+	// Proxies confiáveis para X-Forwarded-For (IP do cliente usado no limite de tentativas de login)
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		if err := router.SetTrustedProxies(strings.Split(strings.ReplaceAll(proxies, " ", ""), ",")); err != nil {
+			fatal("TRUSTED_PROXIES inválido", "error", err)
+		}
+	}
+
+	// Span de cada requisição, continuando o trace recebido no header traceparent
+	router.Use(middleware.TracingMiddleware(tracingConfig.ServiceName), middleware.TraceUserMiddleware())
+
+	// Métricas HTTP do Prometheus (contagem e latência por rota)
+	router.Use(middleware.PrometheusMiddleware())
 
 	// Middleware CORS
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, traceparent, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -95,51 +179,279 @@ func main() {
 	// Health check
 	router.GET("/health", healthCheck)
 
+	// Métricas do Prometheus: na porta da API ou, com METRICS_PORT, em uma porta
+	// separada (não exposta publicamente); METRICS_USERNAME exige basic auth
+	metricsHandler := metrics.Handler(os.Getenv("METRICS_USERNAME"), os.Getenv("METRICS_PASSWORD"))
+	if metricsPort := os.Getenv("METRICS_PORT"); metricsPort != "" && metricsPort != port {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metricsHandler)
+			slog.Info("Métricas disponíveis em porta separada", "url", "http://localhost:"+metricsPort+"/metrics")
+			if err := http.ListenAndServe(":"+metricsPort, mux); err != nil {
+				fatal("Erro ao iniciar servidor de métricas", "error", err)
+			}
+		}()
+	} else {
+		router.GET("/metrics", gin.WrapH(metricsHandler))
+	}
+
 	// Auth routes
-	authController := controllers.NewAuthController(database.Database)
+	// Chaves de assinatura dos JWTs
+	jwtKeys, ephemeralKey, err := keys.Load(keys.Config{
+		Dir:          os.Getenv("JWT_KEYS_DIR"),
+		SigningKeyID: os.Getenv("JWT_SIGNING_KEY_ID"),
+		Secret:       os.Getenv("JWT_SECRET"),
+	})
+	if err != nil {
+		fatal("Erro ao carregar chaves JWT", "error", err)
+	}
+	if ephemeralKey {
+		if os.Getenv("ENV") == "production" {
+			fatal("JWT_SECRET ou JWT_KEYS_DIR deve ser configurado em produção")
+		}
+		slog.Warn("JWT_SECRET não configurado: usando chave temporária (tokens inválidos após reiniciar)")
+	}
+	slog.Info("Chave de assinatura JWT carregada", "kid", jwtKeys.SigningKey().ID, "algorithm", jwtKeys.SigningKey().Algorithm)
+
+	// Envio de emails (redefinição de senha, verificação de email)
+	mailDriver := getEnv("MAIL_DRIVER", mailer.DriverLog)
+	if mailDriver == mailer.DriverLog {
+		// O driver log escreve os links de redefinição e verificação no log:
+		// quem lê os logs poderia assumir qualquer conta
+		if os.Getenv("ENV") == "production" {
+			fatal("MAIL_DRIVER deve ser smtp ou file em produção")
+		}
+		slog.Warn("MAIL_DRIVER não configurado: emails escritos no log, com os links de acesso")
+	}
+	mail, err := mailer.New(mailer.Config{
+		Driver:       mailDriver,
+		From:         getEnv("MAIL_FROM", "SR Robot <no-reply@localhost>"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		Dir:          os.Getenv("MAIL_DIR"),
+	})
+	if err != nil {
+		fatal("Erro ao configurar envio de emails", "error", err)
+	}
+
+	// Login com provedor de identidade (OpenID Connect), opcional
+	apiURL := getEnv("API_URL", "http://localhost:"+port)
+	var oidcProvider *oidc.Provider
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		oidcProvider, err = oidc.NewProvider(oidc.Config{
+			IssuerURL:    issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  getEnv("OIDC_REDIRECT_URL", apiURL+"/auth/oidc/callback"),
+			Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+		})
+		if err != nil {
+			fatal("Erro ao configurar OIDC", "error", err)
+		}
+		slog.Info("Login OIDC habilitado", "issuer", issuer)
+	}
+
+	// Os controllers acessam o banco somente pelos repositórios do MongoDB
+	repos := repository.NewMongo(database.Database)
+
+	// Conexões WebSocket abertas, encerradas quando a sessão ou a chave de API é revogada
+	connections := controllers.NewConnections()
+
+	authController := controllers.NewAuthController(repos, controllers.AuthOptions{
+		Keys:             jwtKeys,
+		AccessTokenTTL:   getEnvDuration("JWT_ACCESS_TTL", 15*time.Minute),
+		RefreshTokenTTL:  getEnvDuration("JWT_REFRESH_TTL", 30*24*time.Hour),
+		Mailer:           mail,
+		AppURL:           getEnv("APP_URL", "http://localhost:3000"),
+		APIURL:           apiURL,
+		PasswordResetTTL: getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+
+		PasswordResetInterval:  getEnvDuration("PASSWORD_RESET_INTERVAL", 5*time.Minute),
+		MaxPasswordResetsPerIP: getEnvInt("PASSWORD_RESET_MAX_PER_IP", 10),
+
+		EmailVerificationTTL: getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		VerificationResend:   getEnvDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", time.Minute),
+
+		MaxLoginFailures:      getEnvInt("LOGIN_MAX_FAILURES", 5),
+		MaxLoginFailuresPerIP: getEnvInt("LOGIN_MAX_FAILURES_PER_IP", 50),
+		LoginFailureWindow:    getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginLockout:          getEnvDuration("LOGIN_LOCKOUT", 15*time.Minute),
+		LoginDelay:            getEnvDuration("LOGIN_DELAY", time.Second),
+
+		MFAIssuer: getEnv("MFA_ISSUER", "SR Robot"),
+
+		OIDC:                     oidcProvider,
+		OIDCAllowUnverifiedEmail: getEnvBool("OIDC_ALLOW_UNVERIFIED_EMAIL", false),
+
+		Connections: connections,
+	})
 	auth := router.Group("/auth")
 	{
 		auth.POST("/register", authController.Register)
 		auth.POST("/login", authController.Login)
+		auth.POST("/refresh", authController.Refresh)
+		auth.POST("/logout", authController.Logout)
+		auth.POST("/forgot-password", authController.ForgotPassword)
+		auth.POST("/reset-password", authController.ResetPassword)
+		auth.GET("/verify-email", authController.VerifyEmail)
+		auth.POST("/resend-verification", middleware.AuthMiddleware(authController), authController.ResendVerification)
+
+		// Autenticação em dois fatores (TOTP)
+		auth.POST("/mfa/verify", authController.VerifyMFA)
+		mfa := auth.Group("/mfa", middleware.AuthMiddleware(authController), middleware.RequireSession())
+		mfa.POST("/enroll", authController.EnrollMFA)
+		mfa.POST("/confirm", authController.ConfirmMFA)
+		mfa.POST("/disable", authController.DisableMFA)
+
+		// Login com provedor de identidade (OIDC)
+		if oidcProvider != nil {
+			auth.GET("/oidc/login", authController.OIDCLogin)
+			auth.GET("/oidc/callback", authController.OIDCCallback)
+		}
+
+		// Chaves de API pessoais (gerenciadas apenas com login interativo)
+		apiKeys := auth.Group("/api-keys", middleware.AuthMiddleware(authController), middleware.RequireSession())
+		apiKeys.GET("", authController.ListAPIKeys)
+		apiKeys.POST("", authController.CreateAPIKey)
+		apiKeys.DELETE("/:id", authController.RevokeAPIKey)
 	}
 
+	// Chaves públicas para outros serviços validarem os tokens
+	router.GET("/.well-known/jwks.json", authController.JWKS)
+
+	// Chat routes
+	autoTitle := getEnv("CHAT_AUTO_TITLE", controllers.AutoTitleHeuristic)
+	if autoTitle == controllers.AutoTitleBackend && os.Getenv("N8N_TITLE_WEBHOOK_URL") == "" {
+		slog.Warn("CHAT_AUTO_TITLE=backend sem N8N_TITLE_WEBHOOK_URL: os títulos usarão a heurística local")
+	}
+	chatController := controllers.NewChatController(repos.Conversations, repos.Messages, chatBackend, controllers.ChatOptions{
+		AsyncWorkers:    getEnvInt("CHAT_ASYNC_WORKERS", 4),
+		AsyncQueueSize:  getEnvInt("CHAT_ASYNC_QUEUE_SIZE", 100),
+		AsyncJobTimeout: getEnvDuration("CHAT_ASYNC_JOB_TIMEOUT", 5*time.Minute),
+		CallbackSecret:  os.Getenv("CHAT_CALLBACK_SECRET"),
+		AutoTitle:       autoTitle,
+
+		CallbackAllowPrivateNetworks: getEnvBool("CHAT_CALLBACK_ALLOW_PRIVATE", false),
+
+		ActiveUsersWindow:   getEnvDuration("METRICS_ACTIVE_USERS_WINDOW", 15*time.Minute),
+		ActiveUsersInterval: getEnvDuration("METRICS_ACTIVE_USERS_INTERVAL", time.Minute),
+
+		Authenticator: authController,
+		Connections:   connections,
+	})
+
 	// Profile routes (protegidas com autenticação)
-	profileController := controllers.NewProfileController(database.Database)
+	profileController := controllers.NewProfileController(repos, controllers.ProfileOptions{
+		Conversations:       chatController,
+		DeletionGracePeriod: getEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", 7*24*time.Hour),
+		Connections:         connections,
+	})
 	profile := router.Group("/profile")
-	profile.Use(middleware.AuthMiddleware())
+	profile.Use(middleware.AuthMiddleware(authController), middleware.RequireSession())
 	{
 		profile.GET("", profileController.GetProfile)
 		profile.PUT("", profileController.UpdateProfile)
+		profile.DELETE("", profileController.DeleteAccount)
+		profile.PUT("/password", profileController.ChangePassword)
+	}
+
+	// Rotas administrativas: o papel é conferido aqui, para cada grupo. Chaves de API
+	// nunca são aceitas. ADMIN_EMAILS promove contas existentes a administrador.
+	adminController := controllers.NewAdminController(repos, controllers.AdminOptions{
+		Conversations: chatController,
+		Accounts:      profileController,
+		Connections:   connections,
+	})
+	if adminEmails := os.Getenv("ADMIN_EMAILS"); adminEmails != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := adminController.GrantAdminRole(ctx, strings.Split(strings.ReplaceAll(adminEmails, " ", ""), ",")); err != nil {
+			slog.Warn("Erro ao aplicar ADMIN_EMAILS", "error", err)
+		}
+		cancel()
 	}
+	admin := router.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(authController), middleware.RequireSession())
+	{
+		// Suporte e administradores: consultar contas e conversas, encerrar sessões
+		support := admin.Group("", middleware.RequireRole(models.UserRoleSupport, models.UserRoleAdmin))
+		support.GET("/users", adminController.ListUsers)
+		support.GET("/users/:id", adminController.GetUser)
+		support.GET("/users/:id/conversations", adminController.ListUserConversations)
+		support.GET("/conversations/:id", adminController.GetConversation)
+		support.POST("/users/:id/logout", adminController.ForceLogout)
+
+		// Somente administradores: alterar, desativar e excluir
+		admins := admin.Group("", middleware.RequireRole(models.UserRoleAdmin))
+		admins.PUT("/users/:id/role", adminController.UpdateUserRole)
+		admins.POST("/users/:id/disable", adminController.DisableUser)
+		admins.POST("/users/:id/enable", adminController.EnableUser)
+		admins.DELETE("/users/:id", adminController.DeleteUser)
+		admins.DELETE("/users/:id/conversations", adminController.DeleteUserConversations)
+		admins.DELETE("/conversations/:id", adminController.DeleteConversation)
+		admins.GET("/audit", adminController.ListAuditLog)
+	}
+
+	// Com REQUIRE_EMAIL_VERIFICATION=true, contas com email não verificado não usam o chat
+	wsAuth := []gin.HandlerFunc{middleware.WebSocketAuthMiddleware(authController)}
+	apiAuth := []gin.HandlerFunc{middleware.AuthMiddleware(authController)}
+	if getEnvBool("REQUIRE_EMAIL_VERIFICATION", false) {
+		wsAuth = append(wsAuth, middleware.RequireVerifiedEmail())
+		apiAuth = append(apiAuth, middleware.RequireVerifiedEmail())
+		slog.Info("Verificação de email obrigatória para o chat")
+	}
+
+	// Escopos exigidos das chaves de API (tokens de login têm todos)
+	canRead := middleware.RequireScope(models.ScopeChatRead)
+	canWrite := middleware.RequireScope(models.ScopeChatWrite)
+
+	// WebSocket de chat (token via query, subprotocolo ou header)
+	router.GET("/api/v1/ws", append(wsAuth, canWrite, chatController.WebSocket)...)
 
 	// Rotas da API (protegidas com autenticação)
 	api := router.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware()) // TODAS as rotas de chat precisam de autenticação
+	api.Use(apiAuth...) // TODAS as rotas de chat precisam de autenticação
 	{
-		// Chat routes
-		chatController := controllers.NewChatController()
-
 		// Enviar mensagem (criar ou continuar conversa)
-		api.POST("/chat", chatController.SendMessage)
+		api.POST("/chat", canWrite, chatController.SendMessage)
+
+		// Enviar mensagem com resposta em streaming (Server-Sent Events)
+		api.POST("/chat/stream", canWrite, chatController.StreamMessage)
+
+		// Buscar nas conversas e mensagens do usuário
+		searchController := controllers.NewSearchController(repos.Conversations, repos.Messages)
+		api.GET("/search", canRead, searchController.Search)
+
+		// Consultar mensagem assíncrona
+		api.GET("/jobs/:id", canRead, chatController.GetChatJob)
 
 		// Buscar histórico de uma conversa
-		api.GET("/conversations/:id", chatController.GetConversationHistory)
+		api.GET("/conversations/:id", canRead, chatController.GetConversationHistory)
 
 		// Listar todas as conversas
-		api.GET("/conversations", chatController.ListConversations)
+		api.GET("/conversations", canRead, chatController.ListConversations)
 
 		// Atualizar título da conversa
-		api.PUT("/conversations/:id", chatController.UpdateConversationTitle)
+		api.PUT("/conversations/:id", canWrite, chatController.UpdateConversationTitle)
 
 		// Deletar conversa
-		api.DELETE("/conversations/:id", chatController.DeleteConversation)
+		api.DELETE("/conversations/:id", canWrite, chatController.DeleteConversation)
+
+		// Editar mensagem do usuário (opcionalmente regenerando a resposta)
+		api.PUT("/conversations/:id/messages/:messageId", canWrite, chatController.EditMessage)
+
+		// Regenerar a última resposta do assistente
+		api.POST("/conversations/:id/regenerate", canWrite, chatController.RegenerateReply)
+
+		// Bifurcar conversa a partir de uma mensagem
+		api.POST("/conversations/:id/fork", canWrite, chatController.ForkConversation)
 	}
 
 	// Iniciar servidor
-	log.Printf("🚀 Servidor rodando na porta %s", port)
-	log.Printf("📖 Documentação Swagger disponível em: http://localhost:%s/swagger/index.html", port)
+	slog.Info("Servidor iniciado", "port", port, "swagger", "http://localhost:"+port+"/swagger/index.html")
 	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("❌ Erro ao iniciar servidor: %v", err)
+		fatal("Erro ao iniciar servidor", "error", err)
 	}
 }
 
@@ -157,3 +469,73 @@ func healthCheck(c *gin.Context) {
 		"service": "sr_robot_api",
 	})
 }
+
+// fatal registra o erro e encerra o processo (equivalente a log.Fatal)
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// getEnv lê uma variável do ambiente, usando o padrão se ausente
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvDuration lê uma duração (ex: "30s", "2m") do ambiente, usando o padrão se ausente ou inválida
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Valor inválido, usando o padrão", "key", key, "value", value, "default", defaultValue.String())
+		return defaultValue
+	}
+	return duration
+}
+
+// getEnvBool lê um booleano ("true", "1", ...) do ambiente, usando o padrão se ausente ou inválido
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Valor inválido, usando o padrão", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return enabled
+}
+
+// getEnvFloat lê um número decimal do ambiente, usando o padrão se ausente ou inválido
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		slog.Warn("Valor inválido, usando o padrão", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+// getEnvInt lê um inteiro do ambiente, usando o padrão se ausente ou inválido
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Valor inválido, usando o padrão", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return number
+}
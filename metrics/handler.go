@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler serves the metrics in the Prometheus text format. When username is
+// set, scrapers must send these HTTP basic auth credentials.
+func Handler(username, password string) http.Handler {
+	handler := promhttp.Handler()
+	if username == "" {
+		return handler
+	}
+
+	// Hashes have the same length, so the comparison time does not reveal the credentials' length
+	wantUser, wantPassword := sha256.Sum256([]byte(username)), sha256.Sum256([]byte(password))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		gotUser, gotPassword := sha256.Sum256([]byte(user)), sha256.Sum256([]byte(pass))
+		userOK := subtle.ConstantTimeCompare(gotUser[:], wantUser[:]) == 1
+		passwordOK := subtle.ConstantTimeCompare(gotPassword[:], wantPassword[:]) == 1
+		if !ok || !userOK || !passwordOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
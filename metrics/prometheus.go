@@ -33,10 +33,26 @@ var (
 		[]string{"type", "status"}, // type: login/register, status: success/failure
 	)
 
+	LoginLockoutsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_lockouts_total",
+			Help: "Total number of lockouts triggered by repeated failed logins",
+		},
+		[]string{"scope"}, // scope: email/ip/mfa
+	)
+
+	LoginBlockedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_blocked_total",
+			Help: "Total number of login attempts rejected by brute-force protection",
+		},
+		[]string{"reason"}, // reason: locked/delayed/ip_blocked/mfa_blocked
+	)
+
 	ActiveUsers = promauto.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "active_users_total",
-			Help: "Number of currently active users",
+			Help: "Number of users who sent a chat message in the active users window",
 		},
 	)
 
@@ -74,11 +90,38 @@ var (
 	)
 
 	// Chat Metrics
-	ChatMessagesTotal = promauto.NewCounter(
+	ChatMessagesTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "chat_messages_total",
-			Help: "Total number of chat messages processed",
+			Help: "Total number of chat messages stored",
+		},
+		[]string{"role"}, // role: user/assistant
+	)
+
+	ConversationsCreatedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_conversations_created_total",
+			Help: "Total number of conversations created",
+		},
+		[]string{"source"}, // source: chat/fork
+	)
+
+	// Assistant Backend Metrics
+	BackendRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "assistant_backend_requests_total",
+			Help: "Total number of calls to the assistant backend",
 		},
+		[]string{"backend", "operation", "status"}, // operation: send/stream/title, status: success/<http status>/circuit_open/timeout/canceled/error
+	)
+
+	BackendRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "assistant_backend_request_duration_seconds",
+			Help:    "Duration of calls to the assistant backend in seconds",
+			Buckets: []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+		},
+		[]string{"backend", "operation"},
 	)
 
 	// System Metrics
@@ -95,6 +138,14 @@ func RecordAuthAttempt(authType, status string) {
 	AuthAttemptsTotal.WithLabelValues(authType, status).Inc()
 }
 
+func RecordLoginLockout(scope string) {
+	LoginLockoutsTotal.WithLabelValues(scope).Inc()
+}
+
+func RecordLoginBlocked(reason string) {
+	LoginBlockedTotal.WithLabelValues(reason).Inc()
+}
+
 func RecordTokenIssued() {
 	TokensIssued.Inc()
 }
@@ -108,8 +159,17 @@ func RecordDatabaseOperation(operation, collection, status string, duration floa
 	DatabaseOperationDuration.WithLabelValues(operation, collection).Observe(duration)
 }
 
-func RecordChatMessage() {
-	ChatMessagesTotal.Inc()
+func RecordChatMessage(role string) {
+	ChatMessagesTotal.WithLabelValues(role).Inc()
+}
+
+func RecordConversationCreated(source string) {
+	ConversationsCreatedTotal.WithLabelValues(source).Inc()
+}
+
+func RecordBackendRequest(backend, operation, status string, duration float64) {
+	BackendRequestsTotal.WithLabelValues(backend, operation, status).Inc()
+	BackendRequestDuration.WithLabelValues(backend, operation).Observe(duration)
 }
 
 func IncrementActiveConnections() {
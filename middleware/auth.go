@@ -1,17 +1,26 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"chatserver/controllers"
+	"chatserver/logging"
 	"chatserver/metrics"
+	"chatserver/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware validates JWT token
-func AuthMiddleware() gin.HandlerFunc {
+// Authenticator validates an access token and returns its claims
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*models.Claims, error)
+}
+
+// AuthMiddleware validates the JWT token, or a personal API key
+func AuthMiddleware(auth Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -30,26 +39,151 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		token := parts[1]
+		authenticate(c, auth, parts[1])
+	}
+}
+
+// WebSocketAuthMiddleware validates the JWT of a WebSocket handshake. Browsers
+// cannot set the Authorization header on WebSocket connections, so the token is
+// also accepted in the "token" query parameter or as the second value of the
+// Sec-WebSocket-Protocol header ("bearer, <token>").
+func WebSocketAuthMiddleware(auth Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+
+		if token == "" {
+			protocols := strings.Split(c.GetHeader("Sec-WebSocket-Protocol"), ",")
+			if len(protocols) == 2 && strings.TrimSpace(protocols[0]) == controllers.WSBearerProtocol {
+				token = strings.TrimSpace(protocols[1])
+			}
+		}
 
-		// Validate token
-		claims, err := controllers.ValidateToken(token)
-		if err != nil {
-			// Determine the reason for failure
-			reason := "invalid"
-			if strings.Contains(err.Error(), "expired") {
-				reason = "expired"
+		if token == "" {
+			if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+				token = strings.TrimPrefix(authHeader, "Bearer ")
 			}
-			metrics.RecordTokenValidationFailure(reason)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		}
+
+		if token == "" {
+			metrics.RecordTokenValidationFailure("missing")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token required"})
+			c.Abort()
+			return
+		}
+
+		// The connection validates the token again for each event
+		c.Set("token", token)
+		authenticate(c, auth, token)
+	}
+}
+
+// authenticate validates the token and sets the user info in the context
+func authenticate(c *gin.Context, auth Authenticator, token string) {
+	claims, err := auth.Authenticate(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, controllers.ErrAccountDisabled) {
+			metrics.RecordTokenValidationFailure("disabled")
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+			c.Abort()
+			return
+		}
+
+		// Determine the reason for failure
+		reason := "invalid"
+		if errors.Is(err, controllers.ErrSessionRevoked) || errors.Is(err, controllers.ErrAPIKeyRevoked) {
+			reason = "revoked"
+		} else if strings.Contains(err.Error(), "expired") {
+			reason = "expired"
+		}
+		metrics.RecordTokenValidationFailure(reason)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return
+	}
+
+	// Set user info in context
+	c.Set("email", claims.Email)
+	c.Set("user_id", claims.UserID)
+	c.Set("session_id", claims.SessionID)
+	c.Set("email_verified", claims.EmailVerified)
+	c.Set("role", claims.Role)
+	if claims.ExpiresAt != nil {
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+	}
+	if claims.APIKeyID != "" {
+		c.Set("api_key_id", claims.APIKeyID)
+		c.Set("scopes", claims.Scopes)
+	}
+
+	// Log entries of the request identify the user
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", claims.UserID))
+
+	c.Next()
+}
+
+// RequireVerifiedEmail rejects accounts whose email is not verified yet. Must
+// run after AuthMiddleware; the flag comes from the access token, so a client
+// refreshes its tokens after the verification.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("email_verified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email not verified"})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Set user info in context
-		c.Set("email", claims.Email)
-		c.Set("user_id", claims.UserID)
+// RequireScope restricts a route to API keys granted scope. Requests
+// authenticated with a JWT (an interactive login) have every scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("api_key_id") == "" {
+			c.Next()
+			return
+		}
+		for _, granted := range c.GetStringSlice("scopes") {
+			if granted == scope {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key missing scope " + scope})
+		c.Abort()
+	}
+}
 
+// RequireRole restricts a route group to the given roles. Must run after
+// AuthMiddleware; tokens issued before roles existed count as UserRoleUser.
+func RequireRole(roles ...models.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		userRole, _ := role.(models.UserRole)
+		if userRole == "" {
+			userRole = models.UserRoleUser
+		}
+		for _, allowed := range roles {
+			if userRole == allowed {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
+
+// RequireSession rejects personal API keys, for the routes that manage the
+// account itself (profile, MFA, API keys): a leaked key must not be able to
+// take over the account or mint new keys.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("api_key_id") != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API keys are not accepted for this endpoint"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
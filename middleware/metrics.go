@@ -27,9 +27,9 @@ func PrometheusMiddleware() gin.HandlerFunc {
 		endpoint := c.FullPath()
 		method := c.Request.Method
 
-		// If endpoint is empty, use the raw path
+		// Unmatched paths share one label, so scanners cannot create a series per URL
 		if endpoint == "" {
-			endpoint = c.Request.URL.Path
+			endpoint = "unmatched"
 		}
 
 		metrics.HttpRequestsTotal.WithLabelValues(method, endpoint, status).Inc()
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware records a server span for each request, named after the
+// route template, continuing the trace of the caller when the request carries
+// a traceparent header. The span is available to the handlers through
+// c.Request.Context().
+func TracingMiddleware(serviceName string) gin.HandlerFunc {
+	// Prometheus scrapes would add a trace every few seconds
+	return otelgin.Middleware(serviceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/metrics"
+	}))
+}
+
+// TraceUserMiddleware adds the authenticated user to the span of the request.
+// It must run after TracingMiddleware; the user is known once the handlers ran.
+func TraceUserMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if userID := c.GetString("user_id"); userID != "" {
+			trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("enduser.id", userID))
+		}
+	}
+}
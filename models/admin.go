@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AdminUser é a visão de uma conta nas rotas administrativas
+type AdminUser struct {
+	ID                   string     `json:"id"`
+	Email                string     `json:"email"`
+	Name                 *string    `json:"name,omitempty"`
+	Role                 UserRole   `json:"role"`
+	EmailVerified        bool       `json:"email_verified"`
+	MFAEnabled           bool       `json:"mfa_enabled"`
+	OIDCLinked           bool       `json:"oidc_linked"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+	DisabledAt           *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason       string     `json:"disabled_reason,omitempty"`
+	DeletionScheduledFor *time.Time `json:"deletion_scheduled_for,omitempty"`
+}
+
+// NewAdminUser monta a visão administrativa de uma conta, sem segredos
+func NewAdminUser(user *User) AdminUser {
+	return AdminUser{
+		ID:                   user.ID,
+		Email:                user.Email,
+		Name:                 user.Name,
+		Role:                 user.EffectiveRole(),
+		EmailVerified:        user.EmailVerified,
+		MFAEnabled:           user.MFAEnabled,
+		OIDCLinked:           user.OIDCSubject != "",
+		CreatedAt:            user.CreatedAt,
+		UpdatedAt:            user.UpdatedAt,
+		DisabledAt:           user.DisabledAt,
+		DisabledReason:       user.DisabledReason,
+		DeletionScheduledFor: user.DeletionScheduledFor,
+	}
+}
+
+// AdminUserDetail inclui as sessões e chaves de API ativas da conta
+type AdminUserDetail struct {
+	AdminUser
+	ActiveSessions int64 `json:"active_sessions"`
+	ActiveAPIKeys  int64 `json:"active_api_keys"`
+}
+
+// DisableUserRequest é o corpo de POST /admin/users/{id}/disable
+type DisableUserRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// AuditEntry registra uma ação administrativa (collection admin_audit)
+type AuditEntry struct {
+	ID         primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	ActorID    string                 `json:"actor_id" bson:"actor_id"`
+	ActorEmail string                 `json:"actor_email" bson:"actor_email"`
+	Action     string                 `json:"action" bson:"action"`           // Ex.: disable_user, view_conversation
+	TargetType string                 `json:"target_type" bson:"target_type"` // user ou conversation
+	TargetID   string                 `json:"target_id,omitempty" bson:"target_id,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	IP         string                 `json:"ip" bson:"ip"`
+	CreatedAt  time.Time              `json:"created_at" bson:"created_at"`
+}
+
+// Ações registradas na trilha de auditoria
+const (
+	AuditSearchUsers             = "search_users"
+	AuditViewUser                = "view_user"
+	AuditListUserConversations   = "list_user_conversations"
+	AuditViewConversation        = "view_conversation"
+	AuditUpdateRole              = "update_role"
+	AuditDisableUser             = "disable_user"
+	AuditEnableUser              = "enable_user"
+	AuditForceLogout             = "force_logout"
+	AuditDeleteUser              = "delete_user"
+	AuditDeleteUserConversations = "delete_user_conversations"
+	AuditDeleteConversation      = "delete_conversation"
+)
+
+// Tipos de alvo das ações auditadas
+const (
+	AuditTargetUser         = "user"
+	AuditTargetConversation = "conversation"
+)
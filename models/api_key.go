@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyPrefix starts every personal API key, telling them apart from JWTs in the Authorization header
+const APIKeyPrefix = "srk_"
+
+// API key scopes
+const (
+	ScopeChatRead  = "chat:read"  // Read conversations, messages, search and jobs
+	ScopeChatWrite = "chat:write" // Send messages and edit or delete conversations
+)
+
+// APIScopes are the scopes a personal API key can be granted
+var APIScopes = []string{ScopeChatRead, ScopeChatWrite}
+
+// APIKey is a personal API key, used by machine clients instead of logging in.
+// Only the SHA-256 hash of the key is stored; the key itself is shown once.
+type APIKey struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID     string             `json:"-" bson:"user_id"`
+	Name       string             `json:"name" bson:"name"`
+	Prefix     string             `json:"prefix" bson:"prefix"` // First characters of the key, to recognize it
+	KeyHash    string             `json:"-" bson:"key_hash"`
+	Scopes     []string           `json:"scopes" bson:"scopes"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	LastUsedAt *time.Time         `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time         `json:"expires_at,omitempty" bson:"expires_at,omitempty"` // nil = never expires
+	RevokedAt  *time.Time         `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest is the body of POST /auth/api-keys
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"omitempty,dive,oneof=chat:read chat:write"` // Default: all scopes
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=3650"`         // Default: never expires
+}
+
+// CreateAPIKeyResponse returns the new key, which cannot be retrieved again
+type CreateAPIKeyResponse struct {
+	Key string `json:"key"`
+	APIKey
+}
+
+// APIKeyListResponse lists the active keys of the user
+type APIKeyListResponse struct {
+	APIKeys []APIKey `json:"api_keys"`
+}
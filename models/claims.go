@@ -7,5 +7,27 @@ import (
 type Claims struct {
 	Email  string `json:"email"`
 	UserID string `json:"user_id"`
+	// SessionID identifies the login session; the token is rejected once the session is revoked
+	SessionID string `json:"sid,omitempty"`
+	// EmailVerified reflects the account when the token was issued; a refresh picks up a later verification
+	EmailVerified bool `json:"email_verified,omitempty"`
+	// Role of the user when the token was issued; a refresh picks up a later change
+	Role UserRole `json:"role,omitempty"`
+	// APIKeyID and Scopes are set when the request authenticated with a personal
+	// API key instead of a JWT; they are never part of a token
+	APIKeyID string   `json:"-"`
+	Scopes   []string `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// MFAAudience is the audience of the MFA challenge tokens, which are never accepted as access tokens
+const MFAAudience = "mfa"
+
+// MFAChallengeClaims is the short-lived token returned by a login that still
+// needs the second factor, exchanged at /auth/mfa/verify for the token pair
+type MFAChallengeClaims struct {
+	UserID string `json:"user_id"`
+	// LoginMethod is the first factor, recorded in the session started after the second one
+	LoginMethod string `json:"login_method,omitempty"`
 	jwt.RegisteredClaims
 }
@@ -6,24 +6,52 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// DefaultConversationTitle é o título das conversas novas, até que um título seja gerado ou definido
+const DefaultConversationTitle = "Nova Conversa"
+
+// TitleSource define a origem do título de uma conversa
+type TitleSource string
+
+const (
+	TitleSourceDefault TitleSource = "default" // Título padrão (DefaultConversationTitle)
+	TitleSourceAuto    TitleSource = "auto"    // Gerado automaticamente após a primeira resposta
+	TitleSourceUser    TitleSource = "user"    // Definido pelo usuário; nunca é sobrescrito
+)
+
 // Conversation representa uma conversa entre o usuário e o chatbot
 type Conversation struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	UserID    string             `json:"userId,omitempty" bson:"userId,omitempty"` // Opcional: para usuários autenticados
-	Title     string             `json:"title,omitempty" bson:"title,omitempty"`   // Título da conversa (pode ser gerado automaticamente)
-	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID      string             `json:"userId,omitempty" bson:"userId,omitempty"`           // Opcional: para usuários autenticados
+	Title       string             `json:"title,omitempty" bson:"title,omitempty"`             // Título da conversa (pode ser gerado automaticamente)
+	TitleSource TitleSource        `json:"titleSource,omitempty" bson:"titleSource,omitempty"` // default, auto ou user
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+
+	// Bifurcação: a conversa compartilha o histórico da conversa de origem até a mensagem informada
+	ParentConversationID *primitive.ObjectID `json:"parentConversationId,omitempty" bson:"parentConversationId,omitempty"`
+	ForkedFromMessageID  *primitive.ObjectID `json:"forkedFromMessageId,omitempty" bson:"forkedFromMessageId,omitempty"`
+	DeletedAt            *time.Time          `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"` // Removida pelo usuário, mantida apenas como origem de bifurcações
 }
 
 // NewConversation cria uma nova conversa
 func NewConversation(userID string) *Conversation {
 	now := time.Now()
 	return &Conversation{
-		ID:        primitive.NewObjectID(),
-		UserID:    userID,
-		Title:     "Nova Conversa",
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:          primitive.NewObjectID(),
+		UserID:      userID,
+		Title:       DefaultConversationTitle,
+		TitleSource: TitleSourceDefault,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 }
 
+// NewForkedConversation cria uma conversa que continua a partir de uma mensagem da conversa de origem
+func NewForkedConversation(parent *Conversation, messageID primitive.ObjectID) *Conversation {
+	conversation := NewConversation(parent.UserID)
+	conversation.Title = parent.Title
+	conversation.TitleSource = parent.TitleSource
+	conversation.ParentConversationID = &parent.ID
+	conversation.ForkedFromMessageID = &messageID
+	return conversation
+}
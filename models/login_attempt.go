@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginAttempt tracks the recent failed logins of an email or a client IP.
+// Kept in MongoDB so every API replica enforces the same limits.
+type LoginAttempt struct {
+	ID            string     `json:"id" bson:"_id"` // "email:<address>" or "ip:<address>"
+	Failures      int        `json:"failures" bson:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at" bson:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+	ExpiresAt     time.Time  `json:"expires_at" bson:"expires_at"` // Forgotten after this (TTL index)
+}
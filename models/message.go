@@ -15,16 +15,77 @@ const (
 	RoleSystem    MessageRole = "system"
 )
 
+// MessageStatus define o estado de geração de uma resposta do assistente
+type MessageStatus string
+
+const (
+	StatusPending   MessageStatus = "pending"   // Aguardando o backend (modo assíncrono)
+	StatusCompleted MessageStatus = "completed" // Resposta gerada
+	StatusFailed    MessageStatus = "failed"    // Falha ao gerar a resposta
+)
+
 // Message representa uma mensagem dentro de uma conversa
 type Message struct {
-	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	ConversationID primitive.ObjectID `json:"conversationId" bson:"conversationId"`
-	Role           MessageRole        `json:"role" bson:"role"`                     // user, assistant, system
-	Content        string             `json:"content" bson:"content"`               // Conteúdo da mensagem
-	Tokens         int                `json:"tokens,omitempty" bson:"tokens,omitempty"` // Quantidade de tokens (opcional)
-	LatencyMs      int64              `json:"latencyMs,omitempty" bson:"latencyMs,omitempty"` // Latência da resposta em ms
-	Metadata       map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"` // Metadados adicionais
-	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	ID             primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	ConversationID primitive.ObjectID     `json:"conversationId" bson:"conversationId"`
+	Role           MessageRole            `json:"role" bson:"role"`                                     // user, assistant, system
+	Content        string                 `json:"content" bson:"content"`                               // Conteúdo da mensagem
+	Tokens         int                    `json:"tokens,omitempty" bson:"tokens,omitempty"`             // Quantidade de tokens (opcional)
+	LatencyMs      int64                  `json:"latencyMs,omitempty" bson:"latencyMs,omitempty"`       // Latência da resposta em ms
+	Metadata       map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"`         // Metadados adicionais
+	Status         MessageStatus          `json:"status,omitempty" bson:"status,omitempty"`             // Estado da resposta (vazio = completed)
+	Error          string                 `json:"error,omitempty" bson:"error,omitempty"`               // Motivo da falha (status failed)
+	Versions       []MessageVersion       `json:"versions,omitempty" bson:"versions,omitempty"`         // Versões alternativas (edições e regenerações)
+	VersionIndex   int                    `json:"versionIndex,omitempty" bson:"versionIndex,omitempty"` // Versão exibida em Content
+	CreatedAt      time.Time              `json:"createdAt" bson:"createdAt"`
+	CompletedAt    *time.Time             `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+	EditedAt       *time.Time             `json:"editedAt,omitempty" bson:"editedAt,omitempty"`
+
+	// Instância que processa a resposta pendente e prazo até o qual ela a
+	// mantém; respostas com o prazo vencido são encerradas por qualquer instância
+	LeaseOwner string     `json:"-" bson:"leaseOwner,omitempty"`
+	LeaseUntil *time.Time `json:"-" bson:"leaseUntil,omitempty"`
+}
+
+// MessageVersion guarda uma versão do conteúdo de uma mensagem
+type MessageVersion struct {
+	Content   string                 `json:"content" bson:"content"`
+	LatencyMs int64                  `json:"latencyMs,omitempty" bson:"latencyMs,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"createdAt" bson:"createdAt"`
+}
+
+// AddVersion torna o conteúdo informado a versão atual, mantendo as anteriores
+// em Versions. Na primeira chamada o conteúdo original vira a versão 0.
+func (m *Message) AddVersion(content string, metadata map[string]interface{}, latencyMs int64) {
+	if len(m.Versions) == 0 {
+		createdAt := m.CreatedAt
+		if m.EditedAt != nil {
+			createdAt = *m.EditedAt
+		}
+		m.Versions = append(m.Versions, MessageVersion{
+			Content:   m.Content,
+			LatencyMs: m.LatencyMs,
+			Metadata:  m.Metadata,
+			CreatedAt: createdAt,
+		})
+	}
+
+	m.Versions = append(m.Versions, MessageVersion{
+		Content:   content,
+		LatencyMs: latencyMs,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	})
+	m.VersionIndex = len(m.Versions) - 1
+	m.Content = content
+	m.LatencyMs = latencyMs
+	m.Metadata = metadata
+}
+
+// IsCompleted indica se a mensagem tem conteúdo final (mensagens antigas não têm status)
+func (m *Message) IsCompleted() bool {
+	return m.Status == "" || m.Status == StatusCompleted
 }
 
 // NewMessage cria uma nova mensagem
@@ -37,4 +98,3 @@ func NewMessage(conversationID primitive.ObjectID, role MessageRole, content str
 		CreatedAt:      time.Now(),
 	}
 }
-
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// OIDCState is a pending OpenID Connect login, created when the user is sent
+// to the identity provider and consumed by the callback
+type OIDCState struct {
+	ID           string    `json:"-" bson:"_id"` // SHA-256 of the state parameter
+	CodeVerifier string    `json:"-" bson:"code_verifier"`
+	Nonce        string    `json:"-" bson:"nonce"`
+	CreatedAt    time.Time `json:"-" bson:"created_at"`
+	ExpiresAt    time.Time `json:"-" bson:"expires_at"`
+}
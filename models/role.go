@@ -0,0 +1,18 @@
+package models
+
+// UserRole define o nível de acesso de uma conta às rotas administrativas
+type UserRole string
+
+const (
+	UserRoleUser    UserRole = "user"
+	UserRoleSupport UserRole = "support" // Consulta contas e conversas para atender usuários
+	UserRoleAdmin   UserRole = "admin"   // Gerencia contas e papéis
+)
+
+// UserRoles são os papéis válidos, do menos ao mais privilegiado
+var UserRoles = []UserRole{UserRoleUser, UserRoleSupport, UserRoleAdmin}
+
+// UpdateRoleRequest é o corpo de PUT /admin/users/{id}/role
+type UpdateRoleRequest struct {
+	Role UserRole `json:"role" binding:"required,oneof=user support admin"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a login session: the family of refresh tokens issued from a single
+// login. Every refresh rotates the token; presenting an already rotated token
+// revokes the whole session. Only SHA-256 hashes of the tokens are stored.
+type Session struct {
+	ID                  primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID              string             `json:"user_id" bson:"user_id"`
+	RefreshTokenHash    string             `json:"-" bson:"refresh_token_hash"`
+	PreviousTokenHashes []string           `json:"-" bson:"previous_token_hashes,omitempty"` // Rotated tokens, kept for reuse detection
+	UserAgent           string             `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	IP                  string             `json:"ip,omitempty" bson:"ip,omitempty"`
+	LoginMethod         string             `json:"login_method,omitempty" bson:"login_method,omitempty"` // password or oidc
+	CreatedAt           time.Time          `json:"created_at" bson:"created_at"`
+	LastUsedAt          time.Time          `json:"last_used_at" bson:"last_used_at"`
+	ExpiresAt           time.Time          `json:"expires_at" bson:"expires_at"`
+	RevokedAt           *time.Time         `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	RevokeReason        string             `json:"revoke_reason,omitempty" bson:"revoke_reason,omitempty"` // logout, reuse, ...
+}
+
+// How the session was started; refreshes keep the method and CreatedAt of the login
+const (
+	LoginMethodPassword = "password"
+	LoginMethodOIDC     = "oidc"
+)
+
+// Session revocation reasons
+const (
+	RevokeReasonLogout          = "logout"
+	RevokeReasonReuse           = "reuse"
+	RevokeReasonPasswordReset   = "password_reset"
+	RevokeReasonPasswordChange  = "password_change"
+	RevokeReasonAccountDeleted  = "account_deleted"
+	RevokeReasonRoleChange      = "role_change"
+	RevokeReasonAccountDisabled = "account_disabled"
+	RevokeReasonAdminLogout     = "admin_logout"
+	RevokeReasonAccountClaimed  = "account_claimed" // Unverified account taken over by the owner of the email
+)
+
+// RefreshRequest is the body of /auth/refresh and /auth/logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -14,6 +14,44 @@ type User struct {
 	Bio       *string   `json:"bio,omitempty" bson:"bio,omitempty"`
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+
+	// Role grants access to the administrative routes (empty = UserRoleUser)
+	Role UserRole `json:"role,omitempty" bson:"role,omitempty"`
+
+	// Email verification: the flag and, while pending, the SHA-256 of the token
+	// sent by email, its expiration and when it was last sent (resend throttling)
+	EmailVerified              bool       `json:"email_verified" bson:"email_verified"`
+	EmailVerifiedAt            *time.Time `json:"email_verified_at,omitempty" bson:"email_verified_at,omitempty"`
+	EmailVerificationTokenHash string     `json:"-" bson:"email_verification_token_hash,omitempty"`
+	EmailVerificationExpiresAt *time.Time `json:"-" bson:"email_verification_expires_at,omitempty"`
+	EmailVerificationSentAt    *time.Time `json:"-" bson:"email_verification_sent_at,omitempty"`
+
+	// Password reset: SHA-256 of the single-use token sent by email and its expiration
+	PasswordResetTokenHash string     `json:"-" bson:"password_reset_token_hash,omitempty"`
+	PasswordResetExpiresAt *time.Time `json:"-" bson:"password_reset_expires_at,omitempty"`
+
+	// Identity provider (OpenID Connect) account linked to this user. Users
+	// created by an OIDC login have no password until they reset it.
+	OIDCIssuer  string `json:"-" bson:"oidc_issuer,omitempty"`
+	OIDCSubject string `json:"-" bson:"oidc_subject,omitempty"`
+
+	// Two-factor authentication (TOTP): the confirmed secret, the secret of an
+	// enrollment not confirmed yet, the SHA-256 of the unused recovery codes and
+	// the time step of the last accepted code (a code is accepted only once)
+	MFAEnabled            bool     `json:"mfa_enabled" bson:"mfa_enabled"`
+	MFASecret             string   `json:"-" bson:"mfa_secret,omitempty"`
+	MFAPendingSecret      string   `json:"-" bson:"mfa_pending_secret,omitempty"`
+	MFARecoveryCodeHashes []string `json:"-" bson:"mfa_recovery_code_hashes,omitempty"`
+	MFALastUsedStep       int64    `json:"-" bson:"mfa_last_used_step,omitempty"`
+
+	// Account suspension by an administrator: the user can neither log in nor
+	// use existing tokens and API keys until the account is enabled again
+	DisabledAt     *time.Time `json:"-" bson:"disabled_at,omitempty"`
+	DisabledReason string     `json:"-" bson:"disabled_reason,omitempty"`
+
+	// Account deletion: the account is deactivated and purged (with its
+	// conversations) after this time; logging in before it cancels the deletion
+	DeletionScheduledFor *time.Time `json:"-" bson:"deletion_scheduled_for,omitempty"`
 }
 
 type LoginRequest struct {
@@ -26,17 +64,77 @@ type RegisterRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"` // Empty for accounts without a password (OIDC), after a recent OIDC login
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"` // Empty for accounts without a password (OIDC), after a recent OIDC login
+}
+
+type DeleteAccountResponse struct {
+	Message              string     `json:"message"`
+	DeletionScheduledFor *time.Time `json:"deletion_scheduled_for,omitempty"` // Absent when the account was deleted immediately
+}
+
+// MFAChallengeResponse is returned by login instead of the tokens when the account has MFA enabled
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`  // Exchanged with a code at /auth/mfa/verify
+	ExpiresIn   int64  `json:"expires_in"` // MFA token lifetime in seconds
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password"`                // Empty for accounts without a password (OIDC): the code is enough
+	Code     string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+type MFAEnrollResponse struct {
+	Secret     string `json:"secret"`      // Base32 secret, for manual entry in the authenticator app
+	OTPAuthURI string `json:"otpauth_uri"` // otpauth:// URI, usually shown as a QR code
+}
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"` // Single-use codes, shown only once
+}
+
 type AuthResponse struct {
-	Token     string    `json:"token"`
-	Email     string    `json:"email"`
-	UserID    string    `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
+	Token         string    `json:"token"`         // Short-lived access token
+	RefreshToken  string    `json:"refresh_token"` // Exchanged for a new token pair at /auth/refresh
+	ExpiresIn     int64     `json:"expires_in"`    // Access token lifetime in seconds
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          UserRole  `json:"role"`
+	UserID        string    `json:"user_id"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type ProfileResponse struct {
-	Email string  `json:"email"`
-	Name  *string `json:"name"`
-	Bio   *string `json:"bio"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Role          UserRole `json:"role"`
+	MFAEnabled    bool     `json:"mfa_enabled"`
+	Name          *string  `json:"name"`
+	Bio           *string  `json:"bio"`
 }
 
 type UpdateProfileRequest struct {
@@ -44,6 +142,14 @@ type UpdateProfileRequest struct {
 	Bio  *string `json:"bio"`
 }
 
+// EffectiveRole returns the role of the user, UserRoleUser when none is stored
+func (u *User) EffectiveRole() UserRole {
+	if u.Role == "" {
+		return UserRoleUser
+	}
+	return u.Role
+}
+
 // HashPassword hashes the user password
 func (u *User) HashPassword() error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyRepository acessa as chaves de API pessoais (collection api_keys).
+// Uma chave ativa não foi revogada e não expirou.
+type APIKeyRepository interface {
+	// Create insere a chave; o ID é gerado por quem chama
+	Create(ctx context.Context, key *models.APIKey) error
+
+	// FindByHash busca a chave pelo hash do seu valor, ativa ou não
+	FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+
+	// ListActive retorna as chaves ativas do usuário em now, das mais recentes
+	// para as mais antigas
+	ListActive(ctx context.Context, userID string, now time.Time) ([]models.APIKey, error)
+
+	// CountActive conta as chaves ativas do usuário em now
+	CountActive(ctx context.Context, userID string, now time.Time) (int64, error)
+
+	// TouchLastUsed registra o uso da chave em now
+	TouchLastUsed(ctx context.Context, id primitive.ObjectID, now time.Time) error
+
+	// Revoke revoga a chave do usuário, se ainda não revogada; retorna
+	// ErrNotFound caso contrário
+	Revoke(ctx context.Context, id primitive.ObjectID, userID string, now time.Time) error
+
+	// RevokeByUser revoga todas as chaves do usuário e retorna quantas
+	RevokeByUser(ctx context.Context, userID string, now time.Time) (int64, error)
+
+	// DeleteByUser remove todas as chaves do usuário
+	DeleteByUser(ctx context.Context, userID string) error
+}
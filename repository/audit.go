@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditFilter filtra a trilha de auditoria; campos vazios não filtram
+type AuditFilter struct {
+	ActorID  string
+	TargetID string
+	Action   string
+	Before   *primitive.ObjectID // Continua a listagem antes deste ID (cursor)
+}
+
+// AuditRepository acessa a trilha de auditoria das rotas administrativas
+// (collection admin_audit). Os registros nunca são alterados.
+type AuditRepository interface {
+	// Create insere o registro e preenche entry.ID
+	Create(ctx context.Context, entry *models.AuditEntry) error
+
+	// List retorna até limit registros, dos mais recentes para os mais antigos
+	List(ctx context.Context, filter AuditFilter, limit int64) ([]models.AuditEntry, error)
+}
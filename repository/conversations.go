@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConversationCursor aponta para a última conversa de uma página, ordenada
+// por updatedAt e _id (decrescentes)
+type ConversationCursor struct {
+	UpdatedAt time.Time
+	ID        primitive.ObjectID
+}
+
+// ConversationHit é uma conversa cujo título corresponde a uma busca textual
+type ConversationHit struct {
+	models.Conversation `bson:",inline"`
+	Score               float64 `bson:"score"` // Relevância (maior = mais relevante)
+}
+
+// ConversationRepository acessa as conversas (collection conversations)
+type ConversationRepository interface {
+	// Create insere a conversa; o ID é gerado por models.NewConversation
+	Create(ctx context.Context, conversation *models.Conversation) error
+
+	// FindByID busca a conversa de qualquer usuário, incluindo as removidas
+	// mantidas como origem de bifurcações
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Conversation, error)
+
+	// FindOwned busca a conversa somente se ela pertence ao usuário
+	FindOwned(ctx context.Context, id primitive.ObjectID, userID string) (*models.Conversation, error)
+
+	// FindByUser retorna todas as conversas do usuário, sem ordem definida
+	FindByUser(ctx context.Context, userID string) ([]models.Conversation, error)
+
+	// List retorna até limit conversas do usuário ordenadas por updatedAt e _id
+	// (mais recentes primeiro), continuando após o cursor quando informado
+	List(ctx context.Context, userID string, after *ConversationCursor, limit int64) ([]models.Conversation, error)
+
+	// CountByUser conta as conversas do usuário
+	CountByUser(ctx context.Context, userID string) (int64, error)
+
+	// FindForks retorna as conversas bifurcadas a partir da conversa informada
+	FindForks(ctx context.Context, parentID primitive.ObjectID) ([]models.Conversation, error)
+
+	// Touch atualiza o updatedAt da conversa
+	Touch(ctx context.Context, id primitive.ObjectID) error
+
+	// UpdateTitle define o título escolhido pelo usuário (nunca substituído pelo
+	// título automático) e retorna a conversa atualizada
+	UpdateTitle(ctx context.Context, id primitive.ObjectID, userID, title string) (*models.Conversation, error)
+
+	// HasDefaultTitle indica se a conversa ainda tem o título padrão
+	HasDefaultTitle(ctx context.Context, id primitive.ObjectID) (bool, error)
+
+	// SetAutoTitle grava o título gerado automaticamente, somente se a conversa
+	// ainda tem o título padrão
+	SetAutoTitle(ctx context.Context, id primitive.ObjectID, title string) error
+
+	// MarkDeleted remove o dono da conversa, que passa a existir apenas como
+	// origem de bifurcações
+	MarkDeleted(ctx context.Context, id primitive.ObjectID) error
+
+	// Delete remove a conversa (as mensagens são removidas pelo MessageRepository)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+
+	// CountActiveUsers conta os usuários distintos com conversas atualizadas desde since
+	CountActiveUsers(ctx context.Context, since time.Time) (int64, error)
+
+	// Search busca no título das conversas do usuário, da mais relevante para a
+	// menos relevante. Suporta frases entre aspas e exclusão de termos com "-".
+	Search(ctx context.Context, userID, query string, limit int64) ([]ConversationHit, error)
+}
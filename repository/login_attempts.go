@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+)
+
+// LoginAttemptRepository acessa os contadores de falhas de login (collection
+// login_attempts), identificados por "email:<endereço>" ou "ip:<endereço>".
+// Um contador é esquecido depois de expires_at (índice TTL).
+type LoginAttemptRepository interface {
+	// FindActive retorna os contadores dos IDs informados que não expiraram em now
+	FindActive(ctx context.Context, ids []string, now time.Time) ([]models.LoginAttempt, error)
+
+	// RecordFailure soma uma falha ao contador, recomeçando a contagem se ele
+	// expirou, e retorna o contador atualizado. O contador dura window a
+	// partir de now, ou até o fim do bloqueio, se for mais longo.
+	RecordFailure(ctx context.Context, id string, now time.Time, window time.Duration) (*models.LoginAttempt, error)
+
+	// Lock bloqueia o contador até lockedUntil, zerando as falhas, se ele ainda
+	// tem failures falhas (outra requisição não o bloqueou antes)
+	Lock(ctx context.Context, id string, failures int, lockedUntil, expiresAt time.Time) (bool, error)
+
+	// Delete remove o contador
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// NewMemory cria repositórios em memória, vazios, para testes da API sem
+// banco de dados. Os índices únicos dos usuários são respeitados, mas não há
+// índices de texto: a busca textual é aproximada (ver textScore).
+func NewMemory() Repositories {
+	return Repositories{
+		Users:         NewMemoryUserRepository(),
+		Sessions:      NewMemorySessionRepository(),
+		APIKeys:       NewMemoryAPIKeyRepository(),
+		LoginAttempts: NewMemoryLoginAttemptRepository(),
+		OIDCStates:    NewMemoryOIDCStateRepository(),
+		Conversations: NewMemoryConversationRepository(),
+		Messages:      NewMemoryMessageRepository(),
+		Audit:         NewMemoryAuditRepository(),
+	}
+}
+
+// memoryStore guarda documentos codificados em BSON, como o MongoDB: datas
+// com precisão de milissegundos, campos omitempty ausentes e nenhuma memória
+// compartilhada com quem grava ou lê os documentos
+type memoryStore[K comparable, T any] struct {
+	mu   sync.RWMutex
+	docs map[K]bson.Raw
+}
+
+func newMemoryStore[K comparable, T any]() *memoryStore[K, T] {
+	return &memoryStore[K, T]{docs: make(map[K]bson.Raw)}
+}
+
+func decodeDoc[T any](raw bson.Raw) (*T, error) {
+	var doc T
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// insert grava o documento com a chave informada
+func (s *memoryStore[K, T]) insert(key K, doc *T) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[key] = raw
+	return nil
+}
+
+// insertUnique grava o documento se duplicate não retorna true para nenhum
+// documento existente; caso contrário retorna ErrDuplicate
+func (s *memoryStore[K, T]) insertUnique(key K, doc *T, duplicate func(*T) bool) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.docs {
+		existingDoc, err := decodeDoc[T](existing)
+		if err != nil {
+			return err
+		}
+		if duplicate(existingDoc) {
+			return ErrDuplicate
+		}
+	}
+	s.docs[key] = raw
+	return nil
+}
+
+// get retorna uma cópia do documento ou ErrNotFound
+func (s *memoryStore[K, T]) get(key K) (*T, error) {
+	s.mu.RLock()
+	raw, ok := s.docs[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return decodeDoc[T](raw)
+}
+
+// find retorna cópias dos documentos para os quais match retorna true
+func (s *memoryStore[K, T]) find(match func(*T) bool) ([]T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := []T{}
+	for _, raw := range s.docs {
+		doc, err := decodeDoc[T](raw)
+		if err != nil {
+			return nil, err
+		}
+		if match(doc) {
+			docs = append(docs, *doc)
+		}
+	}
+	return docs, nil
+}
+
+// update aplica apply ao documento da chave quando ele existe e match retorna
+// true, retornando uma cópia atualizada. O documento é verificado e alterado
+// sob o mesmo lock, como os updates com filtro do MongoDB.
+func (s *memoryStore[K, T]) update(key K, match func(*T) bool, apply func(*T)) (*T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, ok := s.docs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.apply(key, raw, match, apply)
+}
+
+// updateWhere aplica apply a todos os documentos para os quais match retorna
+// true e retorna cópias atualizadas
+func (s *memoryStore[K, T]) updateWhere(match func(*T) bool, apply func(*T)) ([]T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := []T{}
+	for key, raw := range s.docs {
+		doc, err := s.apply(key, raw, match, apply)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		updated = append(updated, *doc)
+	}
+	return updated, nil
+}
+
+// apply decodifica, altera e grava um documento; chamado com o lock de escrita
+func (s *memoryStore[K, T]) apply(key K, raw bson.Raw, match func(*T) bool, apply func(*T)) (*T, error) {
+	doc, err := decodeDoc[T](raw)
+	if err != nil {
+		return nil, err
+	}
+	if match != nil && !match(doc) {
+		return nil, ErrNotFound
+	}
+
+	apply(doc)
+	if raw, err = bson.Marshal(doc); err != nil {
+		return nil, err
+	}
+	s.docs[key] = raw
+	return decodeDoc[T](raw)
+}
+
+// delete remove o documento da chave, se existir
+func (s *memoryStore[K, T]) delete(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.docs, key)
+}
+
+// deleteWhere remove os documentos para os quais match retorna true
+func (s *memoryStore[K, T]) deleteWhere(match func(*T) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, raw := range s.docs {
+		doc, err := decodeDoc[T](raw)
+		if err != nil {
+			return err
+		}
+		if match(doc) {
+			delete(s.docs, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryAPIKeyRepository implementa APIKeyRepository em memória
+type MemoryAPIKeyRepository struct {
+	store *memoryStore[primitive.ObjectID, models.APIKey]
+}
+
+// NewMemoryAPIKeyRepository cria um repositório vazio
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{store: newMemoryStore[primitive.ObjectID, models.APIKey]()}
+}
+
+// activeAPIKey replica o filtro de activeAPIKeysFilter
+func activeAPIKey(key *models.APIKey, userID string, now time.Time) bool {
+	return key.UserID == userID && key.RevokedAt == nil && (key.ExpiresAt == nil || key.ExpiresAt.After(now))
+}
+
+func (r *MemoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return r.store.insert(key.ID, key)
+}
+
+func (r *MemoryAPIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	keys, err := r.store.find(func(key *models.APIKey) bool { return key.KeyHash == keyHash })
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNotFound
+	}
+	return &keys[0], nil
+}
+
+func (r *MemoryAPIKeyRepository) ListActive(ctx context.Context, userID string, now time.Time) ([]models.APIKey, error) {
+	keys, err := r.store.find(func(key *models.APIKey) bool { return activeAPIKey(key, userID, now) })
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (r *MemoryAPIKeyRepository) CountActive(ctx context.Context, userID string, now time.Time) (int64, error) {
+	keys, err := r.store.find(func(key *models.APIKey) bool { return activeAPIKey(key, userID, now) })
+	return int64(len(keys)), err
+}
+
+func (r *MemoryAPIKeyRepository) TouchLastUsed(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	_, err := r.store.update(id, nil, func(key *models.APIKey) { key.LastUsedAt = &now })
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *MemoryAPIKeyRepository) Revoke(ctx context.Context, id primitive.ObjectID, userID string, now time.Time) error {
+	_, err := r.store.update(id, func(key *models.APIKey) bool {
+		return key.UserID == userID && key.RevokedAt == nil
+	}, func(key *models.APIKey) {
+		key.RevokedAt = &now
+	})
+	return err
+}
+
+func (r *MemoryAPIKeyRepository) RevokeByUser(ctx context.Context, userID string, now time.Time) (int64, error) {
+	revoked, err := r.store.updateWhere(func(key *models.APIKey) bool {
+		return key.UserID == userID && key.RevokedAt == nil
+	}, func(key *models.APIKey) {
+		key.RevokedAt = &now
+	})
+	return int64(len(revoked)), err
+}
+
+func (r *MemoryAPIKeyRepository) DeleteByUser(ctx context.Context, userID string) error {
+	return r.store.deleteWhere(func(key *models.APIKey) bool {
+		return key.UserID == userID
+	})
+}
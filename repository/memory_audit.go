@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryAuditRepository implementa AuditRepository em memória
+type MemoryAuditRepository struct {
+	store *memoryStore[primitive.ObjectID, models.AuditEntry]
+}
+
+// NewMemoryAuditRepository cria um repositório vazio
+func NewMemoryAuditRepository() *MemoryAuditRepository {
+	return &MemoryAuditRepository{store: newMemoryStore[primitive.ObjectID, models.AuditEntry]()}
+}
+
+func (r *MemoryAuditRepository) Create(ctx context.Context, entry *models.AuditEntry) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	return r.store.insert(entry.ID, entry)
+}
+
+func (r *MemoryAuditRepository) List(ctx context.Context, filter AuditFilter, limit int64) ([]models.AuditEntry, error) {
+	entries, err := r.store.find(func(entry *models.AuditEntry) bool {
+		switch {
+		case filter.ActorID != "" && entry.ActorID != filter.ActorID:
+			return false
+		case filter.TargetID != "" && entry.TargetID != filter.TargetID:
+			return false
+		case filter.Action != "" && entry.Action != filter.Action:
+			return false
+		case filter.Before != nil && compareIDs(entry.ID, *filter.Before) >= 0:
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return compareIDs(entries[i].ID, entries[j].ID) > 0 })
+	if limit > 0 && int64(len(entries)) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
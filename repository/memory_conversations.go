@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryConversationRepository implementa ConversationRepository em memória
+type MemoryConversationRepository struct {
+	store *memoryStore[primitive.ObjectID, models.Conversation]
+}
+
+// NewMemoryConversationRepository cria um repositório vazio
+func NewMemoryConversationRepository() *MemoryConversationRepository {
+	return &MemoryConversationRepository{store: newMemoryStore[primitive.ObjectID, models.Conversation]()}
+}
+
+// hasDefaultTitle replica o filtro de defaultTitleFilter
+func hasDefaultTitle(conversation *models.Conversation) bool {
+	return conversation.Title == models.DefaultConversationTitle &&
+		conversation.TitleSource != models.TitleSourceAuto &&
+		conversation.TitleSource != models.TitleSourceUser
+}
+
+func (r *MemoryConversationRepository) Create(ctx context.Context, conversation *models.Conversation) error {
+	return r.store.insert(conversation.ID, conversation)
+}
+
+func (r *MemoryConversationRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Conversation, error) {
+	return r.store.get(id)
+}
+
+func (r *MemoryConversationRepository) FindOwned(ctx context.Context, id primitive.ObjectID, userID string) (*models.Conversation, error) {
+	conversation, err := r.store.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return conversation, nil
+}
+
+func (r *MemoryConversationRepository) FindByUser(ctx context.Context, userID string) ([]models.Conversation, error) {
+	return r.store.find(func(conversation *models.Conversation) bool {
+		return conversation.UserID == userID
+	})
+}
+
+func (r *MemoryConversationRepository) List(ctx context.Context, userID string, after *ConversationCursor, limit int64) ([]models.Conversation, error) {
+	conversations, err := r.store.find(func(conversation *models.Conversation) bool {
+		if conversation.UserID != userID {
+			return false
+		}
+		if after == nil {
+			return true
+		}
+		return conversation.UpdatedAt.Before(after.UpdatedAt) ||
+			(conversation.UpdatedAt.Equal(after.UpdatedAt) && bytes.Compare(conversation.ID[:], after.ID[:]) < 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(conversations, func(i, j int) bool {
+		a, b := conversations[i], conversations[j]
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+		return bytes.Compare(a.ID[:], b.ID[:]) > 0
+	})
+	if limit > 0 && int64(len(conversations)) > limit {
+		conversations = conversations[:limit]
+	}
+	return conversations, nil
+}
+
+func (r *MemoryConversationRepository) CountByUser(ctx context.Context, userID string) (int64, error) {
+	conversations, err := r.FindByUser(ctx, userID)
+	return int64(len(conversations)), err
+}
+
+func (r *MemoryConversationRepository) FindForks(ctx context.Context, parentID primitive.ObjectID) ([]models.Conversation, error) {
+	return r.store.find(func(conversation *models.Conversation) bool {
+		return conversation.ParentConversationID != nil && *conversation.ParentConversationID == parentID
+	})
+}
+
+func (r *MemoryConversationRepository) Touch(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.store.update(id, nil, func(conversation *models.Conversation) {
+		conversation.UpdatedAt = time.Now()
+	})
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *MemoryConversationRepository) UpdateTitle(ctx context.Context, id primitive.ObjectID, userID, title string) (*models.Conversation, error) {
+	return r.store.update(id,
+		func(conversation *models.Conversation) bool { return conversation.UserID == userID },
+		func(conversation *models.Conversation) {
+			conversation.Title = title
+			conversation.TitleSource = models.TitleSourceUser
+			conversation.UpdatedAt = time.Now()
+		},
+	)
+}
+
+func (r *MemoryConversationRepository) HasDefaultTitle(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	conversation, err := r.store.get(id)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return hasDefaultTitle(conversation), nil
+}
+
+func (r *MemoryConversationRepository) SetAutoTitle(ctx context.Context, id primitive.ObjectID, title string) error {
+	_, err := r.store.update(id, hasDefaultTitle, func(conversation *models.Conversation) {
+		conversation.Title = title
+		conversation.TitleSource = models.TitleSourceAuto
+	})
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *MemoryConversationRepository) MarkDeleted(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.store.update(id, nil, func(conversation *models.Conversation) {
+		now := time.Now()
+		conversation.UserID = ""
+		conversation.DeletedAt = &now
+	})
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *MemoryConversationRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.store.delete(id)
+	return nil
+}
+
+func (r *MemoryConversationRepository) CountActiveUsers(ctx context.Context, since time.Time) (int64, error) {
+	conversations, err := r.store.find(func(conversation *models.Conversation) bool {
+		return conversation.UserID != "" && !conversation.UpdatedAt.Before(since)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	users := make(map[string]bool)
+	for _, conversation := range conversations {
+		users[conversation.UserID] = true
+	}
+	return int64(len(users)), nil
+}
+
+func (r *MemoryConversationRepository) Search(ctx context.Context, userID, query string, limit int64) ([]ConversationHit, error) {
+	q := parseTextQuery(query)
+	conversations, err := r.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []ConversationHit{}
+	for _, conversation := range conversations {
+		if score := textScore(conversation.Title, q); score > 0 {
+			hits = append(hits, ConversationHit{Conversation: conversation, Score: score})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if limit > 0 && int64(len(hits)) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"chatserver/models"
+)
+
+// MemoryLoginAttemptRepository implementa LoginAttemptRepository em memória
+type MemoryLoginAttemptRepository struct {
+	mu    sync.Mutex // Torna o upsert de RecordFailure atômico
+	store *memoryStore[string, models.LoginAttempt]
+}
+
+// NewMemoryLoginAttemptRepository cria um repositório vazio
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{store: newMemoryStore[string, models.LoginAttempt]()}
+}
+
+func (r *MemoryLoginAttemptRepository) FindActive(ctx context.Context, ids []string, now time.Time) ([]models.LoginAttempt, error) {
+	return r.store.find(func(attempt *models.LoginAttempt) bool {
+		return slices.Contains(ids, attempt.ID) && attempt.ExpiresAt.After(now)
+	})
+}
+
+func (r *MemoryLoginAttemptRepository) RecordFailure(ctx context.Context, id string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, err := r.store.get(id)
+	if err == ErrNotFound {
+		attempt = &models.LoginAttempt{ID: id}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if attempt.ExpiresAt.After(now) {
+		attempt.Failures++
+	} else {
+		attempt.Failures = 1
+		attempt.LockedUntil = nil
+	}
+	attempt.LastFailureAt = now
+	attempt.ExpiresAt = now.Add(window)
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(attempt.ExpiresAt) {
+		attempt.ExpiresAt = *attempt.LockedUntil
+	}
+
+	if err := r.store.insert(id, attempt); err != nil {
+		return nil, err
+	}
+	return r.store.get(id)
+}
+
+func (r *MemoryLoginAttemptRepository) Lock(ctx context.Context, id string, failures int, lockedUntil, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.store.update(id, func(attempt *models.LoginAttempt) bool {
+		return attempt.Failures == failures
+	}, func(attempt *models.LoginAttempt) {
+		attempt.Failures = 0
+		attempt.LockedUntil = &lockedUntil
+		attempt.ExpiresAt = expiresAt
+	})
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *MemoryLoginAttemptRepository) Delete(ctx context.Context, id string) error {
+	r.store.delete(id)
+	return nil
+}
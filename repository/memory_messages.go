@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryMessageRepository implementa MessageRepository em memória
+type MemoryMessageRepository struct {
+	store *memoryStore[primitive.ObjectID, models.Message]
+}
+
+// NewMemoryMessageRepository cria um repositório vazio
+func NewMemoryMessageRepository() *MemoryMessageRepository {
+	return &MemoryMessageRepository{store: newMemoryStore[primitive.ObjectID, models.Message]()}
+}
+
+// compareIDs compara ObjectIDs, que seguem a ordem de criação
+func compareIDs(a, b primitive.ObjectID) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+// inSegments replica o filtro de segmentsFilter
+func inSegments(message *models.Message, segments []Segment) bool {
+	for _, segment := range segments {
+		if message.ConversationID != segment.ConversationID {
+			continue
+		}
+		if segment.UpTo == nil || compareIDs(message.ID, *segment.UpTo) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// findSorted retorna as mensagens selecionadas por match em ordem cronológica
+func (r *MemoryMessageRepository) findSorted(match func(*models.Message) bool) ([]models.Message, error) {
+	messages, err := r.store.find(match)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(messages, func(i, j int) bool { return compareIDs(messages[i].ID, messages[j].ID) < 0 })
+	return messages, nil
+}
+
+func (r *MemoryMessageRepository) Create(ctx context.Context, message *models.Message) error {
+	return r.store.insert(message.ID, message)
+}
+
+func (r *MemoryMessageRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Message, error) {
+	return r.store.get(id)
+}
+
+func (r *MemoryMessageRepository) FindLast(ctx context.Context, conversationID primitive.ObjectID, role models.MessageRole) (*models.Message, error) {
+	messages, err := r.findSorted(func(message *models.Message) bool {
+		return message.ConversationID == conversationID && message.Role == role
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrNotFound
+	}
+	return &messages[len(messages)-1], nil
+}
+
+func (r *MemoryMessageRepository) FindNext(ctx context.Context, conversationID, messageID primitive.ObjectID) (*models.Message, error) {
+	messages, err := r.findSorted(func(message *models.Message) bool {
+		return message.ConversationID == conversationID && compareIDs(message.ID, messageID) > 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrNotFound
+	}
+	return &messages[0], nil
+}
+
+func (r *MemoryMessageRepository) Update(ctx context.Context, message *models.Message) error {
+	replacement := *message
+	_, err := r.store.update(message.ID, nil, func(stored *models.Message) {
+		*stored = replacement
+	})
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *MemoryMessageRepository) AddVersion(ctx context.Context, message *models.Message) (bool, error) {
+	previousIndex, added := addedVersion(message)
+	_, err := r.store.update(message.ID,
+		func(stored *models.Message) bool { return stored.VersionIndex == previousIndex },
+		func(stored *models.Message) {
+			stored.Content = message.Content
+			stored.LatencyMs = message.LatencyMs
+			stored.Metadata = message.Metadata
+			stored.VersionIndex = message.VersionIndex
+			stored.Status = message.Status
+			stored.Error = message.Error
+			if message.EditedAt != nil {
+				stored.EditedAt = message.EditedAt
+			}
+			if message.CompletedAt != nil {
+				stored.CompletedAt = message.CompletedAt
+			}
+			stored.Versions = append(stored.Versions, added...)
+		},
+	)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *MemoryMessageRepository) List(ctx context.Context, segments []Segment, page MessagePage) ([]models.Message, error) {
+	messages, err := r.findSorted(func(message *models.Message) bool {
+		switch {
+		case page.After != nil && compareIDs(message.ID, *page.After) <= 0:
+			return false
+		case page.After == nil && page.Before != nil && compareIDs(message.ID, *page.Before) >= 0:
+			return false
+		}
+		return inSegments(message, segments)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if page.Limit > 0 && int64(len(messages)) > page.Limit {
+		// As mensagens mais próximas do cursor: as primeiras depois de "after"
+		// ou as últimas antes de "before" (sem cursor, as mais recentes)
+		if page.After != nil {
+			messages = messages[:page.Limit]
+		} else {
+			messages = messages[int64(len(messages))-page.Limit:]
+		}
+	}
+	return messages, nil
+}
+
+func (r *MemoryMessageRepository) Count(ctx context.Context, segments []Segment) (int64, error) {
+	messages, err := r.store.find(func(message *models.Message) bool {
+		return inSegments(message, segments)
+	})
+	return int64(len(messages)), err
+}
+
+func (r *MemoryMessageRepository) Contains(ctx context.Context, segments []Segment, messageID primitive.ObjectID) (bool, error) {
+	message, err := r.store.get(messageID)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return inSegments(message, segments), nil
+}
+
+func (r *MemoryMessageRepository) FailPending(ctx context.Context, now time.Time, reason string) (int64, error) {
+	failed, err := r.store.updateWhere(func(message *models.Message) bool {
+		return message.Status == models.StatusPending && (message.LeaseUntil == nil || !message.LeaseUntil.After(now))
+	}, func(message *models.Message) {
+		message.Status = models.StatusFailed
+		message.Error = reason
+		message.CompletedAt = &now
+		message.LeaseOwner = ""
+		message.LeaseUntil = nil
+	})
+	return int64(len(failed)), err
+}
+
+func (r *MemoryMessageRepository) RenewLeases(ctx context.Context, owner string, until time.Time) (int64, error) {
+	renewed, err := r.store.updateWhere(func(message *models.Message) bool {
+		return message.Status == models.StatusPending && message.LeaseOwner == owner
+	}, func(message *models.Message) {
+		message.LeaseUntil = &until
+	})
+	return int64(len(renewed)), err
+}
+
+func (r *MemoryMessageRepository) DeleteByConversation(ctx context.Context, conversationID primitive.ObjectID) error {
+	return r.store.deleteWhere(func(message *models.Message) bool {
+		return message.ConversationID == conversationID
+	})
+}
+
+func (r *MemoryMessageRepository) DeleteAfter(ctx context.Context, conversationID, messageID primitive.ObjectID) error {
+	return r.store.deleteWhere(func(message *models.Message) bool {
+		return message.ConversationID == conversationID && compareIDs(message.ID, messageID) > 0
+	})
+}
+
+func (r *MemoryMessageRepository) Search(ctx context.Context, conversationIDs []primitive.ObjectID, query string, limit int64) ([]MessageHit, error) {
+	q := parseTextQuery(query)
+	conversations := make(map[primitive.ObjectID]bool, len(conversationIDs))
+	for _, id := range conversationIDs {
+		conversations[id] = true
+	}
+
+	messages, err := r.findSorted(func(message *models.Message) bool {
+		return conversations[message.ConversationID]
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []MessageHit{}
+	for _, message := range messages {
+		if score := textScore(message.Content, q); score > 0 {
+			hits = append(hits, MessageHit{Message: message, Score: score})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if limit > 0 && int64(len(hits)) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
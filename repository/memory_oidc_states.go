@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"chatserver/models"
+)
+
+// MemoryOIDCStateRepository implementa OIDCStateRepository em memória
+type MemoryOIDCStateRepository struct {
+	mu    sync.Mutex // Torna a leitura e a remoção de Consume atômicas
+	store *memoryStore[string, models.OIDCState]
+}
+
+// NewMemoryOIDCStateRepository cria um repositório vazio
+func NewMemoryOIDCStateRepository() *MemoryOIDCStateRepository {
+	return &MemoryOIDCStateRepository{store: newMemoryStore[string, models.OIDCState]()}
+}
+
+func (r *MemoryOIDCStateRepository) Create(ctx context.Context, state *models.OIDCState) error {
+	return r.store.insert(state.ID, state)
+}
+
+func (r *MemoryOIDCStateRepository) Consume(ctx context.Context, id string, now time.Time) (*models.OIDCState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, err := r.store.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !state.ExpiresAt.After(now) {
+		return nil, ErrNotFound
+	}
+	r.store.delete(id)
+	return state, nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemorySessionRepository implementa SessionRepository em memória
+type MemorySessionRepository struct {
+	store *memoryStore[primitive.ObjectID, models.Session]
+}
+
+// NewMemorySessionRepository cria um repositório vazio
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{store: newMemoryStore[primitive.ObjectID, models.Session]()}
+}
+
+// matchSession replica o filtro de MongoSessionRepository.Revoke
+func matchSession(session *models.Session, filter SessionFilter) bool {
+	switch {
+	case session.RevokedAt != nil:
+		return false
+	case filter.ID != nil && session.ID != *filter.ID:
+		return false
+	case filter.ExceptID != nil && session.ID == *filter.ExceptID:
+		return false
+	case filter.UserID != "" && session.UserID != filter.UserID:
+		return false
+	case filter.TokenHash != "" && session.RefreshTokenHash != filter.TokenHash &&
+		!slices.Contains(session.PreviousTokenHashes, filter.TokenHash):
+		return false
+	case filter.PreviousTokenHash != "" && !slices.Contains(session.PreviousTokenHashes, filter.PreviousTokenHash):
+		return false
+	}
+	return true
+}
+
+func (r *MemorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	return r.store.insert(session.ID, session)
+}
+
+func (r *MemorySessionRepository) FindByID(ctx context.Context, id primitive.ObjectID, userID string) (*models.Session, error) {
+	session, err := r.store.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return session, nil
+}
+
+func (r *MemorySessionRepository) Rotate(ctx context.Context, tokenHash, newTokenHash string, now, expiresAt time.Time) (*models.Session, error) {
+	first := false
+	updated, err := r.store.updateWhere(func(session *models.Session) bool {
+		if first || session.RefreshTokenHash != tokenHash || session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+			return false
+		}
+		first = true
+		return true
+	}, func(session *models.Session) {
+		session.RefreshTokenHash = newTokenHash
+		session.LastUsedAt = now
+		session.ExpiresAt = expiresAt
+		session.PreviousTokenHashes = append(session.PreviousTokenHashes, tokenHash)
+		if extra := len(session.PreviousTokenHashes) - maxPreviousTokenHashes; extra > 0 {
+			session.PreviousTokenHashes = session.PreviousTokenHashes[extra:]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(updated) == 0 {
+		return nil, ErrNotFound
+	}
+	return &updated[0], nil
+}
+
+func (r *MemorySessionRepository) Revoke(ctx context.Context, filter SessionFilter, reason string, now time.Time) (int64, error) {
+	if filter == (SessionFilter{}) {
+		return 0, nil
+	}
+
+	revoked, err := r.store.updateWhere(func(session *models.Session) bool {
+		return matchSession(session, filter)
+	}, func(session *models.Session) {
+		session.RevokedAt = &now
+		session.RevokeReason = reason
+	})
+	return int64(len(revoked)), err
+}
+
+func (r *MemorySessionRepository) CountActive(ctx context.Context, userID string, now time.Time) (int64, error) {
+	sessions, err := r.store.find(func(session *models.Session) bool {
+		return session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now)
+	})
+	return int64(len(sessions)), err
+}
+
+func (r *MemorySessionRepository) DeleteByUser(ctx context.Context, userID string) error {
+	return r.store.deleteWhere(func(session *models.Session) bool {
+		return session.UserID == userID
+	})
+}
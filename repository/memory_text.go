@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"strings"
+)
+
+// textQuery é uma busca textual interpretada como no $text do MongoDB:
+// palavras soltas (basta uma), frases entre aspas (todas obrigatórias) e
+// termos excluídos com "-"
+type textQuery struct {
+	words    []string
+	phrases  []string
+	excluded []string
+}
+
+func parseTextQuery(query string) textQuery {
+	var q textQuery
+	for i, part := range strings.Split(strings.ToLower(query), `"`) {
+		// Partes ímpares estavam entre aspas
+		if i%2 == 1 {
+			if part = strings.TrimSpace(part); part != "" {
+				q.phrases = append(q.phrases, part)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			if excluded, ok := strings.CutPrefix(word, "-"); ok {
+				if excluded != "" {
+					q.excluded = append(q.excluded, excluded)
+				}
+				continue
+			}
+			q.words = append(q.words, word)
+		}
+	}
+	return q
+}
+
+// textScore retorna a relevância do texto para a busca, ou 0 se não
+// corresponde. Diferente do índice de texto do MongoDB, compara trechos sem
+// diferenciar maiúsculas, sem stemming nem remoção de acentos.
+func textScore(text string, q textQuery) float64 {
+	text = strings.ToLower(text)
+	for _, term := range q.excluded {
+		if strings.Contains(text, term) {
+			return 0
+		}
+	}
+
+	var score float64
+	for _, phrase := range q.phrases {
+		count := strings.Count(text, phrase)
+		if count == 0 {
+			return 0
+		}
+		score += float64(count)
+	}
+	matchedWord := len(q.words) == 0
+	for _, word := range q.words {
+		if count := strings.Count(text, word); count > 0 {
+			matchedWord = true
+			score += float64(count)
+		}
+	}
+	if !matchedWord {
+		return 0
+	}
+	return score
+}
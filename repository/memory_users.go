@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryUserRepository implementa UserRepository em memória
+type MemoryUserRepository struct {
+	store *memoryStore[string, models.User]
+}
+
+// NewMemoryUserRepository cria um repositório vazio
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{store: newMemoryStore[string, models.User]()}
+}
+
+// updateFirst altera a conta selecionada por match (como um FindOneAndUpdate
+// cujo filtro não usa o ID)
+func (r *MemoryUserRepository) updateFirst(match func(*models.User) bool, apply func(*models.User)) (*models.User, error) {
+	first := false
+	updated, err := r.store.updateWhere(func(user *models.User) bool {
+		if first || !match(user) {
+			return false
+		}
+		first = true
+		return true
+	}, apply)
+	if err != nil {
+		return nil, err
+	}
+	if len(updated) == 0 {
+		return nil, ErrNotFound
+	}
+	return &updated[0], nil
+}
+
+// updateByID altera a conta se ela atende a match e indica se atendia
+func (r *MemoryUserRepository) updateByID(id string, match func(*models.User) bool, apply func(*models.User)) (bool, error) {
+	_, err := r.store.update(id, match, apply)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	if user.ID == "" {
+		user.ID = primitive.NewObjectID().Hex()
+	}
+	// Mesmos índices únicos do MongoDB: email e conta do provedor de identidade
+	return r.store.insertUnique(user.ID, user, func(existing *models.User) bool {
+		return existing.Email == user.Email ||
+			(user.OIDCSubject != "" && existing.OIDCIssuer == user.OIDCIssuer && existing.OIDCSubject == user.OIDCSubject)
+	})
+}
+
+func (r *MemoryUserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
+	return r.store.get(id)
+}
+
+// findFirst busca a conta selecionada por match
+func (r *MemoryUserRepository) findFirst(match func(*models.User) bool) (*models.User, error) {
+	users, err := r.store.find(match)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, ErrNotFound
+	}
+	return &users[0], nil
+}
+
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findFirst(func(user *models.User) bool { return user.Email == email })
+}
+
+func (r *MemoryUserRepository) FindByOIDC(ctx context.Context, issuer, subject string) (*models.User, error) {
+	return r.findFirst(func(user *models.User) bool {
+		return user.OIDCIssuer == issuer && user.OIDCSubject == subject
+	})
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context, filter UserFilter, limit int64) ([]models.User, error) {
+	query := strings.ToLower(filter.Query)
+	users, err := r.store.find(func(user *models.User) bool {
+		if query != "" && !strings.Contains(strings.ToLower(user.Email), query) &&
+			(user.Name == nil || !strings.Contains(strings.ToLower(*user.Name), query)) {
+			return false
+		}
+		if filter.Role != "" && user.EffectiveRole() != filter.Role {
+			return false
+		}
+		if filter.Disabled && user.DisabledAt == nil {
+			return false
+		}
+		// IDs hexadecimais de mesmo tamanho seguem a ordem dos ObjectIDs
+		return filter.Before == "" || user.ID < filter.Before
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID > users[j].ID })
+	if limit > 0 && int64(len(users)) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *MemoryUserRepository) FindDeletionDue(ctx context.Context, now time.Time, limit int64) ([]models.User, error) {
+	users, err := r.store.find(func(user *models.User) bool {
+		return user.DeletionScheduledFor != nil && !user.DeletionScheduledFor.After(now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(users)) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *MemoryUserRepository) UpdateProfile(ctx context.Context, id string, name, bio *string) (*models.User, error) {
+	return r.store.update(id, nil, func(user *models.User) {
+		if name != nil {
+			user.Name = name
+		}
+		if bio != nil {
+			user.Bio = bio
+		}
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (r *MemoryUserRepository) ChangePassword(ctx context.Context, id, currentHash, newHash string) (bool, error) {
+	return r.updateByID(id,
+		func(user *models.User) bool { return user.Password == currentHash },
+		func(user *models.User) {
+			user.Password = newHash
+			user.UpdatedAt = time.Now()
+			user.PasswordResetTokenHash = ""
+			user.PasswordResetExpiresAt = nil
+		},
+	)
+}
+
+func (r *MemoryUserRepository) SetPasswordResetToken(ctx context.Context, email, tokenHash string, expiresAt time.Time) (*models.User, error) {
+	return r.updateFirst(
+		func(user *models.User) bool { return user.Email == email },
+		func(user *models.User) {
+			user.PasswordResetTokenHash = tokenHash
+			user.PasswordResetExpiresAt = &expiresAt
+		},
+	)
+}
+
+func (r *MemoryUserRepository) ResetPassword(ctx context.Context, tokenHash, passwordHash string, now time.Time) (*models.User, error) {
+	return r.updateFirst(
+		func(user *models.User) bool {
+			return user.PasswordResetTokenHash == tokenHash &&
+				user.PasswordResetExpiresAt != nil && user.PasswordResetExpiresAt.After(now)
+		},
+		func(user *models.User) {
+			user.Password = passwordHash
+			user.UpdatedAt = now
+			user.PasswordResetTokenHash = ""
+			user.PasswordResetExpiresAt = nil
+		},
+	)
+}
+
+func (r *MemoryUserRepository) VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error {
+	_, err := r.updateFirst(
+		func(user *models.User) bool {
+			return user.EmailVerificationTokenHash == tokenHash &&
+				user.EmailVerificationExpiresAt != nil && user.EmailVerificationExpiresAt.After(now)
+		},
+		func(user *models.User) {
+			user.EmailVerified = true
+			user.EmailVerifiedAt = &now
+			user.UpdatedAt = now
+			user.EmailVerificationTokenHash = ""
+			user.EmailVerificationExpiresAt = nil
+			user.EmailVerificationSentAt = nil
+		},
+	)
+	return err
+}
+
+func (r *MemoryUserRepository) RenewVerificationToken(ctx context.Context, id, tokenHash string, now, expiresAt time.Time, resendInterval time.Duration) (*models.User, error) {
+	return r.store.update(id,
+		func(user *models.User) bool {
+			return !user.EmailVerified &&
+				(user.EmailVerificationSentAt == nil || !user.EmailVerificationSentAt.After(now.Add(-resendInterval)))
+		},
+		func(user *models.User) {
+			user.EmailVerificationTokenHash = tokenHash
+			user.EmailVerificationExpiresAt = &expiresAt
+			user.EmailVerificationSentAt = &now
+		},
+	)
+}
+
+func (r *MemoryUserRepository) LinkOIDC(ctx context.Context, email, issuer, subject string) (*models.User, error) {
+	return r.updateFirst(
+		func(user *models.User) bool {
+			return user.Email == email && user.EmailVerified && user.OIDCSubject == ""
+		},
+		func(user *models.User) {
+			user.OIDCIssuer = issuer
+			user.OIDCSubject = subject
+			user.UpdatedAt = time.Now()
+		},
+	)
+}
+
+func (r *MemoryUserRepository) ClaimUnverifiedOIDC(ctx context.Context, email, issuer, subject string, now time.Time) (*models.User, error) {
+	return r.updateFirst(
+		func(user *models.User) bool {
+			return user.Email == email && !user.EmailVerified && user.OIDCSubject == ""
+		},
+		func(user *models.User) {
+			user.OIDCIssuer = issuer
+			user.OIDCSubject = subject
+			user.EmailVerified = true
+			user.EmailVerifiedAt = &now
+			user.EmailVerificationTokenHash = ""
+			user.EmailVerificationExpiresAt = nil
+			user.EmailVerificationSentAt = nil
+			user.Password = ""
+			user.PasswordResetTokenHash = ""
+			user.PasswordResetExpiresAt = nil
+			user.MFAEnabled = false
+			user.MFASecret = ""
+			user.MFAPendingSecret = ""
+			user.MFARecoveryCodeHashes = nil
+			user.MFALastUsedStep = 0
+			user.UpdatedAt = now
+		},
+	)
+}
+
+func (r *MemoryUserRepository) SetMFAPendingSecret(ctx context.Context, id, secret string) error {
+	_, err := r.updateByID(id,
+		func(user *models.User) bool { return !user.MFAEnabled },
+		func(user *models.User) { user.MFAPendingSecret = secret },
+	)
+	return err
+}
+
+func (r *MemoryUserRepository) EnableMFA(ctx context.Context, id, pendingSecret string, recoveryCodeHashes []string, step int64) (bool, error) {
+	return r.updateByID(id,
+		func(user *models.User) bool { return user.MFAPendingSecret == pendingSecret },
+		func(user *models.User) {
+			user.MFAEnabled = true
+			user.MFASecret = pendingSecret
+			user.MFARecoveryCodeHashes = recoveryCodeHashes
+			user.MFALastUsedStep = step
+			user.UpdatedAt = time.Now()
+			user.MFAPendingSecret = ""
+		},
+	)
+}
+
+func (r *MemoryUserRepository) DisableMFA(ctx context.Context, id string) error {
+	_, err := r.updateByID(id, nil, func(user *models.User) {
+		user.MFAEnabled = false
+		user.UpdatedAt = time.Now()
+		user.MFASecret = ""
+		user.MFAPendingSecret = ""
+		user.MFARecoveryCodeHashes = nil
+		user.MFALastUsedStep = 0
+	})
+	return err
+}
+
+func (r *MemoryUserRepository) UseTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	return r.updateByID(id,
+		func(user *models.User) bool { return user.MFALastUsedStep < step },
+		func(user *models.User) { user.MFALastUsedStep = step },
+	)
+}
+
+func (r *MemoryUserRepository) UseRecoveryCode(ctx context.Context, id, codeHash string) (bool, error) {
+	return r.updateByID(id,
+		func(user *models.User) bool { return slices.Contains(user.MFARecoveryCodeHashes, codeHash) },
+		func(user *models.User) {
+			user.MFARecoveryCodeHashes = slices.DeleteFunc(user.MFARecoveryCodeHashes, func(hash string) bool {
+				return hash == codeHash
+			})
+		},
+	)
+}
+
+func (r *MemoryUserRepository) ScheduleDeletion(ctx context.Context, id string, at time.Time) error {
+	_, err := r.updateByID(id, nil, func(user *models.User) {
+		user.DeletionScheduledFor = &at
+		user.UpdatedAt = time.Now()
+	})
+	return err
+}
+
+func (r *MemoryUserRepository) CancelDeletion(ctx context.Context, id string) (bool, error) {
+	return r.updateByID(id,
+		func(user *models.User) bool {
+			return user.DeletionScheduledFor != nil && user.DeletionScheduledFor.After(time.Now())
+		},
+		func(user *models.User) { user.DeletionScheduledFor = nil },
+	)
+}
+
+func (r *MemoryUserRepository) SetRole(ctx context.Context, id string, role models.UserRole) (*models.User, error) {
+	var previous models.User
+	_, err := r.store.update(id, nil, func(user *models.User) {
+		previous = *user
+		// Usuários comuns não guardam papel
+		user.Role = role
+		if role == models.UserRoleUser {
+			user.Role = ""
+		}
+		user.UpdatedAt = time.Now()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+func (r *MemoryUserRepository) GrantAdmin(ctx context.Context, emails []string) (int64, error) {
+	updated, err := r.store.updateWhere(
+		func(user *models.User) bool {
+			return slices.Contains(emails, user.Email) && user.EmailVerified && user.Role != models.UserRoleAdmin
+		},
+		func(user *models.User) {
+			user.Role = models.UserRoleAdmin
+			user.UpdatedAt = time.Now()
+		},
+	)
+	return int64(len(updated)), err
+}
+
+func (r *MemoryUserRepository) Disable(ctx context.Context, id, reason string, at time.Time) (*models.User, error) {
+	return r.store.update(id, nil, func(user *models.User) {
+		user.DisabledAt = &at
+		user.DisabledReason = reason
+		user.UpdatedAt = at
+	})
+}
+
+func (r *MemoryUserRepository) Enable(ctx context.Context, id string) (*models.User, error) {
+	return r.store.update(id, nil, func(user *models.User) {
+		user.DisabledAt = nil
+		user.DisabledReason = ""
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, id string) error {
+	r.store.delete(id)
+	return nil
+}
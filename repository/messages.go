@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Segment seleciona as mensagens de uma conversa. Em conversas bifurcadas, o
+// histórico é formado por um segmento da própria conversa e um por conversa de
+// origem, limitado ao ponto da bifurcação (UpTo, inclusive).
+type Segment struct {
+	ConversationID primitive.ObjectID
+	UpTo           *primitive.ObjectID // nil = todas as mensagens da conversa
+}
+
+// MessagePage define uma página de mensagens: as últimas Limit anteriores a
+// Before, as primeiras Limit posteriores a After ou, sem cursor, as mais
+// recentes. Limit 0 retorna todas.
+type MessagePage struct {
+	Limit  int64
+	Before *primitive.ObjectID
+	After  *primitive.ObjectID
+}
+
+// MessageHit é uma mensagem cujo conteúdo corresponde a uma busca textual
+type MessageHit struct {
+	models.Message `bson:",inline"`
+	Score          float64 `bson:"score"` // Relevância (maior = mais relevante)
+}
+
+// MessageRepository acessa as mensagens (collection messages). Como ObjectIDs
+// seguem a ordem de criação, as mensagens são ordenadas e paginadas por _id.
+type MessageRepository interface {
+	// Create insere a mensagem; o ID é gerado por models.NewMessage
+	Create(ctx context.Context, message *models.Message) error
+
+	// FindByID busca a mensagem
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Message, error)
+
+	// FindLast busca a mensagem mais recente da conversa com o papel informado
+	FindLast(ctx context.Context, conversationID primitive.ObjectID, role models.MessageRole) (*models.Message, error)
+
+	// FindNext busca a mensagem da conversa logo após a mensagem informada
+	FindNext(ctx context.Context, conversationID, messageID primitive.ObjectID) (*models.Message, error)
+
+	// Update grava o conteúdo, as versões e o estado da mensagem. Não é erro se
+	// a mensagem foi removida nesse meio tempo (conversa deletada).
+	Update(ctx context.Context, message *models.Message) error
+
+	// AddVersion grava a versão adicionada com models.Message.AddVersion se a
+	// mensagem ainda está na versão anterior. Retorna false se outra requisição
+	// adicionou uma versão ou removeu a mensagem nesse meio tempo.
+	AddVersion(ctx context.Context, message *models.Message) (bool, error)
+
+	// List retorna uma página das mensagens dos segmentos em ordem cronológica
+	List(ctx context.Context, segments []Segment, page MessagePage) ([]models.Message, error)
+
+	// Count conta as mensagens dos segmentos
+	Count(ctx context.Context, segments []Segment) (int64, error)
+
+	// Contains indica se a mensagem faz parte dos segmentos
+	Contains(ctx context.Context, segments []Segment, messageID primitive.ObjectID) (bool, error)
+
+	// FailPending marca como failed, com o erro reason, as respostas assíncronas
+	// ainda pendentes cujo lease venceu em now (ou sem lease) e retorna quantas
+	// foram alteradas
+	FailPending(ctx context.Context, now time.Time, reason string) (int64, error)
+
+	// RenewLeases estende até until o lease das respostas pendentes da instância owner
+	RenewLeases(ctx context.Context, owner string, until time.Time) (int64, error)
+
+	// DeleteByConversation remove todas as mensagens da conversa
+	DeleteByConversation(ctx context.Context, conversationID primitive.ObjectID) error
+
+	// DeleteAfter remove as mensagens da conversa posteriores à mensagem informada
+	DeleteAfter(ctx context.Context, conversationID, messageID primitive.ObjectID) error
+
+	// Search busca no conteúdo das mensagens das conversas informadas, da mais
+	// relevante para a menos relevante, com a mesma sintaxe de ConversationRepository.Search
+	Search(ctx context.Context, conversationIDs []primitive.ObjectID, query string, limit int64) ([]MessageHit, error)
+}
+
+// addedVersion retorna a versão exibida antes da última chamada a
+// models.Message.AddVersion e as versões que ela adicionou (na primeira
+// edição o conteúdo original também passa a ser uma versão)
+func addedVersion(message *models.Message) (previousIndex int, added []models.MessageVersion) {
+	if len(message.Versions) <= 2 {
+		return 0, message.Versions
+	}
+	return message.VersionIndex - 1, message.Versions[len(message.Versions)-1:]
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongo cria os repositórios sobre o banco informado. Os índices usados
+// pelas consultas são criados por database.EnsureIndexes.
+func NewMongo(db *mongo.Database) Repositories {
+	return Repositories{
+		Users:         NewMongoUserRepository(db),
+		Sessions:      NewMongoSessionRepository(db),
+		APIKeys:       NewMongoAPIKeyRepository(db),
+		LoginAttempts: NewMongoLoginAttemptRepository(db),
+		OIDCStates:    NewMongoOIDCStateRepository(db),
+		Conversations: NewMongoConversationRepository(db),
+		Messages:      NewMongoMessageRepository(db),
+		Audit:         NewMongoAuditRepository(db),
+	}
+}
+
+// translate converte mongo.ErrNoDocuments em ErrNotFound
+func translate(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// findOne decodifica o documento encontrado pelo filtro
+func findOne[T any](ctx context.Context, collection *mongo.Collection, filter interface{}, opts ...*options.FindOneOptions) (*T, error) {
+	var doc T
+	if err := collection.FindOne(ctx, filter, opts...).Decode(&doc); err != nil {
+		return nil, translate(err)
+	}
+	return &doc, nil
+}
+
+// findOneAndUpdate aplica update ao documento encontrado pelo filtro e o
+// retorna como ficou depois da alteração
+func findOneAndUpdate[T any](ctx context.Context, collection *mongo.Collection, filter, update interface{}) (*T, error) {
+	var doc T
+	err := collection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &doc, nil
+}
+
+// findAll decodifica todos os documentos encontrados pelo filtro
+func findAll[T any](ctx context.Context, collection *mongo.Collection, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := []T{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// textSearchOptions ordena a busca textual por relevância, expondo-a no campo score
+func textSearchOptions(limit int64) *options.FindOptions {
+	return options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetLimit(limit)
+}
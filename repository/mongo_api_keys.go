@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAPIKeyRepository implementa APIKeyRepository no MongoDB
+type MongoAPIKeyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoAPIKeyRepository usa a collection api_keys do banco
+func NewMongoAPIKeyRepository(db *mongo.Database) *MongoAPIKeyRepository {
+	return &MongoAPIKeyRepository{collection: db.Collection("api_keys")}
+}
+
+// activeAPIKeysFilter seleciona as chaves do usuário não revogadas nem expiradas
+func activeAPIKeysFilter(userID string, now time.Time) bson.M {
+	return bson.M{
+		"user_id":    userID,
+		"revoked_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": now}},
+		},
+	}
+}
+
+func (r *MongoAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	_, err := r.collection.InsertOne(ctx, key)
+	return err
+}
+
+func (r *MongoAPIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	return findOne[models.APIKey](ctx, r.collection, bson.M{"key_hash": keyHash})
+}
+
+func (r *MongoAPIKeyRepository) ListActive(ctx context.Context, userID string, now time.Time) ([]models.APIKey, error) {
+	return findAll[models.APIKey](ctx, r.collection, activeAPIKeysFilter(userID, now),
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+}
+
+func (r *MongoAPIKeyRepository) CountActive(ctx context.Context, userID string, now time.Time) (int64, error) {
+	return r.collection.CountDocuments(ctx, activeAPIKeysFilter(userID, now))
+}
+
+func (r *MongoAPIKeyRepository) TouchLastUsed(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": now}})
+	return err
+}
+
+func (r *MongoAPIKeyRepository) Revoke(ctx context.Context, id primitive.ObjectID, userID string, now time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoAPIKeyRepository) RevokeByUser(ctx context.Context, userID string, now time.Time) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": now}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *MongoAPIKeyRepository) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAuditRepository implementa AuditRepository no MongoDB
+type MongoAuditRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoAuditRepository usa a collection admin_audit do banco
+func NewMongoAuditRepository(db *mongo.Database) *MongoAuditRepository {
+	return &MongoAuditRepository{collection: db.Collection("admin_audit")}
+}
+
+func (r *MongoAuditRepository) Create(ctx context.Context, entry *models.AuditEntry) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+func (r *MongoAuditRepository) List(ctx context.Context, filter AuditFilter, limit int64) ([]models.AuditEntry, error) {
+	query := bson.M{}
+	if filter.ActorID != "" {
+		query["actor_id"] = filter.ActorID
+	}
+	if filter.TargetID != "" {
+		query["target_id"] = filter.TargetID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.Before != nil {
+		query["_id"] = bson.M{"$lt": *filter.Before}
+	}
+
+	return findAll[models.AuditEntry](ctx, r.collection, query,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit),
+	)
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoConversationRepository implementa ConversationRepository no MongoDB
+type MongoConversationRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoConversationRepository usa a collection conversations do banco
+func NewMongoConversationRepository(db *mongo.Database) *MongoConversationRepository {
+	return &MongoConversationRepository{collection: db.Collection("conversations")}
+}
+
+// defaultTitleFilter seleciona a conversa enquanto ela tem o título padrão
+func defaultTitleFilter(id primitive.ObjectID) bson.M {
+	return bson.M{
+		"_id":         id,
+		"title":       models.DefaultConversationTitle,
+		"titleSource": bson.M{"$nin": bson.A{models.TitleSourceAuto, models.TitleSourceUser}},
+	}
+}
+
+func (r *MongoConversationRepository) Create(ctx context.Context, conversation *models.Conversation) error {
+	_, err := r.collection.InsertOne(ctx, conversation)
+	return err
+}
+
+func (r *MongoConversationRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Conversation, error) {
+	return findOne[models.Conversation](ctx, r.collection, bson.M{"_id": id})
+}
+
+func (r *MongoConversationRepository) FindOwned(ctx context.Context, id primitive.ObjectID, userID string) (*models.Conversation, error) {
+	return findOne[models.Conversation](ctx, r.collection, bson.M{"_id": id, "userId": userID})
+}
+
+func (r *MongoConversationRepository) FindByUser(ctx context.Context, userID string) ([]models.Conversation, error) {
+	return findAll[models.Conversation](ctx, r.collection, bson.M{"userId": userID})
+}
+
+func (r *MongoConversationRepository) List(ctx context.Context, userID string, after *ConversationCursor, limit int64) ([]models.Conversation, error) {
+	filter := bson.M{"userId": userID}
+	if after != nil {
+		// Continuar a partir da última conversa da página anterior
+		filter["$or"] = bson.A{
+			bson.M{"updatedAt": bson.M{"$lt": after.UpdatedAt}},
+			bson.M{"updatedAt": after.UpdatedAt, "_id": bson.M{"$lt": after.ID}},
+		}
+	}
+
+	// _id desempata conversas atualizadas no mesmo instante
+	return findAll[models.Conversation](ctx, r.collection, filter, options.Find().
+		SetSort(bson.D{{Key: "updatedAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit),
+	)
+}
+
+func (r *MongoConversationRepository) CountByUser(ctx context.Context, userID string) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"userId": userID})
+}
+
+func (r *MongoConversationRepository) FindForks(ctx context.Context, parentID primitive.ObjectID) ([]models.Conversation, error) {
+	return findAll[models.Conversation](ctx, r.collection, bson.M{"parentConversationId": parentID})
+}
+
+func (r *MongoConversationRepository) Touch(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"updatedAt": time.Now()}})
+	return err
+}
+
+func (r *MongoConversationRepository) UpdateTitle(ctx context.Context, id primitive.ObjectID, userID, title string) (*models.Conversation, error) {
+	return findOneAndUpdate[models.Conversation](ctx, r.collection,
+		bson.M{"_id": id, "userId": userID},
+		bson.M{"$set": bson.M{
+			"title":       title,
+			"titleSource": models.TitleSourceUser,
+			"updatedAt":   time.Now(),
+		}},
+	)
+}
+
+func (r *MongoConversationRepository) HasDefaultTitle(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, defaultTitleFilter(id))
+	return count > 0, err
+}
+
+func (r *MongoConversationRepository) SetAutoTitle(ctx context.Context, id primitive.ObjectID, title string) error {
+	// O filtro evita sobrescrever um título definido pelo usuário enquanto o título era gerado
+	_, err := r.collection.UpdateOne(ctx, defaultTitleFilter(id), bson.M{"$set": bson.M{
+		"title":       title,
+		"titleSource": models.TitleSourceAuto,
+	}})
+	return err
+}
+
+func (r *MongoConversationRepository) MarkDeleted(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$unset": bson.M{"userId": ""},
+		"$set":   bson.M{"deletedAt": time.Now()},
+	})
+	return err
+}
+
+func (r *MongoConversationRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *MongoConversationRepository) CountActiveUsers(ctx context.Context, since time.Time) (int64, error) {
+	cursor, err := r.collection.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{
+			"updatedAt": bson.M{"$gte": since},
+			"userId":    bson.M{"$exists": true},
+		}},
+		bson.M{"$group": bson.M{"_id": "$userId"}},
+		bson.M{"$count": "users"},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	// Sem conversas no período, $count não retorna documento
+	var result []struct {
+		Users int64 `bson:"users"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+	return result[0].Users, nil
+}
+
+func (r *MongoConversationRepository) Search(ctx context.Context, userID, query string, limit int64) ([]ConversationHit, error) {
+	return findAll[ConversationHit](ctx, r.collection, bson.M{
+		"$text":  bson.M{"$search": query},
+		"userId": userID,
+	}, textSearchOptions(limit))
+}
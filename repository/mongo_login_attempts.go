@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoLoginAttemptRepository implementa LoginAttemptRepository no MongoDB
+type MongoLoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoLoginAttemptRepository usa a collection login_attempts do banco
+func NewMongoLoginAttemptRepository(db *mongo.Database) *MongoLoginAttemptRepository {
+	return &MongoLoginAttemptRepository{collection: db.Collection("login_attempts")}
+}
+
+func (r *MongoLoginAttemptRepository) FindActive(ctx context.Context, ids []string, now time.Time) ([]models.LoginAttempt, error) {
+	return findAll[models.LoginAttempt](ctx, r.collection, bson.M{
+		"_id":        bson.M{"$in": ids},
+		"expires_at": bson.M{"$gt": now},
+	})
+}
+
+func (r *MongoLoginAttemptRepository) RecordFailure(ctx context.Context, id string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	// Pipeline: a contagem e o reinício dependem do documento atual, numa só operação
+	active := bson.M{"$gt": bson.A{"$expires_at", now}}
+
+	var attempt models.LoginAttempt
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"failures": bson.M{"$cond": bson.A{
+				active, bson.M{"$add": bson.A{"$failures", 1}}, 1,
+			}},
+			"locked_until":    bson.M{"$cond": bson.A{active, "$locked_until", "$$REMOVE"}},
+			"last_failure_at": now,
+			"expires_at":      bson.M{"$max": bson.A{now.Add(window), "$locked_until"}},
+		}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (r *MongoLoginAttemptRepository) Lock(ctx context.Context, id string, failures int, lockedUntil, expiresAt time.Time) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "failures": failures},
+		bson.M{"$set": bson.M{
+			"failures":     0,
+			"locked_until": lockedUntil,
+			"expires_at":   expiresAt,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *MongoLoginAttemptRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoMessageRepository implementa MessageRepository no MongoDB
+type MongoMessageRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoMessageRepository usa a collection messages do banco
+func NewMongoMessageRepository(db *mongo.Database) *MongoMessageRepository {
+	return &MongoMessageRepository{collection: db.Collection("messages")}
+}
+
+// segmentsFilter monta o filtro das mensagens dos segmentos
+func segmentsFilter(segments []Segment) bson.M {
+	if len(segments) == 1 && segments[0].UpTo == nil {
+		return bson.M{"conversationId": segments[0].ConversationID}
+	}
+
+	or := make(bson.A, 0, len(segments))
+	for _, segment := range segments {
+		filter := bson.M{"conversationId": segment.ConversationID}
+		if segment.UpTo != nil {
+			filter["_id"] = bson.M{"$lte": *segment.UpTo}
+		}
+		or = append(or, filter)
+	}
+	return bson.M{"$or": or}
+}
+
+func (r *MongoMessageRepository) Create(ctx context.Context, message *models.Message) error {
+	_, err := r.collection.InsertOne(ctx, message)
+	return err
+}
+
+func (r *MongoMessageRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Message, error) {
+	return findOne[models.Message](ctx, r.collection, bson.M{"_id": id})
+}
+
+func (r *MongoMessageRepository) FindLast(ctx context.Context, conversationID primitive.ObjectID, role models.MessageRole) (*models.Message, error) {
+	return findOne[models.Message](ctx, r.collection,
+		bson.M{"conversationId": conversationID, "role": role},
+		options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}),
+	)
+}
+
+func (r *MongoMessageRepository) FindNext(ctx context.Context, conversationID, messageID primitive.ObjectID) (*models.Message, error) {
+	return findOne[models.Message](ctx, r.collection,
+		bson.M{"conversationId": conversationID, "_id": bson.M{"$gt": messageID}},
+		options.FindOne().SetSort(bson.D{{Key: "_id", Value: 1}}),
+	)
+}
+
+func (r *MongoMessageRepository) Update(ctx context.Context, message *models.Message) error {
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": message.ID}, message)
+	return err
+}
+
+func (r *MongoMessageRepository) AddVersion(ctx context.Context, message *models.Message) (bool, error) {
+	previousIndex, added := addedVersion(message)
+	filter := bson.M{"_id": message.ID, "versionIndex": previousIndex}
+	if previousIndex == 0 {
+		// versionIndex 0 não é gravado
+		filter["versionIndex"] = bson.M{"$exists": false}
+	}
+
+	set := bson.M{
+		"content":      message.Content,
+		"latencyMs":    message.LatencyMs,
+		"metadata":     message.Metadata,
+		"versionIndex": message.VersionIndex,
+		"status":       message.Status,
+		"error":        message.Error,
+	}
+	if message.EditedAt != nil {
+		set["editedAt"] = message.EditedAt
+	}
+	if message.CompletedAt != nil {
+		set["completedAt"] = message.CompletedAt
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{
+		"$set":  set,
+		"$push": bson.M{"versions": bson.M{"$each": added}},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *MongoMessageRepository) List(ctx context.Context, segments []Segment, page MessagePage) ([]models.Message, error) {
+	filter := segmentsFilter(segments)
+
+	// Sem "after", as mensagens mais próximas do cursor são as mais recentes:
+	// busca em ordem decrescente e inverte no final
+	ascending := page.After != nil
+	sortOrder := -1
+	switch {
+	case page.After != nil:
+		filter["_id"] = bson.M{"$gt": *page.After}
+		sortOrder = 1
+	case page.Before != nil:
+		filter["_id"] = bson.M{"$lt": *page.Before}
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: sortOrder}})
+	if page.Limit > 0 {
+		findOptions.SetLimit(page.Limit)
+	}
+
+	messages, err := findAll[models.Message](ctx, r.collection, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	if !ascending {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, nil
+}
+
+func (r *MongoMessageRepository) Count(ctx context.Context, segments []Segment) (int64, error) {
+	return r.collection.CountDocuments(ctx, segmentsFilter(segments))
+}
+
+func (r *MongoMessageRepository) Contains(ctx context.Context, segments []Segment, messageID primitive.ObjectID) (bool, error) {
+	filter := segmentsFilter(segments)
+	filter["_id"] = messageID
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *MongoMessageRepository) FailPending(ctx context.Context, now time.Time, reason string) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{
+			"status": models.StatusPending,
+			// $not também seleciona as mensagens sem leaseUntil
+			"leaseUntil": bson.M{"$not": bson.M{"$gt": now}},
+		},
+		bson.M{
+			"$set":   bson.M{"status": models.StatusFailed, "error": reason, "completedAt": now},
+			"$unset": bson.M{"leaseOwner": "", "leaseUntil": ""},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *MongoMessageRepository) RenewLeases(ctx context.Context, owner string, until time.Time) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"status": models.StatusPending, "leaseOwner": owner},
+		bson.M{"$set": bson.M{"leaseUntil": until}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *MongoMessageRepository) DeleteByConversation(ctx context.Context, conversationID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"conversationId": conversationID})
+	return err
+}
+
+func (r *MongoMessageRepository) DeleteAfter(ctx context.Context, conversationID, messageID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"conversationId": conversationID,
+		"_id":            bson.M{"$gt": messageID},
+	})
+	return err
+}
+
+func (r *MongoMessageRepository) Search(ctx context.Context, conversationIDs []primitive.ObjectID, query string, limit int64) ([]MessageHit, error) {
+	return findAll[MessageHit](ctx, r.collection, bson.M{
+		"$text":          bson.M{"$search": query},
+		"conversationId": bson.M{"$in": conversationIDs},
+	}, textSearchOptions(limit))
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoOIDCStateRepository implementa OIDCStateRepository no MongoDB
+type MongoOIDCStateRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoOIDCStateRepository usa a collection oidc_states do banco
+func NewMongoOIDCStateRepository(db *mongo.Database) *MongoOIDCStateRepository {
+	return &MongoOIDCStateRepository{collection: db.Collection("oidc_states")}
+}
+
+func (r *MongoOIDCStateRepository) Create(ctx context.Context, state *models.OIDCState) error {
+	_, err := r.collection.InsertOne(ctx, state)
+	return err
+}
+
+func (r *MongoOIDCStateRepository) Consume(ctx context.Context, id string, now time.Time) (*models.OIDCState, error) {
+	var state models.OIDCState
+	err := r.collection.FindOneAndDelete(ctx, bson.M{
+		"_id":        id,
+		"expires_at": bson.M{"$gt": now},
+	}).Decode(&state)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &state, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoSessionRepository implementa SessionRepository no MongoDB
+type MongoSessionRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoSessionRepository usa a collection sessions do banco
+func NewMongoSessionRepository(db *mongo.Database) *MongoSessionRepository {
+	return &MongoSessionRepository{collection: db.Collection("sessions")}
+}
+
+func (r *MongoSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	_, err := r.collection.InsertOne(ctx, session)
+	return err
+}
+
+func (r *MongoSessionRepository) FindByID(ctx context.Context, id primitive.ObjectID, userID string) (*models.Session, error) {
+	return findOne[models.Session](ctx, r.collection, bson.M{"_id": id, "user_id": userID})
+}
+
+func (r *MongoSessionRepository) Rotate(ctx context.Context, tokenHash, newTokenHash string, now, expiresAt time.Time) (*models.Session, error) {
+	return findOneAndUpdate[models.Session](ctx, r.collection,
+		bson.M{
+			"refresh_token_hash": tokenHash,
+			"revoked_at":         bson.M{"$exists": false},
+			"expires_at":         bson.M{"$gt": now},
+		},
+		bson.M{
+			"$set": bson.M{
+				"refresh_token_hash": newTokenHash,
+				"last_used_at":       now,
+				"expires_at":         expiresAt,
+			},
+			"$push": bson.M{"previous_token_hashes": bson.M{
+				"$each":  bson.A{tokenHash},
+				"$slice": -maxPreviousTokenHashes,
+			}},
+		},
+	)
+}
+
+func (r *MongoSessionRepository) Revoke(ctx context.Context, filter SessionFilter, reason string, now time.Time) (int64, error) {
+	if filter == (SessionFilter{}) {
+		return 0, nil
+	}
+
+	query := bson.M{"revoked_at": bson.M{"$exists": false}}
+	ids := bson.M{}
+	if filter.ID != nil {
+		ids["$eq"] = *filter.ID
+	}
+	if filter.ExceptID != nil {
+		ids["$ne"] = *filter.ExceptID
+	}
+	if len(ids) > 0 {
+		query["_id"] = ids
+	}
+	if filter.UserID != "" {
+		query["user_id"] = filter.UserID
+	}
+	if filter.TokenHash != "" {
+		query["$or"] = bson.A{
+			bson.M{"refresh_token_hash": filter.TokenHash},
+			bson.M{"previous_token_hashes": filter.TokenHash},
+		}
+	}
+	if filter.PreviousTokenHash != "" {
+		query["previous_token_hashes"] = filter.PreviousTokenHash
+	}
+
+	result, err := r.collection.UpdateMany(ctx, query, bson.M{"$set": bson.M{
+		"revoked_at":    now,
+		"revoke_reason": reason,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *MongoSessionRepository) CountActive(ctx context.Context, userID string, now time.Time) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{
+		"user_id":    userID,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	})
+}
+
+func (r *MongoSessionRepository) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
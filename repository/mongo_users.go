@@ -0,0 +1,366 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoUserRepository implementa UserRepository no MongoDB
+type MongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoUserRepository usa a collection users do banco
+func NewMongoUserRepository(db *mongo.Database) *MongoUserRepository {
+	return &MongoUserRepository{collection: db.Collection("users")}
+}
+
+// userID converte o ID hexadecimal; IDs inválidos não correspondem a nenhuma conta
+func userID(id string) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, ErrNotFound
+	}
+	return objectID, nil
+}
+
+// updateByID aplica update à conta se ela atende ao filtro (além do ID) e
+// indica se atendia
+func (r *MongoUserRepository) updateByID(ctx context.Context, id string, filter, update bson.M) (bool, error) {
+	objectID, err := userID(id)
+	if err != nil {
+		return false, err
+	}
+	filter["_id"] = objectID
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *MongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	result, err := r.collection.InsertOne(ctx, user)
+	if err != nil {
+		return translate(err)
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r *MongoUserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
+	objectID, err := userID(id)
+	if err != nil {
+		return nil, err
+	}
+	return findOne[models.User](ctx, r.collection, bson.M{"_id": objectID})
+}
+
+func (r *MongoUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return findOne[models.User](ctx, r.collection, bson.M{"email": email})
+}
+
+func (r *MongoUserRepository) FindByOIDC(ctx context.Context, issuer, subject string) (*models.User, error) {
+	return findOne[models.User](ctx, r.collection, bson.M{"oidc_issuer": issuer, "oidc_subject": subject})
+}
+
+func (r *MongoUserRepository) List(ctx context.Context, filter UserFilter, limit int64) ([]models.User, error) {
+	query := bson.M{}
+	if filter.Query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		query["$or"] = bson.A{bson.M{"email": pattern}, bson.M{"name": pattern}}
+	}
+	switch filter.Role {
+	case "":
+	case models.UserRoleUser:
+		// Usuários comuns não guardam papel
+		query["role"] = bson.M{"$exists": false}
+	default:
+		query["role"] = filter.Role
+	}
+	if filter.Disabled {
+		query["disabled_at"] = bson.M{"$exists": true}
+	}
+	if filter.Before != "" {
+		before, err := userID(filter.Before)
+		if err != nil {
+			return []models.User{}, nil
+		}
+		query["_id"] = bson.M{"$lt": before}
+	}
+
+	return findAll[models.User](ctx, r.collection, query,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit),
+	)
+}
+
+func (r *MongoUserRepository) FindDeletionDue(ctx context.Context, now time.Time, limit int64) ([]models.User, error) {
+	return findAll[models.User](ctx, r.collection,
+		bson.M{"deletion_scheduled_for": bson.M{"$lte": now}},
+		options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(limit),
+	)
+}
+
+func (r *MongoUserRepository) UpdateProfile(ctx context.Context, id string, name, bio *string) (*models.User, error) {
+	objectID, err := userID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	if name != nil {
+		set["name"] = name
+	}
+	if bio != nil {
+		set["bio"] = bio
+	}
+	return findOneAndUpdate[models.User](ctx, r.collection, bson.M{"_id": objectID}, bson.M{"$set": set})
+}
+
+func (r *MongoUserRepository) ChangePassword(ctx context.Context, id, currentHash, newHash string) (bool, error) {
+	return r.updateByID(ctx, id,
+		bson.M{"password": currentHash},
+		bson.M{
+			"$set": bson.M{"password": newHash, "updated_at": time.Now()},
+			// Um link de redefinição pendente foi pedido com a senha antiga
+			"$unset": bson.M{"password_reset_token_hash": "", "password_reset_expires_at": ""},
+		},
+	)
+}
+
+func (r *MongoUserRepository) SetPasswordResetToken(ctx context.Context, email, tokenHash string, expiresAt time.Time) (*models.User, error) {
+	return findOneAndUpdate[models.User](ctx, r.collection,
+		bson.M{"email": email},
+		bson.M{"$set": bson.M{
+			"password_reset_token_hash": tokenHash,
+			"password_reset_expires_at": expiresAt,
+		}},
+	)
+}
+
+func (r *MongoUserRepository) ResetPassword(ctx context.Context, tokenHash, passwordHash string, now time.Time) (*models.User, error) {
+	return findOneAndUpdate[models.User](ctx, r.collection,
+		bson.M{
+			"password_reset_token_hash": tokenHash,
+			"password_reset_expires_at": bson.M{"$gt": now},
+		},
+		bson.M{
+			"$set":   bson.M{"password": passwordHash, "updated_at": now},
+			"$unset": bson.M{"password_reset_token_hash": "", "password_reset_expires_at": ""},
+		},
+	)
+}
+
+func (r *MongoUserRepository) VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error {
+	_, err := findOneAndUpdate[models.User](ctx, r.collection,
+		bson.M{
+			"email_verification_token_hash": tokenHash,
+			"email_verification_expires_at": bson.M{"$gt": now},
+		},
+		bson.M{
+			"$set": bson.M{"email_verified": true, "email_verified_at": now, "updated_at": now},
+			"$unset": bson.M{
+				"email_verification_token_hash": "",
+				"email_verification_expires_at": "",
+				"email_verification_sent_at":    "",
+			},
+		},
+	)
+	return err
+}
+
+func (r *MongoUserRepository) RenewVerificationToken(ctx context.Context, id, tokenHash string, now, expiresAt time.Time, resendInterval time.Duration) (*models.User, error) {
+	objectID, err := userID(id)
+	if err != nil {
+		return nil, err
+	}
+	return findOneAndUpdate[models.User](ctx, r.collection,
+		bson.M{
+			"_id":            objectID,
+			"email_verified": bson.M{"$ne": true},
+			"$or": bson.A{
+				bson.M{"email_verification_sent_at": bson.M{"$exists": false}},
+				bson.M{"email_verification_sent_at": bson.M{"$lte": now.Add(-resendInterval)}},
+			},
+		},
+		bson.M{"$set": bson.M{
+			"email_verification_token_hash": tokenHash,
+			"email_verification_expires_at": expiresAt,
+			"email_verification_sent_at":    now,
+		}},
+	)
+}
+
+func (r *MongoUserRepository) LinkOIDC(ctx context.Context, email, issuer, subject string) (*models.User, error) {
+	return findOneAndUpdate[models.User](ctx, r.collection,
+		bson.M{"email": email, "email_verified": true, "oidc_subject": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"oidc_issuer":  issuer,
+			"oidc_subject": subject,
+			"updated_at":   time.Now(),
+		}},
+	)
+}
+
+func (r *MongoUserRepository) ClaimUnverifiedOIDC(ctx context.Context, email, issuer, subject string, now time.Time) (*models.User, error) {
+	return findOneAndUpdate[models.User](ctx, r.collection,
+		bson.M{"email": email, "email_verified": bson.M{"$ne": true}, "oidc_subject": bson.M{"$exists": false}},
+		bson.M{
+			"$set": bson.M{
+				"oidc_issuer":       issuer,
+				"oidc_subject":      subject,
+				"email_verified":    true,
+				"email_verified_at": now,
+				"password":          "",
+				"mfa_enabled":       false,
+				"updated_at":        now,
+			},
+			"$unset": bson.M{
+				"email_verification_token_hash": "",
+				"email_verification_expires_at": "",
+				"email_verification_sent_at":    "",
+				"password_reset_token_hash":     "",
+				"password_reset_expires_at":     "",
+				"mfa_secret":                    "",
+				"mfa_pending_secret":            "",
+				"mfa_recovery_code_hashes":      "",
+				"mfa_last_used_step":            "",
+			},
+		},
+	)
+}
+
+func (r *MongoUserRepository) SetMFAPendingSecret(ctx context.Context, id, secret string) error {
+	_, err := r.updateByID(ctx, id,
+		bson.M{"mfa_enabled": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"mfa_pending_secret": secret}},
+	)
+	return err
+}
+
+func (r *MongoUserRepository) EnableMFA(ctx context.Context, id, pendingSecret string, recoveryCodeHashes []string, step int64) (bool, error) {
+	return r.updateByID(ctx, id,
+		bson.M{"mfa_pending_secret": pendingSecret},
+		bson.M{
+			"$set": bson.M{
+				"mfa_enabled":              true,
+				"mfa_secret":               pendingSecret,
+				"mfa_recovery_code_hashes": recoveryCodeHashes,
+				"mfa_last_used_step":       step,
+				"updated_at":               time.Now(),
+			},
+			"$unset": bson.M{"mfa_pending_secret": ""},
+		},
+	)
+}
+
+func (r *MongoUserRepository) DisableMFA(ctx context.Context, id string) error {
+	_, err := r.updateByID(ctx, id, bson.M{}, bson.M{
+		"$set": bson.M{"mfa_enabled": false, "updated_at": time.Now()},
+		"$unset": bson.M{
+			"mfa_secret":               "",
+			"mfa_pending_secret":       "",
+			"mfa_recovery_code_hashes": "",
+			"mfa_last_used_step":       "",
+		},
+	})
+	return err
+}
+
+func (r *MongoUserRepository) UseTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	return r.updateByID(ctx, id,
+		bson.M{"mfa_last_used_step": bson.M{"$not": bson.M{"$gte": step}}},
+		bson.M{"$set": bson.M{"mfa_last_used_step": step}},
+	)
+}
+
+func (r *MongoUserRepository) UseRecoveryCode(ctx context.Context, id, codeHash string) (bool, error) {
+	return r.updateByID(ctx, id,
+		bson.M{"mfa_recovery_code_hashes": codeHash},
+		bson.M{"$pull": bson.M{"mfa_recovery_code_hashes": codeHash}},
+	)
+}
+
+func (r *MongoUserRepository) ScheduleDeletion(ctx context.Context, id string, at time.Time) error {
+	_, err := r.updateByID(ctx, id, bson.M{},
+		bson.M{"$set": bson.M{"deletion_scheduled_for": at, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+func (r *MongoUserRepository) CancelDeletion(ctx context.Context, id string) (bool, error) {
+	// Depois do prazo a conta pode já estar sendo removida
+	return r.updateByID(ctx, id,
+		bson.M{"deletion_scheduled_for": bson.M{"$gt": time.Now()}},
+		bson.M{"$unset": bson.M{"deletion_scheduled_for": ""}},
+	)
+}
+
+func (r *MongoUserRepository) SetRole(ctx context.Context, id string, role models.UserRole) (*models.User, error) {
+	objectID, err := userID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	update := bson.M{"$set": bson.M{"role": role, "updated_at": time.Now()}}
+	if role == models.UserRoleUser {
+		update = bson.M{"$unset": bson.M{"role": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	}
+	// Sem ReturnDocument: o documento retornado é o anterior à alteração
+	var previous models.User
+	if err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": objectID}, update).Decode(&previous); err != nil {
+		return nil, translate(err)
+	}
+	return &previous, nil
+}
+
+func (r *MongoUserRepository) GrantAdmin(ctx context.Context, emails []string) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"email": bson.M{"$in": emails}, "email_verified": true, "role": bson.M{"$ne": models.UserRoleAdmin}},
+		bson.M{"$set": bson.M{"role": models.UserRoleAdmin, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *MongoUserRepository) Disable(ctx context.Context, id, reason string, at time.Time) (*models.User, error) {
+	objectID, err := userID(id)
+	if err != nil {
+		return nil, err
+	}
+	return findOneAndUpdate[models.User](ctx, r.collection, bson.M{"_id": objectID}, bson.M{"$set": bson.M{
+		"disabled_at":     at,
+		"disabled_reason": reason,
+		"updated_at":      at,
+	}})
+}
+
+func (r *MongoUserRepository) Enable(ctx context.Context, id string) (*models.User, error) {
+	objectID, err := userID(id)
+	if err != nil {
+		return nil, err
+	}
+	return findOneAndUpdate[models.User](ctx, r.collection, bson.M{"_id": objectID}, bson.M{
+		"$unset": bson.M{"disabled_at": "", "disabled_reason": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+	})
+}
+
+func (r *MongoUserRepository) Delete(ctx context.Context, id string) error {
+	objectID, err := userID(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	return err
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+)
+
+// OIDCStateRepository acessa os logins OIDC em andamento (collection
+// oidc_states), identificados pelo hash do parâmetro state
+type OIDCStateRepository interface {
+	// Create insere o login em andamento
+	Create(ctx context.Context, state *models.OIDCState) error
+
+	// Consume remove e retorna o login em andamento, se não expirou em now.
+	// O state é de uso único: uma segunda chamada retorna ErrNotFound.
+	Consume(ctx context.Context, id string, now time.Time) (*models.OIDCState, error)
+}
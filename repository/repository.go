@@ -0,0 +1,28 @@
+// Package repository isola o acesso aos dados da API: usuários, sessões,
+// chaves de API, tentativas de login, logins OIDC em andamento, conversas,
+// mensagens e a trilha de auditoria. Os controllers recebem os repositórios no construtor: em produção
+// usam a implementação do MongoDB (NewMongo) e, em testes com httptest, a
+// implementação em memória (NewMemory), sem banco de dados.
+package repository
+
+import "errors"
+
+// ErrNotFound indica que o documento não existe ou não atende às condições da
+// operação (por exemplo, a conversa não pertence ao usuário)
+var ErrNotFound = errors.New("documento não encontrado")
+
+// ErrDuplicate indica que a gravação violaria um índice único (por exemplo,
+// outra conta já usa o email)
+var ErrDuplicate = errors.New("documento duplicado")
+
+// Repositories agrupa os repositórios usados pela API
+type Repositories struct {
+	Users         UserRepository
+	Sessions      SessionRepository
+	APIKeys       APIKeyRepository
+	LoginAttempts LoginAttemptRepository
+	OIDCStates    OIDCStateRepository
+	Conversations ConversationRepository
+	Messages      MessageRepository
+	Audit         AuditRepository
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxPreviousTokenHashes limita quantos refresh tokens já trocados são
+// guardados por sessão para detectar reutilização
+const maxPreviousTokenHashes = 100
+
+// SessionFilter seleciona sessões ativas a revogar. Os campos preenchidos são
+// combinados; um filtro vazio não seleciona nenhuma sessão.
+type SessionFilter struct {
+	ID                *primitive.ObjectID // Somente esta sessão
+	UserID            string              // Sessões do usuário
+	ExceptID          *primitive.ObjectID // Exceto esta sessão
+	TokenHash         string              // Refresh token atual ou já trocado
+	PreviousTokenHash string              // Somente refresh token já trocado
+}
+
+// SessionRepository acessa as sessões de login (collection sessions). Cada
+// sessão guarda o hash do refresh token atual; os access tokens referenciam a
+// sessão pelo ID.
+type SessionRepository interface {
+	// Create insere a sessão; o ID é gerado por quem chama
+	Create(ctx context.Context, session *models.Session) error
+
+	// FindByID busca a sessão do usuário, ativa ou revogada
+	FindByID(ctx context.Context, id primitive.ObjectID, userID string) (*models.Session, error)
+
+	// Rotate troca o refresh token de uma sessão ativa em now cujo token atual
+	// é tokenHash, guardando o anterior, e retorna a sessão atualizada
+	Rotate(ctx context.Context, tokenHash, newTokenHash string, now, expiresAt time.Time) (*models.Session, error)
+
+	// Revoke revoga as sessões ativas selecionadas pelo filtro e retorna quantas
+	Revoke(ctx context.Context, filter SessionFilter, reason string, now time.Time) (int64, error)
+
+	// CountActive conta as sessões do usuário não revogadas nem expiradas em now
+	CountActive(ctx context.Context, userID string, now time.Time) (int64, error)
+
+	// DeleteByUser remove todas as sessões do usuário
+	DeleteByUser(ctx context.Context, userID string) error
+}
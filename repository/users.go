@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatserver/models"
+)
+
+// UserFilter filtra a listagem de contas das rotas administrativas
+type UserFilter struct {
+	Query    string          // Trecho do email ou do nome (sem diferenciar maiúsculas)
+	Role     models.UserRole // Papel efetivo; vazio = todos
+	Disabled bool            // Somente contas desativadas
+	Before   string          // Continua a listagem antes deste ID (cursor)
+}
+
+// UserRepository acessa as contas (collection users). As operações com
+// condições (tokens, MFA, troca de senha) verificam e atualizam o documento de
+// uma só vez, para que requisições simultâneas não tenham sucesso as duas.
+type UserRepository interface {
+	// Create insere o usuário e preenche user.ID. Retorna ErrDuplicate se outra
+	// conta já usa o email ou a conta do provedor de identidade.
+	Create(ctx context.Context, user *models.User) error
+
+	// FindByID busca o usuário pelo ID hexadecimal
+	FindByID(ctx context.Context, id string) (*models.User, error)
+
+	// FindByEmail busca o usuário pelo email
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+
+	// FindByOIDC busca o usuário vinculado à conta do provedor de identidade
+	FindByOIDC(ctx context.Context, issuer, subject string) (*models.User, error)
+
+	// List retorna até limit contas, das mais recentes para as mais antigas
+	List(ctx context.Context, filter UserFilter, limit int64) ([]models.User, error)
+
+	// FindDeletionDue retorna até limit contas cuja exclusão agendada venceu
+	FindDeletionDue(ctx context.Context, now time.Time, limit int64) ([]models.User, error)
+
+	// UpdateProfile altera nome e bio (campos nil não são alterados) e retorna o usuário atualizado
+	UpdateProfile(ctx context.Context, id string, name, bio *string) (*models.User, error)
+
+	// ChangePassword troca o hash da senha se ele ainda é currentHash,
+	// invalidando um link de redefinição pendente. Retorna false se a senha
+	// foi alterada por outra requisição.
+	ChangePassword(ctx context.Context, id, currentHash, newHash string) (bool, error)
+
+	// SetPasswordResetToken grava o token de redefinição da conta do email,
+	// substituindo o anterior, e retorna o usuário
+	SetPasswordResetToken(ctx context.Context, email, tokenHash string, expiresAt time.Time) (*models.User, error)
+
+	// ResetPassword consome um token de redefinição válido em now e define a nova senha
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string, now time.Time) (*models.User, error)
+
+	// VerifyEmail consome um token de verificação válido em now e confirma o email
+	VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error
+
+	// RenewVerificationToken grava um novo token de verificação se o email não
+	// foi verificado e o último envio tem pelo menos resendInterval. Retorna
+	// ErrNotFound quando o envio é recusado.
+	RenewVerificationToken(ctx context.Context, id, tokenHash string, now, expiresAt time.Time, resendInterval time.Duration) (*models.User, error)
+
+	// LinkOIDC vincula a conta do email, já verificado e ainda não vinculada,
+	// à conta do provedor de identidade e retorna o usuário atualizado
+	LinkOIDC(ctx context.Context, email, issuer, subject string) (*models.User, error)
+
+	// ClaimUnverifiedOIDC vincula a conta do email, não verificado e ainda não
+	// vinculada, à conta do provedor de identidade, que comprovou o email.
+	// Quem cadastrou a conta pode não ser o dono do email: a senha, os tokens
+	// pendentes e o MFA são removidos. Retorna o usuário atualizado.
+	ClaimUnverifiedOIDC(ctx context.Context, email, issuer, subject string, now time.Time) (*models.User, error)
+
+	// SetMFAPendingSecret grava o segredo de uma configuração de MFA ainda não
+	// confirmada, se o MFA não está ativo
+	SetMFAPendingSecret(ctx context.Context, id, secret string) error
+
+	// EnableMFA ativa o MFA com o segredo pendente, se ele ainda é pendingSecret
+	EnableMFA(ctx context.Context, id, pendingSecret string, recoveryCodeHashes []string, step int64) (bool, error)
+
+	// DisableMFA desativa o MFA e remove os segredos e códigos de recuperação
+	DisableMFA(ctx context.Context, id string) error
+
+	// UseTOTPStep registra o uso de um código TOTP, recusando passos já usados
+	UseTOTPStep(ctx context.Context, id string, step int64) (bool, error)
+
+	// UseRecoveryCode consome um código de recuperação ainda não usado
+	UseRecoveryCode(ctx context.Context, id, codeHash string) (bool, error)
+
+	// ScheduleDeletion agenda a exclusão definitiva da conta
+	ScheduleDeletion(ctx context.Context, id string, at time.Time) error
+
+	// CancelDeletion cancela a exclusão agendada se o prazo ainda não venceu
+	CancelDeletion(ctx context.Context, id string) (bool, error)
+
+	// SetRole define o papel do usuário e retorna o documento anterior à alteração
+	SetRole(ctx context.Context, id string, role models.UserRole) (*models.User, error)
+
+	// GrantAdmin promove a administrador as contas com os emails informados,
+	// somente se o email foi verificado, e retorna quantas foram alteradas
+	GrantAdmin(ctx context.Context, emails []string) (int64, error)
+
+	// Disable desativa a conta e retorna o usuário atualizado
+	Disable(ctx context.Context, id, reason string, at time.Time) (*models.User, error)
+
+	// Enable reativa a conta e retorna o usuário atualizado
+	Enable(ctx context.Context, id string) (*models.User, error)
+
+	// Delete remove a conta
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,105 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890"
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},          // 94287082
+	{1111111109, "081804"},  // 07081804
+	{1111111111, "050471"},  // 14050471
+	{1234567890, "005924"},  // 89005924
+	{2000000000, "279037"},  // 69279037
+	{20000000000, "353130"}, // 65353130
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		code, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != v.code {
+			t.Errorf("T=%d: code %s, want %s", v.unix, code, v.code)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseSecret(t *testing.T) {
+	code, err := Code(strings.ToLower(rfcSecret), Step(time.Unix(59, 0)))
+	if err != nil || code != "287082" {
+		t.Errorf("lowercase secret: code %q, error %v", code, err)
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("invalid secret: expected an error")
+	}
+}
+
+func TestValidateReturnsMatchedStep(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, err := Code(rfcSecret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := Validate(rfcSecret, code, now, 1)
+		if !ok {
+			t.Errorf("offset %d: code rejected", offset)
+			continue
+		}
+		// Controllers store the step to refuse the same code twice
+		if step != current+offset {
+			t.Errorf("offset %d: step %d, want %d", offset, step, current+offset)
+		}
+	}
+
+	// Spaces typed by the user are ignored
+	code, _ := Code(rfcSecret, current)
+	if _, ok := Validate(rfcSecret, code[:3]+" "+code[3:], now, 1); !ok {
+		t.Error("code with a space rejected")
+	}
+}
+
+func TestValidateBoundsSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	for _, offset := range []int64{-3, -2, 2, 3} {
+		code, err := Code(rfcSecret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := Validate(rfcSecret, code, now, 1); ok {
+			t.Errorf("offset %d accepted with skew 1", offset)
+		}
+	}
+
+	// Without skew only the current step is accepted
+	previous, _ := Code(rfcSecret, current-1)
+	if _, ok := Validate(rfcSecret, previous, now, 0); ok {
+		t.Error("previous step accepted with skew 0")
+	}
+}
+
+func TestValidateRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "94287082", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, now, 1); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+}
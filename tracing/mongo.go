@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewMongoMonitor returns a command monitor that records a client span for
+// each MongoDB command run with a traced context. Commands run outside a
+// trace (background workers, index creation) are not recorded. Filters and
+// documents are never added to the spans.
+func NewMongoMonitor() *event.CommandMonitor {
+	var spans sync.Map // Request ID of the command -> trace.Span
+
+	finish := func(requestID int64, failure string) {
+		value, ok := spans.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		if failure != "" {
+			RecordError(span, errors.New(failure))
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				return
+			}
+
+			collection := mongoCollection(evt)
+			name := evt.CommandName
+			if collection != "" {
+				name += " " + collection
+			}
+			_, span := Tracer().Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system", "mongodb"),
+					attribute.String("db.namespace", evt.DatabaseName),
+					attribute.String("db.operation.name", evt.CommandName),
+					attribute.String("db.collection.name", collection),
+				),
+			)
+			if span.IsRecording() {
+				spans.Store(evt.RequestID, span)
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.RequestID, "")
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.RequestID, evt.Failure)
+		},
+	}
+}
+
+// mongoCollection returns the collection targeted by the command: the value of
+// the command name field (find, insert, aggregate...) or, for getMore, the
+// collection field
+func mongoCollection(evt *event.CommandStartedEvent) string {
+	if collection, ok := evt.Command.Lookup(evt.CommandName).StringValueOK(); ok {
+		return collection
+	}
+	collection, _ := evt.Command.Lookup("collection").StringValueOK()
+	return collection
+}
@@ -0,0 +1,30 @@
+// Package tracing configures OpenTelemetry for the API: the tracer provider
+// that samples and exports the spans, and the W3C Trace Context propagator
+// (traceparent header) used to continue the trace of callers and to forward
+// it to the services called. Spans are created with the OpenTelemetry API
+// through Tracer. Without an exporter no span is recorded, but the trace
+// context received from the caller is still forwarded.
+package tracing
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName identifies the instrumentation of this module in the exported spans
+const ScopeName = "chatserver"
+
+// Tracer returns the tracer of the provider installed by Setup
+func Tracer() trace.Tracer {
+	return otel.Tracer(ScopeName)
+}
+
+// RecordError records err on the span and marks it as failed; a nil err is ignored
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}